@@ -1,22 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/arodd/go-unrarall/internal/app"
 	"github.com/arodd/go-unrarall/internal/cli"
 	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/plan"
 )
 
 // version may be overridden at build time with:
 // -ldflags "-X main.version=<version>"
 var version = "1.0.1"
 
-var runApp = app.Run
+var (
+	runApp       = app.Run
+	runPasswords = app.RunPasswords
+	runVerify    = app.RunVerify
+	runWatch     = app.Watch
+	runServe     = app.Serve
+)
 
 func main() {
 	os.Exit(run(os.Args))
@@ -29,6 +40,19 @@ func run(args []string) int {
 func runWithIO(args []string, stdout, stderr io.Writer) (exitCode int) {
 	program := programName(args)
 
+	if cli.IsPasswordsCommand(args) {
+		return runPasswordsCommand(program, args, stdout, stderr)
+	}
+	if cli.IsVerifyCommand(args) {
+		return runVerifyCommand(program, args, stdout, stderr)
+	}
+	if cli.IsWatchCommand(args) {
+		return runWatchCommand(program, args, stdout, stderr)
+	}
+	if cli.IsServeCommand(args) {
+		return runServeCommand(program, args, stdout, stderr)
+	}
+
 	opts, err := cli.ParseArgs(args)
 	if err != nil {
 		parseStderr := stderr
@@ -80,8 +104,24 @@ func runWithIO(args []string, stdout, stderr io.Writer) (exitCode int) {
 		return 0
 	}
 
-	logger := log.NewWithWriters(opts.Quiet, opts.Verbose, stdoutSink, stderrSink)
-	stats, runErr := runApp(opts, logger)
+	infoSink := stdoutSink
+	if opts.Plan == plan.JSON {
+		// Keep stdout for the JSON plan.
+		infoSink = stderrSink
+	}
+	logger := log.NewWithWriters(opts.Quiet, opts.Verbose, infoSink, stderrSink)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal gets the default behavior and ends the process.
+		<-ctx.Done()
+		stop()
+	}()
+	stats, runErr := runApp(ctx, opts, logger)
+	if errors.Is(runErr, context.Canceled) {
+		return app.ExitCanceled
+	}
 	if runErr != nil {
 		logger.Errorf("Run failed: %v", runErr)
 		return 1
@@ -90,6 +130,111 @@ func runWithIO(args []string, stdout, stderr io.Writer) (exitCode int) {
 	return app.ExitCode(stats, opts.AllowFailures)
 }
 
+func runPasswordsCommand(program string, args []string, stdout, stderr io.Writer) int {
+	opts, err := cli.ParsePasswordsArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n\n", err)
+		fmt.Fprint(stderr, cli.PasswordsUsage(program))
+		return 1
+	}
+	if opts.ShowHelp {
+		fmt.Fprint(stdout, cli.PasswordsUsage(program))
+		return 0
+	}
+	if err := runPasswords(opts, os.Stdin, stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runVerifyCommand(program string, args []string, stdout, stderr io.Writer) int {
+	opts, err := cli.ParseVerifyArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n\n", err)
+		fmt.Fprint(stderr, cli.VerifyUsage(program))
+		return 1
+	}
+	if opts.ShowHelp {
+		fmt.Fprint(stdout, cli.VerifyUsage(program))
+		return 0
+	}
+	if err := runVerify(opts, stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runWatchCommand(program string, args []string, stdout, stderr io.Writer) (exitCode int) {
+	opts, err := cli.ParseWatchArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n\n", err)
+		fmt.Fprint(stderr, cli.WatchUsage(program))
+		return 1
+	}
+	if opts.Options.ShowHelp {
+		fmt.Fprint(stdout, cli.WatchUsage(program))
+		return 0
+	}
+	if opts.Options.ShowVersion {
+		fmt.Fprintf(stdout, "%s %s\n", program, version)
+		return 0
+	}
+
+	if opts.Options.LogFile != "" {
+		var logFile *os.File
+		stdout, stderr, logFile, err = appendSinks(stdout, stderr, opts.Options.LogFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer func() {
+			if closeErr := logFile.Close(); closeErr != nil {
+				fmt.Fprintf(stderr, "Error: close log file %q: %v\n", opts.Options.LogFile, closeErr)
+				if exitCode == 0 {
+					exitCode = 1
+				}
+			}
+		}()
+	}
+	logger := log.NewWithWriters(opts.Options.Quiet, opts.Options.Verbose, stdout, stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	if err := runWatch(ctx, opts, reload, logger); err != nil {
+		logger.Errorf("Watch failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func runServeCommand(program string, args []string, stdout, stderr io.Writer) int {
+	opts, err := cli.ParseServeArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n\n", err)
+		fmt.Fprint(stderr, cli.ServeUsage(program))
+		return 1
+	}
+	if opts.ShowHelp {
+		fmt.Fprint(stdout, cli.ServeUsage(program))
+		return 0
+	}
+	logger := log.NewWithWriters(opts.Quiet, opts.Verbose, stdout, stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := runServe(ctx, opts, logger); err != nil {
+		logger.Errorf("Serve failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
 func appendSinks(stdout, stderr io.Writer, logFilePath string) (io.Writer, io.Writer, *os.File, error) {
 	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
 	if err != nil {
@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/arodd/go-unrarall/internal/app"
 	"github.com/arodd/go-unrarall/internal/cli"
@@ -114,7 +118,7 @@ func TestRunWithIORuntimeMessagesTeeToLogFile(t *testing.T) {
 	defer func() {
 		runApp = originalRunApp
 	}()
-	runApp = func(_ cli.Options, logger *logpkg.Logger) (app.Stats, error) {
+	runApp = func(_ context.Context, _ cli.Options, logger *logpkg.Logger) (app.Stats, error) {
 		logger.Infof("runtime info")
 		return app.Stats{}, errors.New("runtime boom")
 	}
@@ -145,3 +149,135 @@ func TestRunWithIORuntimeMessagesTeeToLogFile(t *testing.T) {
 		t.Fatalf("expected runtime error in log file, got %q", logOutput)
 	}
 }
+
+func TestRunWithIOInterruptCancelsRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("os.Interrupt cannot be sent on windows")
+	}
+	originalRunApp := runApp
+	defer func() {
+		runApp = originalRunApp
+	}()
+	runApp = func(ctx context.Context, _ cli.Options, _ *logpkg.Logger) (app.Stats, error) {
+		self, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			return app.Stats{}, err
+		}
+		if err := self.Signal(os.Interrupt); err != nil {
+			return app.Stats{}, err
+		}
+		select {
+		case <-ctx.Done():
+			return app.Stats{}, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return app.Stats{}, errors.New("the run was not canceled")
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if exitCode := runWithIO([]string{"unrarall", t.TempDir()}, &stdout, &stderr); exitCode != app.ExitCanceled {
+		t.Fatalf("runWithIO exit code=%d, want %d (stderr %q)", exitCode, app.ExitCanceled, stderr.String())
+	}
+}
+
+func TestRunWithIODispatchesPasswordsCommand(t *testing.T) {
+	originalRunPasswords := runPasswords
+	defer func() {
+		runPasswords = originalRunPasswords
+	}()
+
+	var got cli.PasswordsOptions
+	runPasswords = func(opts cli.PasswordsOptions, _ *os.File, _, _ io.Writer) error {
+		got = opts
+		return nil
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	exitCode := runWithIO([]string{"unrarall", "passwords", "remove", "--vault", "/tmp/vault"}, &stdout, &stderr)
+	if exitCode != 0 {
+		t.Fatalf("runWithIO exit code=%d, want 0 (stderr %q)", exitCode, stderr.String())
+	}
+	if got.Action != cli.VaultRemove || got.Vault != "/tmp/vault" {
+		t.Fatalf("passwords options=%+v", got)
+	}
+}
+
+func TestRunWithIODispatchesVerifyCommand(t *testing.T) {
+	originalRunVerify := runVerify
+	defer func() {
+		runVerify = originalRunVerify
+	}()
+
+	dir := t.TempDir()
+	var got cli.VerifyOptions
+	runVerify = func(opts cli.VerifyOptions, _, _ io.Writer) error {
+		got = opts
+		return errors.New("1 of 1 manifest(s) failed verification")
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	exitCode := runWithIO([]string{"unrarall", "verify", dir}, &stdout, &stderr)
+	if exitCode != 1 {
+		t.Fatalf("runWithIO exit code=%d, want 1", exitCode)
+	}
+	if got.Dir != dir {
+		t.Fatalf("verify options=%+v, want Dir %q", got, dir)
+	}
+}
+
+func TestRunWithIODispatchesServeCommand(t *testing.T) {
+	originalRunServe := runServe
+	defer func() {
+		runServe = originalRunServe
+	}()
+
+	var got cli.ServeOptions
+	runServe = func(_ context.Context, opts cli.ServeOptions, _ *logpkg.Logger) error {
+		got = opts
+		return nil
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	exitCode := runWithIO([]string{"unrarall", "serve", "--listen", "127.0.0.1:9000", "--history", "5"}, &stdout, &stderr)
+	if exitCode != 0 {
+		t.Fatalf("runWithIO exit code=%d, want 0 (stderr=%q)", exitCode, stderr.String())
+	}
+	if got.Listen != "127.0.0.1:9000" || got.History != 5 {
+		t.Fatalf("serve options=%+v, want the listen address and history", got)
+	}
+
+	if exitCode := runWithIO([]string{"unrarall", "serve", "--listen", "0.0.0.0:9000"}, &stdout, &stderr); exitCode != 1 {
+		t.Fatalf("runWithIO exit code=%d for a public address without a token, want 1", exitCode)
+	}
+}
+
+func TestRunWithIODispatchesWatchCommand(t *testing.T) {
+	originalRunWatch := runWatch
+	defer func() {
+		runWatch = originalRunWatch
+	}()
+
+	dir := t.TempDir()
+	var got cli.WatchOptions
+	runWatch = func(_ context.Context, opts cli.WatchOptions, _ <-chan os.Signal, _ *logpkg.Logger) error {
+		got = opts
+		return nil
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	exitCode := runWithIO([]string{"unrarall", "watch", "--settle", "5s", "--force", dir}, &stdout, &stderr)
+	if exitCode != 0 {
+		t.Fatalf("runWithIO exit code=%d, want 0 (stderr=%q)", exitCode, stderr.String())
+	}
+	if got.Options.Dir != dir || got.Settle != 5*time.Second || !got.Options.Force {
+		t.Fatalf("watch options=%+v, want Dir %q, 5s settle, and --force", got, dir)
+	}
+
+	if exitCode := runWithIO([]string{"unrarall", "watch", "--dry", dir}, &stdout, &stderr); exitCode != 1 {
+		t.Fatalf("runWithIO exit code=%d for --dry, want 1", exitCode)
+	}
+}
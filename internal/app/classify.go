@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"syscall"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/rar"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// errNotRAR marks candidates that fail signature validation.
+var errNotRAR = errors.New("not a valid rar file")
+
+// classifyError groups a candidate failure for the run report. More
+// specific causes are checked first: a missing password file, for example,
+// is a password problem rather than a missing volume.
+func classifyError(err error) report.ErrorClass {
+	var (
+		passwordErr *PasswordRequiredError
+		verifyErr   *checksum.VerificationError
+		copyErr     *fsutil.CopyVerificationError
+		pathErr     *fs.PathError
+		errno       syscall.Errno
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return report.ClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return report.ClassTimeout
+	case errors.Is(err, errNotRAR):
+		return report.ClassNotRAR
+	case errors.As(err, &passwordErr) || rar.IsPasswordError(err):
+		return report.ClassPassword
+	case errors.As(err, &verifyErr):
+		return report.ClassChecksum
+	case errors.As(err, &copyErr):
+		return report.ClassCopyIntegrity
+	case errors.Is(err, rar.ErrUnsafeEntry):
+		return report.ClassUnsafeEntry
+	case rar.IsUnsupportedError(err):
+		return report.ClassUnsupported
+	case rar.IsCorruptionError(err):
+		return report.ClassCorrupt
+	case errors.Is(err, fs.ErrNotExist):
+		return report.ClassMissingVolume
+	case errors.Is(err, errNestedFailed):
+		return report.ClassNested
+	case errors.As(err, &pathErr) || errors.As(err, &errno):
+		return report.ClassIO
+	default:
+		return report.ClassUnknown
+	}
+}
+
+// fail records err on record, classified unless class is given.
+func fail(record *report.Candidate, class report.ErrorClass, err error) {
+	if class == "" {
+		class = classifyError(err)
+	}
+	record.Error = &report.Error{Class: class, Message: err.Error()}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/rar"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/nwaples/rardecode/v2"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want report.ErrorClass
+	}{
+		{name: "not rar", err: errNotRAR, want: report.ClassNotRAR},
+		{name: "password required", err: &PasswordRequiredError{Cause: os.ErrNotExist}, want: report.ClassPassword},
+		{name: "bad password", err: fmt.Errorf("decode: %w", rardecode.ErrBadPassword), want: report.ClassPassword},
+		{name: "checksum", err: &checksum.VerificationError{Missing: []string{"set.r00"}}, want: report.ClassChecksum},
+		{name: "copy integrity", err: &fsutil.CopyVerificationError{Err: errors.New("mismatch")}, want: report.ClassCopyIntegrity},
+		{name: "unsafe entry", err: fmt.Errorf("extract: %w", rar.ErrUnsafeEntry), want: report.ClassUnsafeEntry},
+		{name: "unsupported", err: rardecode.ErrDictionaryTooLarge, want: report.ClassUnsupported},
+		{name: "corrupt", err: rardecode.ErrBadFileChecksum, want: report.ClassCorrupt},
+		{name: "missing volume", err: &fs.PathError{Op: "open", Path: "set.r00", Err: fs.ErrNotExist}, want: report.ClassMissingVolume},
+		{name: "nested", err: errNestedFailed, want: report.ClassNested},
+		{name: "io", err: &fs.PathError{Op: "write", Path: "movie.mkv", Err: syscall.ENOSPC}, want: report.ClassIO},
+		{name: "unknown", err: errors.New("boom"), want: report.ClassUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := classifyError(tt.err); got != tt.want {
+				t.Fatalf("classifyError(%v)=%q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
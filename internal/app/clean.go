@@ -5,6 +5,18 @@ import (
 	"github.com/arodd/go-unrarall/internal/log"
 )
 
+// forgetRemoved wraps onAction so files removed by cleanup hooks are
+// dropped from the checksum cache too; the cache does not drop files it
+// merely cannot find.
+func (r *runner) forgetRemoved(onAction func(hooks.Action)) func(hooks.Action) {
+	return func(action hooks.Action) {
+		if action.Kind == "remove_file" && !action.DryRun {
+			r.checksums.Forget(action.Path)
+		}
+		onAction(action)
+	}
+}
+
 func shouldRunHooks(selected []string) bool {
 	return !(len(selected) == 0 || (len(selected) == 1 && selected[0] == "none"))
 }
@@ -15,6 +27,7 @@ func runCleanupHooks(
 	rarDir string,
 	stem string,
 	dryRun bool,
+	onAction func(hooks.Action),
 	logger *log.Logger,
 ) error {
 	return hooks.Run(selected, hooks.Context{
@@ -23,5 +36,6 @@ func runCleanupHooks(
 		Stem:        stem,
 		DryRun:      dryRun,
 		Log:         logger,
+		OnAction:    onAction,
 	})
 }
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/hooks"
+	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/arodd/go-unrarall/internal/webhook"
+)
+
+var runEventCommand = func(ctx context.Context, command hooks.EventCommand, event hooks.Event) error {
+	return command.Run(ctx, event)
+}
+
+// runEventCommands runs the --on-* commands for a top-level candidate whose
+// record is finished. A failing command fails an extracted candidate; stats
+// are adjusted to match.
+func (r *runner) runEventCommands(candidate finder.Candidate, record *report.Candidate, stats Stats) Stats {
+	run := func(flag, command, name string) {
+		if command == "" {
+			return
+		}
+		event := hooks.Event{
+			Name:        name,
+			Archive:     candidate.Path,
+			Stem:        candidate.Stem,
+			Destination: destinationRoot(r.opts.OutputDir, filepath.Dir(candidate.Path)),
+			Status:      string(record.Outcome),
+		}
+		for _, file := range record.Files {
+			event.Files = append(event.Files, file.Path)
+		}
+		if record.Error != nil {
+			event.ErrorClass = string(record.Error.Class)
+			event.Error = record.Error.Message
+		}
+
+		// Output goes through the run's logger, so it keeps the candidate
+		// prefix and follows --quiet, --log-file, and serve job streams.
+		output := r.log.WithPrefix(flag + ": ")
+		stdout, stderr := output.LineWriter(log.LevelInfo), output.LineWriter(log.LevelError)
+		err := runEventCommand(r.ctx, hooks.EventCommand{
+			Command: command,
+			Timeout: r.opts.CommandTimeout,
+			Stdout:  stdout,
+			Stderr:  stderr,
+		}, event)
+		stdout.Close()
+		stderr.Close()
+		if err == nil {
+			return
+		}
+		r.log.Errorf("Command %s failed for %q: %v", flag, candidate.Path, err)
+		if record.Outcome != report.Extracted {
+			return
+		}
+		fail(record, report.ClassCommand, fmt.Errorf("%s: %w", flag, err))
+		record.Finish(report.Failed)
+		stats.ArchivesExtracted--
+		stats.Failures++
+	}
+
+	switch record.Outcome {
+	case report.Extracted:
+		run("--on-extracted", r.opts.OnExtracted, "extracted")
+	case report.Failed:
+		run("--on-failed", r.opts.OnFailed, "failed")
+	}
+	run("--on-finished", r.opts.OnFinished, "finished")
+	return stats
+}
+
+// notifyWebhook queues a --webhook event for an extracted or failed
+// top-level candidate. Skipped sets send none.
+func (r *runner) notifyWebhook(record *report.Candidate) {
+	event := webhook.Event{Dir: r.opts.Dir, Candidate: record}
+	switch record.Outcome {
+	case report.Extracted:
+		event.Type = webhook.CandidateExtracted
+	case report.Failed:
+		event.Type = webhook.CandidateFailed
+	default:
+		return
+	}
+	r.webhook.Send(event)
+}
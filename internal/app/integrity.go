@@ -0,0 +1,105 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/rar"
+)
+
+// logChecksumFailure logs the detail lines for a failed checksum
+// verification of candidate, after the caller's one-line summary.
+func (r *runner) logChecksumFailure(candidate finder.Candidate, err error) {
+	for _, line := range checksumFailureDetails(candidate, err) {
+		r.log.Errorf("  %s", line)
+	}
+}
+
+// checksumFailureDetails names every missing and mismatched file in err.
+// Files that are volumes of candidate's set are annotated with the archive
+// entries that may have data in them, and the report ends with the entries
+// no bad volume touches, which --force can still extract intact.
+func checksumFailureDetails(candidate finder.Candidate, err error) []string {
+	var verificationErr *checksum.VerificationError
+	if !errors.As(err, &verificationErr) {
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("manifest %q (%s)", verificationErr.Manifest, verificationErr.Algorithm)}
+
+	firstVolume := filepath.Base(candidate.Path)
+	type badFile struct {
+		name   string
+		detail string
+	}
+	bad := make([]badFile, 0, len(verificationErr.Missing)+len(verificationErr.Mismatches))
+	for _, name := range verificationErr.Missing {
+		bad = append(bad, badFile{name: name, detail: "missing"})
+	}
+	for _, mismatch := range verificationErr.Mismatches {
+		bad = append(bad, badFile{
+			name:   mismatch.Name,
+			detail: fmt.Sprintf("mismatch: expected %s, got %s", mismatch.Expected, mismatch.Actual),
+		})
+	}
+
+	var (
+		volumeMap  rar.VolumeMap
+		mapErr     error
+		mapped     bool
+		badVolumes = make(map[int]bool)
+	)
+	for _, file := range bad {
+		base := filepath.Base(checksum.NormalizeEntryName(file.name))
+		if !finder.IsSetVolume(firstVolume, base) {
+			lines = append(lines, fmt.Sprintf("%s: %s", file.name, file.detail))
+			continue
+		}
+		if !mapped {
+			volumeMap, mapErr = mapArchiveVolumes(candidate.Path)
+			mapped = true
+		}
+
+		index := volumeMap.VolumeIndex(base)
+		switch {
+		case mapErr != nil:
+			lines = append(lines, fmt.Sprintf("%s: %s (volume of this set; entries unknown: %v)", file.name, file.detail, mapErr))
+		case index < 0:
+			lines = append(lines, fmt.Sprintf("%s: %s (volume of this set; not reached while listing entries)", file.name, file.detail))
+		default:
+			badVolumes[index] = true
+			lines = append(lines, fmt.Sprintf("%s: %s (volume %d of this set; entries that may span it: %s)",
+				file.name, file.detail, index+1, joinNames(volumeMap.EntriesIn(index))))
+		}
+	}
+	if !mapped || mapErr != nil {
+		return lines
+	}
+
+	if volumeMap.Err != nil {
+		lines = append(lines, fmt.Sprintf("listing stopped after %d volume(s): %v; later entries are unknown", len(volumeMap.Volumes), volumeMap.Err))
+	}
+	unaffected := make([]string, 0, len(volumeMap.Entries))
+	for _, entry := range volumeMap.Entries {
+		touched := false
+		for i := entry.FirstVolume; i <= entry.LastVolume; i++ {
+			touched = touched || badVolumes[i]
+		}
+		if !touched {
+			unaffected = append(unaffected, entry.Name)
+		}
+	}
+	lines = append(lines, fmt.Sprintf("entries clear of bad volumes (recoverable with --force): %s", joinNames(unaffected)))
+	return lines
+}
+
+func joinNames(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+var sourceDevice = fsutil.DeviceOf
+
+// placement orders destination changes between concurrent candidates:
+// moves and manifest writes hold it shared, cleanup hooks exclusively, so
+// empty_folders cannot prune a directory another candidate is moving into.
+type placement struct {
+	mu       sync.RWMutex
+	manifest sync.Mutex
+}
+
+// runConcurrently processes candidates with up to r.jobs workers. A worker
+// takes the first pending candidate whose source device is below its limit,
+// so sets on one spinning disk are read one at a time while other devices
+// stay busy. Each candidate runs on its own runner with prefixed log lines
+// and processes nested archives sequentially. A fatal error, a done
+// context, or the --max-runtime deadline stops further dispatch. Candidates
+// already running finish first, except that a done context rolls back those
+// whose move has not completed.
+func (r *runner) runConcurrently(candidates []finder.Candidate, depth int) (Stats, error) {
+	devices := make([]string, len(candidates))
+	limits := make(map[string]int)
+	for i, candidate := range candidates {
+		device, err := sourceDevice(filepath.Dir(candidate.Path))
+		if err != nil {
+			r.log.Verbosef("Could not identify the device holding %q, limiting it to one job: %v", candidate.Path, err)
+		}
+		devices[i] = device.ID
+		limits[device.ID] = r.jobsPerDevice(device)
+	}
+
+	var (
+		mu       sync.Mutex
+		ready    = sync.NewCond(&mu)
+		pending  = make([]int, len(candidates))
+		active   = make(map[string]int)
+		stats    Stats
+		firstErr error
+	)
+	for i := range pending {
+		pending[i] = i
+	}
+
+	next := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		for {
+			if firstErr != nil || len(pending) == 0 || r.ctx.Err() != nil || r.pastDeadline() {
+				return 0, false
+			}
+			for i, index := range pending {
+				if active[devices[index]] < limits[devices[index]] {
+					pending = append(pending[:i], pending[i+1:]...)
+					active[devices[index]]++
+					return index, true
+				}
+			}
+			ready.Wait()
+		}
+	}
+	done := func(index int, candidateStats Stats, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		active[devices[index]]--
+		stats.add(candidateStats)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		ready.Broadcast()
+	}
+
+	var wg sync.WaitGroup
+	for range min(r.jobs, len(candidates)) {
+		wg.Go(func() {
+			for {
+				index, ok := next()
+				if !ok {
+					return
+				}
+				candidate := candidates[index]
+				job := *r
+				job.jobs = 1
+				job.log = r.log.WithPrefix(fmt.Sprintf("[%s] ", candidate.Stem))
+				candidateStats, err := job.processCandidate(candidate, depth)
+				done(index, candidateStats, err)
+			}
+		})
+	}
+	wg.Wait()
+	if firstErr == nil && len(pending) > 0 {
+		firstErr = r.ctx.Err()
+		if firstErr == nil {
+			r.logDeadline(len(pending))
+		}
+	}
+	return stats, firstErr
+}
+
+// jobsPerDevice returns how many candidates on device may run at once.
+func (r *runner) jobsPerDevice(device fsutil.Device) int {
+	switch {
+	case r.opts.JobsPerDevice > 0:
+		return r.opts.JobsPerDevice
+	case device.SolidState:
+		return r.jobs
+	default:
+		return 1
+	}
+}
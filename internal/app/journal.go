@@ -0,0 +1,103 @@
+package app
+
+import (
+	"encoding/hex"
+	"path/filepath"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/journal"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// skipFromJournal reports whether the journal records candidate's set as
+// extracted from volumes that are unchanged since.
+func (r *runner) skipFromJournal(candidate finder.Candidate) bool {
+	entry, ok := r.journal.Lookup(candidate.Path)
+	if !ok || entry.Outcome != journal.Extracted {
+		return false
+	}
+	if err := entry.CheckVolumes(); err != nil {
+		r.log.Verbosef("Journal entry for %q no longer applies: %v", candidate.Path, err)
+		return false
+	}
+	r.log.Infof("File %q was already extracted to %q on %s (journal), skipping.", candidate.Path, entry.Destination, entry.Time.Local().Format("2006-01-02 15:04"))
+	return true
+}
+
+// pendingJournal holds the journal entry of the candidate a runner copy
+// processes until its outcome is final; see processCandidate.
+type pendingJournal struct {
+	entry *journal.Entry
+}
+
+// recordJournal prepares the journal entry for an extraction attempt of
+// candidate. Volumes are those the decoder opened, or the first volume when
+// it failed before reporting any; they are identified now, before cleanup
+// hooks remove them. The entry is appended by finishJournal once cleanup
+// hooks and --on-* commands have settled the outcome.
+func (r *runner) recordJournal(candidate finder.Candidate, destRoot string, result PasswordExtractionResult, algorithm checksum.Algorithm, placed []placedFile, extractErr error) {
+	if r.journal == nil {
+		return
+	}
+
+	dir := filepath.Dir(candidate.Path)
+	names := result.Volumes
+	if len(names) == 0 {
+		names = []string{filepath.Base(candidate.Path)}
+	}
+	entry := journal.Entry{
+		Set:         candidate.Path,
+		Destination: destRoot,
+		Outcome:     journal.Extracted,
+	}
+	for _, name := range names {
+		volume, err := journal.StatVolume(dir, filepath.Base(name))
+		if err != nil {
+			r.log.Verbosef("Journal cannot identify volume %q: %v", name, err)
+			continue
+		}
+		if sum, ok := result.VolumeSums[filepath.Join(dir, volume.Name)]; ok {
+			volume.Sum = string(algorithm) + ":" + hex.EncodeToString(sum)
+		}
+		entry.Volumes = append(entry.Volumes, volume)
+	}
+	for _, file := range placed {
+		entry.Files = append(entry.Files, file.Dest)
+	}
+	if extractErr != nil {
+		entry.Outcome = journal.Failed
+		entry.Error = extractErr.Error()
+	}
+
+	if r.pending != nil {
+		r.pending.entry = &entry
+		return
+	}
+	r.appendJournal(entry)
+}
+
+// finishJournal appends the entry recordJournal prepared for the candidate
+// of record, if any, with the record's final outcome: a set failed by its
+// cleanup hooks or an --on-* command is journaled as failed, so
+// --skip=journal tries it again.
+func (r *runner) finishJournal(record *report.Candidate) {
+	if r.journal == nil || r.pending == nil || r.pending.entry == nil {
+		return
+	}
+	entry := *r.pending.entry
+	r.pending.entry = nil
+	if record.Outcome == report.Failed && entry.Outcome != journal.Failed {
+		entry.Outcome = journal.Failed
+		if record.Error != nil {
+			entry.Error = record.Error.Message
+		}
+	}
+	r.appendJournal(entry)
+}
+
+func (r *runner) appendJournal(entry journal.Entry) {
+	if err := r.journal.Append(entry); err != nil {
+		r.log.Errorf("Failed to update journal: %v", err)
+	}
+}
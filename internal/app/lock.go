@@ -0,0 +1,65 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/lock"
+	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// skipBusy is the skip reason of sets another run holds locked.
+const skipBusy = "busy"
+
+// lockRun takes the run lock on opts.Dir. Dry runs and --no-lock take
+// none. A tree where the lock file cannot be created is processed without
+// it; only another run holding the lock is an error.
+func lockRun(opts cli.Options, logger *log.Logger) (*lock.Lock, error) {
+	if opts.DryRun || opts.NoLock {
+		return nil, nil
+	}
+	l, err := acquireRunLock(opts.Dir)
+	if errors.Is(err, lock.ErrBusy) {
+		return nil, fmt.Errorf("another run is processing %q: %w", opts.Dir, err)
+	}
+	if err != nil {
+		logger.Errorf("Run lock unavailable, continuing without it: %v", err)
+		return nil, nil
+	}
+	return l, nil
+}
+
+func releaseLock(l *lock.Lock, logger *log.Logger) {
+	if err := l.Release(); err != nil {
+		logger.Errorf("Failed to release lock %q: %v", l.Path(), err)
+	}
+}
+
+// extractLocked extracts a top-level candidate while holding its set lock,
+// so runs sharing the tree never extract or clean the same set at once.
+// A set locked by another run is skipped as busy.
+func (r *runner) extractLocked(candidate finder.Candidate, depth int, record *report.Candidate) (Stats, error) {
+	if r.parent != "" || r.opts.DryRun || r.opts.NoLock {
+		return r.extractRetrying(candidate, depth, record)
+	}
+	l, err := acquireSetLock(candidate.Path)
+	var busy *lock.BusyError
+	switch {
+	case errors.As(err, &busy):
+		if busy.Owner.PID != 0 {
+			r.log.Infof("Skipping %q because another run is extracting it (%s).", candidate.Path, busy.Owner)
+		} else {
+			r.log.Infof("Skipping %q because another run is extracting it.", candidate.Path)
+		}
+		record.SkipReason = skipBusy
+		return Stats{ArchivesFound: 1, ArchivesSkipped: 1}, nil
+	case err != nil:
+		r.log.Verbosef("Set lock unavailable for %q, extracting without it: %v", candidate.Path, err)
+		return r.extractRetrying(candidate, depth, record)
+	}
+	defer releaseLock(l, r.log)
+	return r.extractRetrying(candidate, depth, record)
+}
@@ -1,21 +1,60 @@
 package app
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
-	"os"
-	"strings"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/passwords"
 	"github.com/arodd/go-unrarall/internal/rar"
 )
 
+// ExtractRequest describes one archive extraction, including the password
+// sources tried when the archive turns out to be encrypted.
+type ExtractRequest struct {
+	ArchivePath   string
+	TmpDir        string
+	FullPath      bool
+	MaxDictBytes  int64
+	AllowSymlinks bool
+	// PasswordFile is the global password file, named in password errors.
+	PasswordFile string
+	Passwords    passwords.Chain
+	// Store, when set, reorders candidates so remembered and frequently
+	// successful passwords are tried first.
+	Store *passwords.Store
+	// Prompt, when set, is asked for a password after every candidate from
+	// Passwords failed.
+	Prompt passwords.Prompter
+	// HashAlgorithm, when set, hashes volumes with a checksum.HashingFS
+	// while they are extracted; the sums are returned in VolumeSums.
+	HashAlgorithm checksum.Algorithm
+	// FileHashAlgorithm, when set, hashes extracted files as they are
+	// written; the sums are returned in FileSums.
+	FileHashAlgorithm checksum.Algorithm
+	// Context, when set, stops extraction once done. No further passwords
+	// are tried and the context's error is returned.
+	Context context.Context
+}
+
 // PasswordExtractionResult captures password retry metadata for a successful
 // extraction attempt.
 type PasswordExtractionResult struct {
-	Volumes      []string
-	UsedPassword bool
-	Password     string
+	Volumes        []string
+	UsedPassword   bool
+	Password       string
+	PasswordSource string
+	// PasswordAttempts counts the passwords tried, the working one
+	// included. It is also set when extraction fails.
+	PasswordAttempts int
+	// VolumeSums holds whole-volume sums from the successful extraction,
+	// keyed by absolute path, when HashAlgorithm was set.
+	VolumeSums map[string][]byte
+	// FileSums holds sums of the extracted files, keyed by path relative to
+	// TmpDir, when FileHashAlgorithm was set.
+	FileSums map[string][]byte
 }
 
 // PasswordRequiredError indicates that an archive is encrypted and no password
@@ -44,108 +83,179 @@ type archiveExtractorWithSettings func(
 	settings rar.OpenSettings,
 ) ([]string, error)
 
-// ExtractArchiveWithPasswords extracts archivePath into tmpDir, retrying with
-// passwords from passwordFile if the archive is encrypted.
-func ExtractArchiveWithPasswords(
-	archivePath string,
-	tmpDir string,
-	fullPath bool,
-	maxDictBytes int64,
-	allowSymlinks bool,
-	passwordFile string,
-) (PasswordExtractionResult, error) {
-	return extractArchiveWithPasswords(
-		rar.ExtractToDirWithSettings,
-		archivePath,
-		tmpDir,
-		fullPath,
-		maxDictBytes,
-		allowSymlinks,
-		passwordFile,
-	)
+type passwordChecker func(archivePath string, settings rar.OpenSettings) error
+
+// ExtractArchiveWithPasswords extracts req.ArchivePath into req.TmpDir,
+// retrying with passwords from req.Passwords if the archive is encrypted.
+// Each candidate is checked cheaply first; only a password that passes the
+// check is used for a full extraction, which always starts from an empty
+// temp directory.
+func ExtractArchiveWithPasswords(req ExtractRequest) (PasswordExtractionResult, error) {
+	return extractArchiveWithPasswords(rar.ExtractToDirWithSettings, rar.CheckPassword, req)
 }
 
 func extractArchiveWithPasswords(
 	extract archiveExtractorWithSettings,
-	archivePath string,
-	tmpDir string,
-	fullPath bool,
-	maxDictBytes int64,
-	allowSymlinks bool,
-	passwordFile string,
+	check passwordChecker,
+	req ExtractRequest,
 ) (PasswordExtractionResult, error) {
 	settings := rar.OpenSettings{
-		MaxDictionaryBytes: maxDictBytes,
-		AllowSymlinks:      allowSymlinks,
+		MaxDictionaryBytes: req.MaxDictBytes,
+		AllowSymlinks:      req.AllowSymlinks,
+		Context:            req.Context,
+	}
+
+	// extractOnce runs one full extraction, hashing volumes and extracted
+	// files on the way through when requested. Each attempt gets fresh
+	// hashers so only the successful attempt's sums are reported.
+	extractOnce := func(settings rar.OpenSettings) (PasswordExtractionResult, error) {
+		var hashing *checksum.HashingFS
+		if req.HashAlgorithm != "" {
+			hashing = checksum.NewHashingFS(req.HashAlgorithm)
+			settings.FileSystem = hashing
+		}
+		if req.FileHashAlgorithm != "" {
+			settings.FileSums = checksum.NewFileSums(req.FileHashAlgorithm)
+		}
+		volumes, err := extract(req.ArchivePath, req.TmpDir, req.FullPath, settings)
+		if err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		result := PasswordExtractionResult{Volumes: volumes}
+		if hashing != nil {
+			result.VolumeSums = hashing.Sums()
+		}
+		if settings.FileSums != nil {
+			result.FileSums = settings.FileSums.Sums()
+		}
+		return result, nil
 	}
 
-	volumes, err := extract(archivePath, tmpDir, fullPath, settings)
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result, err := extractOnce(settings)
 	if err == nil {
-		return PasswordExtractionResult{Volumes: volumes}, nil
+		return result, nil
 	}
 	if !rar.IsPasswordError(err) {
 		return PasswordExtractionResult{}, err
 	}
 
-	passwords, loadErr := readPasswordFile(passwordFile)
-	if loadErr != nil {
-		return PasswordExtractionResult{}, &PasswordRequiredError{
-			ArchivePath:  archivePath,
-			PasswordFile: passwordFile,
-			Cause:        loadErr,
-		}
-	}
-	if len(passwords) == 0 {
-		return PasswordExtractionResult{}, &PasswordRequiredError{
-			ArchivePath:  archivePath,
-			PasswordFile: passwordFile,
-			Cause:        errors.New("password file is empty"),
-		}
-	}
+	target := passwords.TargetFor(req.ArchivePath)
+	target.Context = ctx
+	attempts := 0
 
+	// try reports done=true when the attempt settled the archive, either by
+	// succeeding or by failing for a reason no other password can fix.
 	lastErr := err
-	for _, password := range passwords {
-		settings.Password = password
+	try := func(candidate passwords.Candidate) (result PasswordExtractionResult, done bool, err error) {
+		attempts++
+		settings.Password = candidate.Password
+
+		if checkErr := check(req.ArchivePath, settings); checkErr != nil {
+			if !rar.IsPasswordError(checkErr) {
+				return PasswordExtractionResult{}, true, checkErr
+			}
+			lastErr = checkErr
+			return PasswordExtractionResult{}, false, nil
+		}
 
-		volumes, tryErr := extract(archivePath, tmpDir, fullPath, settings)
+		// The unencrypted attempt or an earlier false-positive check may
+		// have left partial entries behind.
+		if err := fsutil.ResetDir(req.TmpDir); err != nil {
+			return PasswordExtractionResult{}, true, fmt.Errorf("reset temp directory %q: %w", req.TmpDir, err)
+		}
+
+		result, tryErr := extractOnce(settings)
 		if tryErr == nil {
-			return PasswordExtractionResult{
-				Volumes:      volumes,
-				UsedPassword: true,
-				Password:     password,
-			}, nil
+			result.UsedPassword = true
+			result.Password = candidate.Password
+			result.PasswordSource = candidate.Source
+			result.PasswordAttempts = attempts
+			return result, true, nil
 		}
-		if !rar.IsPasswordError(tryErr) {
-			return PasswordExtractionResult{}, tryErr
+		// A wrong password can pass the check and still fail the stored
+		// checksum once the whole entry is decoded.
+		if !rar.IsPasswordError(tryErr) && !rar.IsChecksumError(tryErr) {
+			return PasswordExtractionResult{}, true, tryErr
 		}
 		lastErr = tryErr
+		return PasswordExtractionResult{}, false, nil
 	}
 
-	return PasswordExtractionResult{}, lastErr
-}
+	// Providers are resolved one at a time, and the store orders each one's
+	// passwords, so later sources only run when earlier ones fail. The
+	// provider that last opened the set goes first, so its remembered
+	// password is tried before the rest of the chain runs.
+	var (
+		settled    PasswordExtractionResult
+		done       bool
+		settledErr error
+	)
+	loadErr := req.Passwords.Prefer(req.Store.Source(target)).Resolve(target, func(batch []passwords.Candidate) bool {
+		for _, candidate := range req.Store.Order(target, batch) {
+			if settled, done, settledErr = try(candidate); done {
+				return false
+			}
+		}
+		return ctx.Err() == nil
+	})
+	if done {
+		if settledErr != nil {
+			return failPasswordAttempts(req.TmpDir, attempts, settledErr)
+		}
+		return settled, nil
+	}
+	if attempts == 0 && req.Prompt == nil {
+		return failPasswordAttempts(req.TmpDir, attempts, missingPasswordError(req, loadErr))
+	}
 
-func readPasswordFile(path string) ([]string, error) {
-	if strings.TrimSpace(path) == "" {
-		return nil, fmt.Errorf("password file path is empty")
+	if req.Prompt != nil {
+		for attempt := 1; attempt <= passwords.MaxPromptAttempts; attempt++ {
+			password, promptErr := req.Prompt.Prompt(ctx, target, attempt)
+			if promptErr != nil {
+				return failPasswordAttempts(req.TmpDir, attempts, errors.Join(lastErr, fmt.Errorf("password prompt: %w", promptErr)))
+			}
+			if password == "" {
+				break
+			}
+			result, done, err := try(passwords.Candidate{Password: password, Source: passwords.PromptSource})
+			if done {
+				if err != nil {
+					return failPasswordAttempts(req.TmpDir, attempts, err)
+				}
+				return result, nil
+			}
+		}
+		if attempts == 0 {
+			return failPasswordAttempts(req.TmpDir, attempts, missingPasswordError(req, loadErr))
+		}
 	}
 
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	return failPasswordAttempts(req.TmpDir, attempts, lastErr)
+}
+
+func missingPasswordError(req ExtractRequest, loadErr error) error {
+	cause := loadErr
+	if cause == nil {
+		cause = errors.New("password sources are empty")
 	}
-	defer file.Close()
-
-	passwords := make([]string, 0, 8)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimRight(scanner.Text(), "\r")
-		if line == "" {
-			continue
-		}
-		passwords = append(passwords, line)
+	return &PasswordRequiredError{
+		ArchivePath:  req.ArchivePath,
+		PasswordFile: req.PasswordFile,
+		Cause:        cause,
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+}
+
+// failPasswordAttempts empties tmpDir so output from failed password attempts
+// is never moved into the destination, then returns err with the number of
+// passwords tried.
+func failPasswordAttempts(tmpDir string, attempts int, err error) (PasswordExtractionResult, error) {
+	result := PasswordExtractionResult{PasswordAttempts: attempts}
+	if resetErr := fsutil.ResetDir(tmpDir); resetErr != nil {
+		return result, errors.Join(err, fmt.Errorf("reset temp directory %q: %w", tmpDir, resetErr))
 	}
-	return passwords, nil
+	return result, err
 }
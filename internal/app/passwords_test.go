@@ -1,13 +1,17 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/passwords"
 	"github.com/arodd/go-unrarall/internal/rar"
 	"github.com/nwaples/rardecode/v2"
 )
@@ -31,15 +35,15 @@ func TestExtractArchiveWithPasswordsUnencrypted(t *testing.T) {
 		return []string{"release.rar"}, nil
 	}
 
-	result, err := extractArchiveWithPasswords(
-		extract,
-		"/archives/release.rar",
-		t.TempDir(),
-		true,
-		1<<20,
-		false,
-		"/unused/passwords.txt",
-	)
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:   "/archives/release.rar",
+		TmpDir:        t.TempDir(),
+		FullPath:      true,
+		MaxDictBytes:  1 << 20,
+		AllowSymlinks: false,
+		PasswordFile:  "/unused/passwords.txt",
+		Passwords:     passwords.Chain{passwords.FileProvider{Path: "/unused/passwords.txt"}},
+	})
 	if err != nil {
 		t.Fatalf("extractArchiveWithPasswords returned error: %v", err)
 	}
@@ -84,15 +88,15 @@ func TestExtractArchiveWithPasswordsRetriesPasswordFile(t *testing.T) {
 		}
 	}
 
-	result, err := extractArchiveWithPasswords(
-		extract,
-		"/archives/release.part01.rar",
-		t.TempDir(),
-		false,
-		1<<21,
-		true,
-		passwordFile,
-	)
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:   "/archives/release.part01.rar",
+		TmpDir:        t.TempDir(),
+		FullPath:      false,
+		MaxDictBytes:  1 << 21,
+		AllowSymlinks: true,
+		PasswordFile:  passwordFile,
+		Passwords:     passwords.Chain{passwords.FileProvider{Path: passwordFile}},
+	})
 	if err != nil {
 		t.Fatalf("extractArchiveWithPasswords returned error: %v", err)
 	}
@@ -103,6 +107,9 @@ func TestExtractArchiveWithPasswordsRetriesPasswordFile(t *testing.T) {
 	if result.Password != "secret" {
 		t.Fatalf("password=%q, want %q", result.Password, "secret")
 	}
+	if result.PasswordAttempts != 2 {
+		t.Fatalf("PasswordAttempts=%d, want 2", result.PasswordAttempts)
+	}
 	wantVolumes := []string{"release.part01.rar", "release.part02.rar"}
 	if !reflect.DeepEqual(result.Volumes, wantVolumes) {
 		t.Fatalf("volumes=%v, want %v", result.Volumes, wantVolumes)
@@ -125,15 +132,16 @@ func TestExtractArchiveWithPasswordsMissingPasswordFile(t *testing.T) {
 		return nil, rardecode.ErrArchiveEncrypted
 	}
 
-	_, err := extractArchiveWithPasswords(
-		extract,
-		"/archives/release.rar",
-		t.TempDir(),
-		true,
-		1<<20,
-		false,
-		filepath.Join(t.TempDir(), "missing.txt"),
-	)
+	passwordFile := filepath.Join(t.TempDir(), "missing.txt")
+	_, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:   "/archives/release.rar",
+		TmpDir:        t.TempDir(),
+		FullPath:      true,
+		MaxDictBytes:  1 << 20,
+		AllowSymlinks: false,
+		PasswordFile:  passwordFile,
+		Passwords:     passwords.Chain{passwords.FileProvider{Path: passwordFile}},
+	})
 	if err == nil {
 		t.Fatal("expected password required error")
 	}
@@ -160,15 +168,15 @@ func TestExtractArchiveWithPasswordsEmptyPasswordFile(t *testing.T) {
 		return nil, rardecode.ErrArchiveEncrypted
 	}
 
-	_, err := extractArchiveWithPasswords(
-		extract,
-		"/archives/release.rar",
-		t.TempDir(),
-		true,
-		1<<20,
-		false,
-		passwordFile,
-	)
+	_, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:   "/archives/release.rar",
+		TmpDir:        t.TempDir(),
+		FullPath:      true,
+		MaxDictBytes:  1 << 20,
+		AllowSymlinks: false,
+		PasswordFile:  passwordFile,
+		Passwords:     passwords.Chain{passwords.FileProvider{Path: passwordFile}},
+	})
 	if err == nil {
 		t.Fatal("expected password required error")
 	}
@@ -190,35 +198,394 @@ func TestExtractArchiveWithPasswordsPassesThroughNonPasswordError(t *testing.T)
 		return nil, expected
 	}
 
-	_, err := extractArchiveWithPasswords(
-		extract,
-		"/archives/release.rar",
-		t.TempDir(),
-		true,
-		1<<20,
-		false,
-		"/unused",
-	)
+	_, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:   "/archives/release.rar",
+		TmpDir:        t.TempDir(),
+		FullPath:      true,
+		MaxDictBytes:  1 << 20,
+		AllowSymlinks: false,
+		PasswordFile:  "/unused",
+		Passwords:     passwords.Chain{passwords.FileProvider{Path: "/unused"}},
+	})
 	if !errors.Is(err, expected) {
 		t.Fatalf("error=%v, want %v", err, expected)
 	}
 }
 
-func TestReadPasswordFile(t *testing.T) {
+func TestExtractArchiveWithPasswordsTriesProvidersInChainOrder(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	passwordFile := filepath.Join(root, "passwords.txt")
+	if err := os.WriteFile(passwordFile, []byte("global\n"), 0o644); err != nil {
+		t.Fatalf("write password file: %v", err)
+	}
+
+	var tried []string
+	extract := func(_ string, _ string, _ bool, settings rar.OpenSettings) ([]string, error) {
+		tried = append(tried, settings.Password)
+		if settings.Password == "global" {
+			return []string{"release.rar"}, nil
+		}
+		return nil, rardecode.ErrBadPassword
+	}
+
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:  filepath.Join(root, "release{{inline}}.rar"),
+		TmpDir:       t.TempDir(),
+		MaxDictBytes: 1 << 20,
+		PasswordFile: passwordFile,
+		Passwords: passwords.Chain{
+			passwords.FilenameProvider{},
+			passwords.FileProvider{Path: passwordFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("extractArchiveWithPasswords returned error: %v", err)
+	}
+
+	if want := []string{"", "inline", "global"}; !reflect.DeepEqual(tried, want) {
+		t.Fatalf("tried=%v, want %v", tried, want)
+	}
+	if got, want := result.PasswordSource, (passwords.FileProvider{Path: passwordFile}).Name(); got != want {
+		t.Fatalf("PasswordSource=%q, want %q", got, want)
+	}
+}
+
+func TestExtractArchiveWithPasswordsSkipsLaterProvidersOncePasswordWorks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	root := t.TempDir()
+	marker := filepath.Join(root, "command-ran")
+	extract := func(_ string, _ string, _ bool, settings rar.OpenSettings) ([]string, error) {
+		if settings.Password == "inline" {
+			return []string{"release.rar"}, nil
+		}
+		return nil, rardecode.ErrBadPassword
+	}
+
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:  filepath.Join(root, "release{{inline}}.rar"),
+		TmpDir:       t.TempDir(),
+		MaxDictBytes: 1 << 20,
+		Passwords: passwords.Chain{
+			passwords.FilenameProvider{},
+			passwords.CommandProvider{Command: "touch '" + marker + "'"},
+		},
+	})
+	if err != nil || result.Password != "inline" {
+		t.Fatalf("password=%q err=%v, want the name's password", result.Password, err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("password command ran although the name's password worked: %v", err)
+	}
+}
+
+func TestExtractArchiveWithPasswordsTriesRememberedSourceFirst(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	root := t.TempDir()
+	marker := filepath.Join(root, "command-ran")
+	passwordFile := filepath.Join(root, "passwords.txt")
+	if err := os.WriteFile(passwordFile, []byte("other\nsecret\n"), 0o600); err != nil {
+		t.Fatalf("write password file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, passwords.SidecarFileName), []byte("sidecar\n"), 0o600); err != nil {
+		t.Fatalf("write sidecar: %v", err)
+	}
+	archivePath := filepath.Join(root, "release.rar")
+	fileProvider := passwords.FileProvider{Path: passwordFile}
+	store, err := passwords.OpenStore(filepath.Join(t.TempDir(), passwords.StoreFileName))
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	if err := store.Remember(passwords.TargetFor(archivePath), passwords.Candidate{Password: "secret", Source: fileProvider.Name()}); err != nil {
+		t.Fatalf("Remember returned error: %v", err)
+	}
+
+	var tried []string
+	extract := func(_ string, _ string, _ bool, settings rar.OpenSettings) ([]string, error) {
+		tried = append(tried, settings.Password)
+		if settings.Password == "secret" {
+			return []string{"release.rar"}, nil
+		}
+		return nil, rardecode.ErrBadPassword
+	}
+
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:  archivePath,
+		TmpDir:       t.TempDir(),
+		MaxDictBytes: 1 << 20,
+		PasswordFile: passwordFile,
+		Store:        store,
+		Passwords: passwords.Chain{
+			passwords.SidecarProvider{FileName: passwords.SidecarFileName},
+			passwords.CommandProvider{Command: "touch '" + marker + "'"},
+			fileProvider,
+		},
+	})
+	if err != nil || result.Password != "secret" {
+		t.Fatalf("password=%q err=%v, want the remembered password", result.Password, err)
+	}
+	if want := []string{"", "secret"}; !reflect.DeepEqual(tried, want) {
+		t.Fatalf("tried=%v, want the remembered password right after the unencrypted attempt", tried)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("password command ran although the remembered password worked: %v", err)
+	}
+}
+
+func TestExtractArchiveWithPasswordsSkipsFullExtractionForRejectedPasswords(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	passwordFile := filepath.Join(root, "passwords.txt")
+	if err := os.WriteFile(passwordFile, []byte("wrong\nalso-wrong\nsecret\n"), 0o644); err != nil {
+		t.Fatalf("write password file: %v", err)
+	}
+
+	var extracted []string
+	extract := func(_ string, _ string, _ bool, settings rar.OpenSettings) ([]string, error) {
+		extracted = append(extracted, settings.Password)
+		if settings.Password == "secret" {
+			return []string{"release.rar"}, nil
+		}
+		return nil, rardecode.ErrArchiveEncrypted
+	}
+	var checked []string
+	check := func(_ string, settings rar.OpenSettings) error {
+		checked = append(checked, settings.Password)
+		if settings.Password == "secret" {
+			return nil
+		}
+		return rardecode.ErrBadPassword
+	}
+
+	result, err := extractArchiveWithPasswords(extract, check, ExtractRequest{
+		ArchivePath:  "/archives/release.rar",
+		TmpDir:       t.TempDir(),
+		MaxDictBytes: 1 << 20,
+		PasswordFile: passwordFile,
+		Passwords:    passwords.Chain{passwords.FileProvider{Path: passwordFile}},
+	})
+	if err != nil {
+		t.Fatalf("extractArchiveWithPasswords returned error: %v", err)
+	}
+	if result.Password != "secret" {
+		t.Fatalf("password=%q, want %q", result.Password, "secret")
+	}
+	if want := []string{"wrong", "also-wrong", "secret"}; !reflect.DeepEqual(checked, want) {
+		t.Fatalf("checked=%v, want %v", checked, want)
+	}
+	if want := []string{"", "secret"}; !reflect.DeepEqual(extracted, want) {
+		t.Fatalf("full extractions=%v, want %v", extracted, want)
+	}
+}
+
+func TestExtractArchiveWithPasswordsResetsTempDirBetweenAttempts(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	passwordFile := filepath.Join(root, "passwords.txt")
+	if err := os.WriteFile(passwordFile, []byte("false-positive\nsecret\n"), 0o644); err != nil {
+		t.Fatalf("write password file: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	extract := func(_ string, dir string, _ bool, settings rar.OpenSettings) ([]string, error) {
+		switch settings.Password {
+		case "":
+			if err := os.WriteFile(filepath.Join(dir, "unencrypted-partial.nfo"), []byte("x"), 0o644); err != nil {
+				return nil, err
+			}
+			return nil, rardecode.ErrArchivedFileEncrypted
+		case "false-positive":
+			if err := os.WriteFile(filepath.Join(dir, "garbage.mkv"), []byte("x"), 0o644); err != nil {
+				return nil, err
+			}
+			return nil, rardecode.ErrBadFileChecksum
+		default:
+			if err := os.WriteFile(filepath.Join(dir, "payload.mkv"), []byte("ok"), 0o644); err != nil {
+				return nil, err
+			}
+			return []string{"release.rar"}, nil
+		}
+	}
+
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:  "/archives/release.rar",
+		TmpDir:       tmpDir,
+		MaxDictBytes: 1 << 20,
+		PasswordFile: passwordFile,
+		Passwords:    passwords.Chain{passwords.FileProvider{Path: passwordFile}},
+	})
+	if err != nil {
+		t.Fatalf("extractArchiveWithPasswords returned error: %v", err)
+	}
+	if result.Password != "secret" {
+		t.Fatalf("password=%q, want %q", result.Password, "secret")
+	}
+
+	if got, want := dirNames(t, tmpDir), []string{"payload.mkv"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("temp dir contents=%v, want %v", got, want)
+	}
+}
+
+func TestExtractArchiveWithPasswordsLeavesEmptyTempDirWhenAllPasswordsFail(t *testing.T) {
 	t.Parallel()
 
 	root := t.TempDir()
-	path := filepath.Join(root, "passwords.txt")
-	if err := os.WriteFile(path, []byte("alpha\r\n\n beta \n"), 0o644); err != nil {
-		t.Fatalf("write file: %v", err)
+	passwordFile := filepath.Join(root, "passwords.txt")
+	if err := os.WriteFile(passwordFile, []byte("wrong\n"), 0o644); err != nil {
+		t.Fatalf("write password file: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	extract := func(_ string, dir string, _ bool, _ rar.OpenSettings) ([]string, error) {
+		if err := os.WriteFile(filepath.Join(dir, "partial.bin"), []byte("x"), 0o644); err != nil {
+			return nil, err
+		}
+		return nil, rardecode.ErrBadPassword
+	}
+
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:  "/archives/release.rar",
+		TmpDir:       tmpDir,
+		MaxDictBytes: 1 << 20,
+		PasswordFile: passwordFile,
+		Passwords:    passwords.Chain{passwords.FileProvider{Path: passwordFile}},
+	})
+	if !rar.IsPasswordError(err) {
+		t.Fatalf("error=%v, want password error", err)
+	}
+	if result.PasswordAttempts != 1 {
+		t.Fatalf("PasswordAttempts=%d, want 1", result.PasswordAttempts)
+	}
+	if got := dirNames(t, tmpDir); len(got) != 0 {
+		t.Fatalf("expected empty temp dir after failed attempts, got %v", got)
 	}
+}
+
+func TestExtractArchiveWithPasswordsPromptsAfterChainIsExhausted(t *testing.T) {
+	t.Parallel()
+
+	extract := func(_ string, _ string, _ bool, settings rar.OpenSettings) ([]string, error) {
+		if settings.Password == "typed" {
+			return []string{"release.rar"}, nil
+		}
+		return nil, rardecode.ErrArchiveEncrypted
+	}
+	prompt := &scriptedPrompter{answers: []string{"mistyped", "typed"}}
 
-	passwords, err := readPasswordFile(path)
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:  "/archives/release{{wrong}}.rar",
+		TmpDir:       t.TempDir(),
+		MaxDictBytes: 1 << 20,
+		Passwords:    passwords.Chain{passwords.FilenameProvider{}},
+		Prompt:       prompt,
+	})
 	if err != nil {
-		t.Fatalf("readPasswordFile returned error: %v", err)
+		t.Fatalf("extractArchiveWithPasswords returned error: %v", err)
+	}
+	if result.Password != "typed" || result.PasswordSource != passwords.PromptSource {
+		t.Fatalf("result=%+v, want prompted password", result)
+	}
+	if !reflect.DeepEqual(prompt.attempts, []int{1, 2}) {
+		t.Fatalf("prompt attempts=%v, want [1 2]", prompt.attempts)
+	}
+}
+
+func TestExtractArchiveWithPasswordsPromptSkipKeepsPasswordRequiredError(t *testing.T) {
+	t.Parallel()
+
+	extract := func(_ string, _ string, _ bool, _ rar.OpenSettings) ([]string, error) {
+		return nil, rardecode.ErrArchiveEncrypted
+	}
+	prompt := &scriptedPrompter{answers: []string{""}}
+
+	_, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:  "/archives/release.rar",
+		TmpDir:       t.TempDir(),
+		MaxDictBytes: 1 << 20,
+		Prompt:       prompt,
+	})
+	var passwordErr *PasswordRequiredError
+	if !errors.As(err, &passwordErr) {
+		t.Fatalf("error=%v, want *PasswordRequiredError", err)
+	}
+	if len(prompt.attempts) != 1 {
+		t.Fatalf("prompt attempts=%v, want one", prompt.attempts)
+	}
+}
+
+type scriptedPrompter struct {
+	answers  []string
+	attempts []int
+}
+
+func (p *scriptedPrompter) Prompt(_ context.Context, _ passwords.Target, attempt int) (string, error) {
+	p.attempts = append(p.attempts, attempt)
+	if len(p.answers) == 0 {
+		return "", nil
+	}
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return answer, nil
+}
+
+func acceptPassword(string, rar.OpenSettings) error {
+	return nil
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir %q: %v", dir, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestExtractArchiveWithPasswordsReturnsVolumeSums(t *testing.T) {
+	t.Parallel()
+
+	volume := filepath.Join(t.TempDir(), "release.rar")
+	if err := os.WriteFile(volume, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+
+	extract := func(archivePath string, _ string, _ bool, settings rar.OpenSettings) ([]string, error) {
+		if settings.FileSystem == nil {
+			t.Fatal("expected a hashing filesystem for single-pass extraction")
+		}
+		file, err := settings.FileSystem.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		return []string{archivePath}, file.Close()
+	}
+
+	result, err := extractArchiveWithPasswords(extract, acceptPassword, ExtractRequest{
+		ArchivePath:   volume,
+		TmpDir:        t.TempDir(),
+		MaxDictBytes:  1 << 20,
+		HashAlgorithm: checksum.CRC32,
+	})
+	if err != nil {
+		t.Fatalf("extractArchiveWithPasswords returned error: %v", err)
 	}
-	want := []string{"alpha", " beta "}
-	if !reflect.DeepEqual(passwords, want) {
-		t.Fatalf("passwords=%v, want %v", passwords, want)
+	if got, want := result.VolumeSums[volume], []byte{0xb9, 0x9a, 0xcd, 0xde}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("VolumeSums[%q]=%x, want %x", volume, got, want)
 	}
 }
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/hooks"
+	"github.com/arodd/go-unrarall/internal/passwords"
+	"github.com/arodd/go-unrarall/internal/plan"
+	"github.com/arodd/go-unrarall/internal/rar"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/nwaples/rardecode/v2"
+)
+
+// nestedListBytes bounds the nested archive volumes a plan holds in memory
+// to list them, per top-level set.
+const nestedListBytes = 64 << 20
+
+// volumeNamePattern matches entry names that may be archive volumes worth
+// reading into memory for nested listings.
+var volumeNamePattern = regexp.MustCompile(`(?i)\.(rar|[r-z][0-9]{2}|[0-9]{3})$`)
+
+// planCandidate lists candidate without extracting it and records where
+// each entry would go, which nested archives would be recursed into, and
+// what cleanup hooks would delete. Listing problems are part of the plan
+// rather than failures: the real run may still get further, for example
+// with a password from the prompt.
+func (r *runner) planCandidate(candidate finder.Candidate, destRoot, rarDir string, depth int, record *report.Candidate) error {
+	set := &plan.Set{Path: candidate.Path, Destination: destRoot}
+	files, contents, err := r.listForPlan(candidate.Path)
+	if err != nil {
+		set.Error = fmt.Sprintf("listing failed, the plan may be incomplete: %v", err)
+	}
+	planned := r.planFiles(set, files, destRoot)
+	if depth-1 >= 0 {
+		budget := int64(nestedListBytes)
+		set.Nested = r.planNested(files, contents, destRoot, depth-1, &budget)
+		planned = appendNestedTargets(planned, set.Nested)
+	}
+
+	var hookErr error
+	if shouldRunHooks(r.opts.CleanHooks) {
+		onAction := recordHookAction(record)
+		hookErr = hooks.Run(r.opts.CleanHooks, hooks.Context{
+			ExtractRoot: destRoot,
+			RarDir:      rarDir,
+			Stem:        candidate.Stem,
+			DryRun:      true,
+			Log:         r.log,
+			Planned:     planned,
+			OnAction: func(action hooks.Action) {
+				set.Hooks = append(set.Hooks, action)
+				onAction(action)
+			},
+		})
+		if hookErr != nil && set.Error == "" {
+			set.Error = fmt.Sprintf("cleanup hooks failed: %v", hookErr)
+		}
+	}
+
+	r.plan.Add(set)
+	if r.opts.Plan != plan.JSON {
+		for _, line := range set.Lines() {
+			r.log.Infof("%s", line)
+		}
+	}
+	return hookErr
+}
+
+// listForPlan lists path, reading possible nested volumes into memory. An
+// archive with encrypted headers is listed with each password candidate in
+// turn; the prompt is never used.
+func (r *runner) listForPlan(archivePath string) ([]rar.ListedFile, map[string][]byte, error) {
+	opts := rar.OpenSettings{MaxDictionaryBytes: r.opts.MaxDictBytes}.DecodeOptions()
+	list := func(password string) ([]rar.ListedFile, map[string][]byte, error) {
+		want := func(file rar.ListedFile) bool {
+			return (password != "" || !file.Encrypted) && volumeNamePattern.MatchString(file.Name)
+		}
+		listOpts := opts
+		if password != "" {
+			listOpts = append(listOpts[:len(listOpts):len(listOpts)], rardecode.Password(password))
+		}
+		return listArchiveEntries(nil, archivePath, want, nestedListBytes, listOpts...)
+	}
+
+	files, contents, err := list("")
+	if !rar.IsPasswordError(err) || len(files) > 0 {
+		return files, contents, err
+	}
+	target := passwords.TargetFor(archivePath)
+	target.Context = r.ctx
+	listErr := err
+	_ = r.passwords.Prefer(r.store.Source(target)).Resolve(target, func(batch []passwords.Candidate) bool {
+		for _, candidate := range r.store.Order(target, batch) {
+			files, contents, listErr = list(candidate.Password)
+			if !rar.IsPasswordError(listErr) {
+				return false
+			}
+		}
+		return true
+	})
+	if !rar.IsPasswordError(listErr) {
+		return files, contents, listErr
+	}
+	return nil, nil, listErr
+}
+
+// planFiles records the target of every entry in set and returns the
+// targets. Targets are claimed in the order extraction moves them.
+func (r *runner) planFiles(set *plan.Set, files []rar.ListedFile, destRoot string) []string {
+	type entry struct {
+		rel  string
+		file rar.ListedFile
+	}
+	// Later entries overwrite earlier ones with the same target, as they
+	// do in the temp directory.
+	byRel := make(map[string]entry, len(files))
+	for _, file := range files {
+		rel, err := rar.EntryTarget(file.Name, file.IsDir, r.opts.FullPath)
+		if err != nil {
+			set.Error = err.Error()
+			continue
+		}
+		if rel == "" || file.IsDir {
+			continue
+		}
+		if file.Symlink && !r.opts.AllowSymlinks {
+			set.Error = fmt.Sprintf("archive entry %q is a symlink and symlink extraction is disabled (use --allow-symlinks to override)", file.Name)
+			continue
+		}
+		byRel[rel] = entry{rel: rel, file: file}
+	}
+	entries := make([]entry, 0, len(byRel))
+	for _, e := range byRel {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].rel < entries[j].rel })
+
+	targets := make([]string, 0, len(entries))
+	for _, e := range entries {
+		target, renamed := r.plan.Claim(filepath.Join(destRoot, e.rel))
+		set.Files = append(set.Files, plan.File{
+			Entry:   e.file.Name,
+			Target:  target,
+			Size:    e.file.Size,
+			Renamed: renamed,
+			Symlink: e.file.Symlink,
+		})
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// planNested plans the archives among files that extraction would recurse
+// into. Sets whose volumes were read into memory are listed from there,
+// within budget bytes shared by all levels.
+func (r *runner) planNested(files []rar.ListedFile, contents map[string][]byte, parentDest string, depth int, budget *int64) []*plan.Set {
+	var sets []*plan.Set
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		rel, err := rar.EntryTarget(file.Name, false, r.opts.FullPath)
+		if err != nil || rel == "" {
+			continue
+		}
+		first := path.Base(file.Name)
+		if ok, _ := finder.IsFirstVolume(first); !ok {
+			continue
+		}
+		destRoot := r.opts.OutputDir
+		if destRoot == "" {
+			destRoot = filepath.Join(parentDest, filepath.Dir(rel))
+		}
+		set := &plan.Set{Path: file.Name, Destination: destRoot}
+		sets = append(sets, set)
+
+		volumes := rar.MemoryFS{}
+		for _, other := range files {
+			if path.Dir(other.Name) != path.Dir(file.Name) {
+				continue
+			}
+			name := path.Base(other.Name)
+			if data, ok := contents[other.Name]; ok && (name == first || finder.IsSetVolume(first, name)) {
+				volumes[name] = data
+			}
+		}
+		if _, ok := volumes[first]; !ok {
+			set.Error = "not listed: the archive is encrypted or too large to read into memory"
+			continue
+		}
+
+		opts := rar.OpenSettings{MaxDictionaryBytes: r.opts.MaxDictBytes}.DecodeOptions()
+		want := func(nested rar.ListedFile) bool {
+			return !nested.Encrypted && volumeNamePattern.MatchString(nested.Name)
+		}
+		nestedFiles, nestedContents, err := listArchiveEntries(volumes, first, want, *budget, opts...)
+		for _, data := range nestedContents {
+			*budget -= int64(len(data))
+		}
+		if err != nil {
+			set.Error = fmt.Sprintf("listing failed, the plan may be incomplete: %v", err)
+		}
+		r.planFiles(set, nestedFiles, destRoot)
+		if depth-1 >= 0 {
+			set.Nested = r.planNested(nestedFiles, nestedContents, destRoot, depth-1, budget)
+		}
+	}
+	return sets
+}
+
+func appendNestedTargets(targets []string, sets []*plan.Set) []string {
+	for _, set := range sets {
+		for _, file := range set.Files {
+			targets = append(targets, file.Target)
+		}
+		targets = appendNestedTargets(targets, set.Nested)
+	}
+	return targets
+}
@@ -1,18 +1,31 @@
 package app
 
-import "fmt"
+import "errors"
 
-func (r *runner) runRecursive(tmpDir string, depth int) (Stats, error) {
+// errNestedFailed reports that archives extracted from a set failed.
+var errNestedFailed = errors.New("nested extraction run failed")
+
+// runRecursive extracts archives found in tmpDir, the output of parent.
+func (r *runner) runRecursive(tmpDir, parent string, depth int) (Stats, error) {
 	if depth < 0 {
 		return Stats{}, nil
 	}
 
-	nestedStats, err := r.runDirectory(tmpDir, depth)
+	// Nested sets run sequentially inside their parent's job. Their temp
+	// paths never recur, so they are not journaled and their volume sums
+	// are kept out of the checksum cache.
+	nested := *r
+	nested.jobs = 1
+	nested.journal = nil
+	nested.checksums = nil
+	nested.pending = nil
+	nested.parent = parent
+	nestedStats, err := nested.runDirectory(tmpDir, depth)
 	if err != nil {
 		return nestedStats, err
 	}
 	if ExitCode(nestedStats, r.opts.AllowFailures) != 0 {
-		return nestedStats, fmt.Errorf("nested extraction run failed")
+		return nestedStats, errNestedFailed
 	}
 	return nestedStats, nil
 }
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/hooks"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// recordChecksum sets the checksum result of record. Without a manifest,
+// an error means discovery failed.
+func recordChecksum(record *report.Candidate, enabled bool, manifest *checksum.Manifest, err error) {
+	switch {
+	case !enabled:
+		record.Checksum.Result = report.ChecksumDisabled
+		return
+	case err != nil:
+		record.Checksum.Result = report.ChecksumFailed
+	case manifest == nil:
+		record.Checksum.Result = report.ChecksumNone
+		return
+	default:
+		record.Checksum.Result = report.ChecksumPassed
+	}
+	if manifest != nil {
+		record.Checksum.Manifest = manifest.Path
+		record.Checksum.Algorithm = string(manifest.Algorithm)
+	}
+}
+
+// recordExtraction records the volumes read, whether a password was
+// needed, and how many were tried. The password itself is never recorded.
+func recordExtraction(record *report.Candidate, rarDir string, result PasswordExtractionResult, err error) {
+	switch {
+	case result.UsedPassword:
+		record.Password = report.Password{Required: true, Source: result.PasswordSource}
+	case err != nil && classifyError(err) == report.ClassPassword:
+		record.Password.Required = true
+	}
+	record.Password.Attempts = result.PasswordAttempts
+
+	for _, name := range result.Volumes {
+		name = filepath.Base(name)
+		record.Volumes = append(record.Volumes, name)
+		if info, err := os.Stat(filepath.Join(rarDir, name)); err == nil {
+			record.BytesRead += info.Size()
+		}
+	}
+}
+
+// recordPlaced records the files moved to the destination under their
+// final names.
+func recordPlaced(record *report.Candidate, placed []placedFile) {
+	for _, file := range placed {
+		info, err := os.Lstat(file.Dest)
+		if err != nil {
+			continue
+		}
+		record.Files = append(record.Files, report.File{Path: file.Dest, Size: info.Size(), Symlink: file.Symlink})
+		if !file.Symlink {
+			record.BytesWritten += info.Size()
+		}
+	}
+}
+
+// recordHookAction returns a hooks.Context.OnAction callback appending to
+// record.
+func recordHookAction(record *report.Candidate) func(hooks.Action) {
+	return func(action hooks.Action) {
+		record.Hooks = append(record.Hooks, action)
+	}
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// maxRetryDelay caps the wait between attempts of a set.
+const maxRetryDelay = 5 * time.Minute
+
+// waitRetry waits d before a set is started over, or until ctx is done.
+var waitRetry = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transientError ends an attempt that hit a transient filesystem error and
+// has been rolled back, so the set can be started over.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// extractRetrying extracts candidate, starting it over from a clean temp
+// directory after a transient filesystem error (fsutil.IsTransient) up to
+// --io-retries times. The last attempt fails on such errors like any other.
+//
+// Records of sets nested in an attempt are held back until the attempt is
+// final: an abandoned attempt's are dropped from the report, and a final
+// attempt's are handed to the enclosing attempt, or counted and sent to
+// the sink at the top level.
+func (r *runner) extractRetrying(candidate finder.Candidate, depth int, record *report.Candidate) (Stats, error) {
+	wait := r.opts.IORetryDelay
+	for retry := 0; ; retry++ {
+		var nested []*report.Candidate
+		attempt := *r
+		attempt.retryTransient = retry < r.opts.IORetries
+		attempt.nested = &nested
+		stats, err := attempt.extractCandidate(candidate, depth, record)
+		var transient *transientError
+		if !errors.As(err, &transient) {
+			r.keepNested(nested)
+			return stats, err
+		}
+		r.log.Errorf("Transient error on %q, starting it over in %s (retry %d of %d): %v", candidate.Path, wait, retry+1, r.opts.IORetries, err)
+		r.report.Discard(nested)
+		record.Restart()
+		if err := waitRetry(r.ctx, wait); err != nil {
+			return Stats{ArchivesFound: 1}, err
+		}
+		wait = min(2*wait, maxRetryDelay)
+	}
+}
+
+// keepNested passes the records of a final attempt's nested sets on to the
+// enclosing attempt, or publishes them when r runs a top-level set.
+func (r *runner) keepNested(records []*report.Candidate) {
+	if r.nested != nil {
+		*r.nested = append(*r.nested, records...)
+		return
+	}
+	for _, record := range records {
+		r.publish(record)
+	}
+}
+
+// retryAttempt rolls back an attempt ended by the transient error err, so
+// extractRetrying can start the set over. A rollback that leaves files
+// behind would have them placed a second time, with collision suffixes, so
+// the set then fails with the class io and the removal errors instead.
+func (r *runner) retryAttempt(candidate finder.Candidate, tmpDir string, placed []placedFile, err error, stats Stats, record *report.Candidate) (Stats, error) {
+	if rollbackErr := r.rollback(candidate, tmpDir, placed); rollbackErr != nil {
+		err = fmt.Errorf("%w; not retrying because rollback failed: %w", err, rollbackErr)
+		r.log.Errorf("Extraction failed for %q: %v", candidate.Path, err)
+		fail(record, report.ClassIO, err)
+		stats.Failures++
+		return stats, nil
+	}
+	return stats, &transientError{err}
+}
+
+// retryable reports whether err should end this attempt so the set can be
+// started over.
+func (r *runner) retryable(err error) bool {
+	return r.retryTransient && fsutil.IsTransient(err)
+}
@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+func firstAttemptFails(err error) func(int) error {
+	return func(attempt int) error {
+		if attempt == 1 {
+			return err
+		}
+		return nil
+	}
+}
+
+func TestRunStartsSetsOverAfterTransientErrors(t *testing.T) {
+	eio := &fs.PathError{Op: "read", Path: "/mnt/a.part2.rar", Err: syscall.EIO}
+	tests := []struct {
+		name string
+		// extractFails and moveFails return the error of an attempt's
+		// extraction or second move, or nil.
+		extractFails func(attempt int) error
+		moveFails    func(attempt int) error
+		wantStats    Stats
+		wantRetries  int
+		wantWaits    []time.Duration
+	}{
+		{
+			name:         "extraction",
+			extractFails: firstAttemptFails(eio),
+			wantStats:    Stats{ArchivesFound: 1, ArchivesExtracted: 1},
+			wantRetries:  1,
+			wantWaits:    []time.Duration{time.Second},
+		},
+		{
+			name:        "move",
+			moveFails:   firstAttemptFails(syscall.ESTALE),
+			wantStats:   Stats{ArchivesFound: 1, ArchivesExtracted: 1},
+			wantRetries: 1,
+			wantWaits:   []time.Duration{time.Second},
+		},
+		{
+			name:         "exhausted",
+			extractFails: func(int) error { return eio },
+			wantStats:    Stats{ArchivesFound: 1, Failures: 1},
+			wantRetries:  2,
+			wantWaits:    []time.Duration{time.Second, 2 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			out := t.TempDir()
+
+			restore := stubRunDependencies()
+			defer restore()
+
+			scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+				if dir != root {
+					return nil, nil
+				}
+				return []finder.Candidate{{Path: filepath.Join(root, "a.rar"), Stem: "a"}}, nil
+			}
+			validateRarSignature = func(string) (bool, error) {
+				return true, nil
+			}
+			attempt := 0
+			extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+				attempt++
+				if entries, _ := os.ReadDir(req.TmpDir); len(entries) != 0 {
+					t.Errorf("attempt %d started in a temp directory holding %v", attempt, entries)
+				}
+				for _, name := range []string{"one.mkv", "two.mkv"} {
+					if err := os.WriteFile(filepath.Join(req.TmpDir, name), []byte(name), 0o644); err != nil {
+						return PasswordExtractionResult{}, err
+					}
+				}
+				if tt.extractFails != nil {
+					return PasswordExtractionResult{}, tt.extractFails(attempt)
+				}
+				return PasswordExtractionResult{}, nil
+			}
+			moves := 0
+			safeMovePath = func(ctx context.Context, src, dst string, verify func(string) error) (string, error) {
+				moves++
+				if moves == 2 && tt.moveFails != nil {
+					if err := tt.moveFails(attempt); err != nil {
+						return "", err
+					}
+				}
+				return fsutil.SafeMoveContext(ctx, src, dst, verify)
+			}
+			var waits []time.Duration
+			waitRetry = func(_ context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				moves = 0
+				return nil
+			}
+
+			sink := &recordingSink{}
+			opts := cli.Options{Dir: root, OutputDir: out, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20, IORetries: 2, IORetryDelay: time.Second}
+			stats, err := RunWithSink(context.Background(), opts, sink)
+			if err != nil || stats != tt.wantStats {
+				t.Fatalf("stats=%+v err=%v, want %+v", stats, err, tt.wantStats)
+			}
+			if !reflect.DeepEqual(waits, tt.wantWaits) {
+				t.Fatalf("waits=%v, want %v", waits, tt.wantWaits)
+			}
+			if len(sink.candidates) != 1 || sink.candidates[0].Retries != tt.wantRetries {
+				t.Fatalf("records=%+v, want one record with %d retries", sink.candidates, tt.wantRetries)
+			}
+			if record := sink.candidates[0]; tt.wantStats.Failures > 0 && (record.Error == nil || record.Error.Class != report.ClassIO) {
+				t.Fatalf("error=%+v, want the last attempt failed as io", record.Error)
+			}
+			if tt.wantStats.ArchivesExtracted == 1 {
+				entries, _ := os.ReadDir(out)
+				if len(entries) != 2 || entries[0].Name() != "one.mkv" || entries[1].Name() != "two.mkv" {
+					t.Fatalf("output holds %v, want each file once without collision suffixes", entries)
+				}
+			}
+		})
+	}
+}
+
+func TestRunFailsSetWhenRollbackBeforeRetryFails(t *testing.T) {
+	root := t.TempDir()
+	out := t.TempDir()
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: filepath.Join(root, "a.rar"), Stem: "a"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		for _, name := range []string{"one.mkv", "two.mkv"} {
+			if err := os.WriteFile(filepath.Join(req.TmpDir, name), []byte(name), 0o644); err != nil {
+				return PasswordExtractionResult{}, err
+			}
+		}
+		return PasswordExtractionResult{}, nil
+	}
+	// The first file lands somewhere rollback cannot remove: a directory
+	// that is not empty.
+	stuck := filepath.Join(out, "stuck")
+	if err := os.MkdirAll(filepath.Join(stuck, "keep"), 0o755); err != nil {
+		t.Fatalf("create stuck directory: %v", err)
+	}
+	moves := 0
+	safeMovePath = func(context.Context, string, string, func(string) error) (string, error) {
+		moves++
+		if moves == 1 {
+			return stuck, nil
+		}
+		return "", syscall.ESTALE
+	}
+	waitRetry = func(context.Context, time.Duration) error {
+		t.Fatal("retried after a failed rollback")
+		return nil
+	}
+
+	sink := &recordingSink{}
+	opts := cli.Options{Dir: root, OutputDir: out, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20, IORetries: 2, IORetryDelay: time.Second}
+	stats, err := RunWithSink(context.Background(), opts, sink)
+	if err != nil || stats != (Stats{ArchivesFound: 1, Failures: 1}) {
+		t.Fatalf("stats=%+v err=%v, want the set failed", stats, err)
+	}
+	record := sink.candidates[0]
+	if record.Error == nil || record.Error.Class != report.ClassIO || !strings.Contains(record.Error.Message, "rollback failed") || record.Retries != 0 {
+		t.Fatalf("record=%+v, want an io failure naming the rollback and no retries", record)
+	}
+}
+
+func TestRunKeepsOnlyNestedRecordsOfFinalAttempt(t *testing.T) {
+	root := t.TempDir()
+	out := t.TempDir()
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir == root {
+			return []finder.Candidate{{Path: filepath.Join(root, "outer.rar"), Stem: "outer"}}, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, "inner.rar")); err == nil {
+			return []finder.Candidate{{Path: filepath.Join(dir, "inner.rar"), Stem: "inner"}}, nil
+		}
+		return nil, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		name := "inner.rar"
+		if filepath.Base(req.ArchivePath) == "inner.rar" {
+			name = "movie.mkv"
+		}
+		return PasswordExtractionResult{}, os.WriteFile(filepath.Join(req.TmpDir, name), []byte(name), 0o644)
+	}
+	// Moving the outer set's own files fails once, after its nested set
+	// has finished.
+	failed := false
+	safeMovePath = func(ctx context.Context, src, dst string, verify func(string) error) (string, error) {
+		if !failed && filepath.Base(src) == "inner.rar" {
+			failed = true
+			return "", syscall.ESTALE
+		}
+		return fsutil.SafeMoveContext(ctx, src, dst, verify)
+	}
+	waitRetry = func(context.Context, time.Duration) error {
+		return nil
+	}
+
+	sink := &recordingSink{}
+	opts := cli.Options{Dir: root, OutputDir: out, Depth: 1, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20, IORetries: 2, IORetryDelay: time.Second, Report: reportPath}
+	if _, err := RunWithSink(context.Background(), opts, sink); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if !failed {
+		t.Fatal("the outer set was never started over")
+	}
+	var sent []string
+	for _, record := range sink.candidates {
+		sent = append(sent, filepath.Base(record.Path))
+	}
+	if want := []string{"inner.rar", "outer.rar"}; !reflect.DeepEqual(sent, want) {
+		t.Fatalf("sink received %v, want %v", sent, want)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var written report.Report
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if len(written.Candidates) != 2 {
+		t.Fatalf("report lists %d records, want the outer set and one nested record", len(written.Candidates))
+	}
+}
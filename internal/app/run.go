@@ -1,18 +1,34 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
 	"github.com/arodd/go-unrarall/internal/cli"
 	"github.com/arodd/go-unrarall/internal/finder"
 	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/hooks"
+	"github.com/arodd/go-unrarall/internal/journal"
+	"github.com/arodd/go-unrarall/internal/lock"
 	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/metrics"
+	"github.com/arodd/go-unrarall/internal/passwords"
+	"github.com/arodd/go-unrarall/internal/plan"
 	"github.com/arodd/go-unrarall/internal/rar"
-	"github.com/arodd/go-unrarall/internal/sfv"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/arodd/go-unrarall/internal/webhook"
 )
 
 var (
@@ -21,18 +37,56 @@ var (
 	createExtractionTempDir   = fsutil.CreateTempDir
 	extractArchiveWithRetries = ExtractArchiveWithPasswords
 	checkAlreadyExtracted     = AlreadyExtracted
-	safeMovePath              = fsutil.SafeMove
+	safeMovePath              = fsutil.SafeMoveContext
 	runCleanupSelection       = runCleanupHooks
+	openPasswordStore         = passwords.OpenStore
+	newPasswordPrompter       = terminalPasswordPrompter
+	openChecksumCache         = checksum.OpenCache
+	checksumCachePath         = checksum.DefaultCachePath
+	mapArchiveVolumes         = mapVolumes
+	openJournal               = journal.Open
+	listArchiveEntries        = rar.ReadEntries
+	acquireRunLock            = lock.AcquireRun
+	acquireSetLock            = lock.AcquireSet
+	resolvePassphrase         = passwords.ResolvePassphrase
+	// planOutput receives the --plan=json document.
+	planOutput io.Writer = os.Stdout
 )
 
 const scanDepthUnbounded = -1
 
+func mapVolumes(path string) (rar.VolumeMap, error) {
+	return rar.MapVolumes(path)
+}
+
+// terminalPasswordPrompter returns a prompter for stdin, or nil when stdin
+// is not a terminal.
+func terminalPasswordPrompter() passwords.Prompter {
+	if prompter := passwords.NewTerminalPrompter(); prompter != nil {
+		return prompter
+	}
+	return nil
+}
+
 // Stats tracks extraction outcomes across a run.
 type Stats struct {
 	ArchivesFound     int
 	ArchivesExtracted int
 	ArchivesSkipped   int
 	Failures          int
+	// TimedOut counts the Failures that ran out of time.
+	TimedOut int
+}
+
+// summary returns the report totals for s.
+func (s Stats) summary() report.Summary {
+	return report.Summary{
+		Found:     s.ArchivesFound,
+		Extracted: s.ArchivesExtracted,
+		Skipped:   s.ArchivesSkipped,
+		Failures:  s.Failures,
+		TimedOut:  s.TimedOut,
+	}
 }
 
 func (s *Stats) add(other Stats) {
@@ -40,8 +94,13 @@ func (s *Stats) add(other Stats) {
 	s.ArchivesExtracted += other.ArchivesExtracted
 	s.ArchivesSkipped += other.ArchivesSkipped
 	s.Failures += other.Failures
+	s.TimedOut += other.TimedOut
 }
 
+// ExitCanceled is the exit code of a run stopped by SIGINT or SIGTERM, as
+// shells report a process killed by SIGINT.
+const ExitCanceled = 130
+
 // ExitCode computes the process exit code using script-parity behavior.
 func ExitCode(stats Stats, allowFailures bool) int {
 	if stats.Failures == 0 {
@@ -58,34 +117,260 @@ func successfulArchives(stats Stats) int {
 }
 
 type runner struct {
-	opts cli.Options
-	log  *log.Logger
+	opts      cli.Options
+	log       *log.Logger
+	passwords passwords.Chain
+	store     *passwords.Store
+	prompt    passwords.Prompter
+	checksums *checksum.Cache
+	// journal is nil for nested runs; only top-level sets are recorded.
+	journal *journal.Journal
+	// jobs bounds concurrent candidates in runDirectory; copies of the
+	// runner made for a single candidate set it to 1.
+	jobs      int
+	placement *placement
+
+	// report collects candidate records for --report; nil when disabled.
+	report *report.Recorder
+	// plan collects the dry-run plan; nil unless --dry is set.
+	plan *plan.Recorder
+	// parent is the archive whose output a nested runner scans.
+	parent string
+	// webhook delivers --webhook events; nil when disabled.
+	webhook *webhook.Sender
+	// metrics counts finished candidates; nil unless metrics are exported.
+	// Watch passes share the watcher's.
+	metrics *metrics.Metrics
+
+	// ctx stops the run once done: no further candidates start, and those
+	// still extracting or moving files are rolled back.
+	ctx context.Context
+	// deadline is when --max-runtime stops the run starting candidates;
+	// zero without a limit.
+	deadline time.Time
+	// retryTransient ends an attempt at its first transient filesystem
+	// error, rolled back, so extractRetrying can start the set over.
+	retryTransient bool
+	// onCandidate receives each finished record; nil unless a Sink is set.
+	onCandidate func(report.Candidate)
+	// pending holds the journal entry of the candidate this copy processes;
+	// nil outside processCandidate.
+	pending *pendingJournal
+	// nested collects the finished records of sets nested in the current
+	// attempt of the enclosing set; nil outside one. See extractRetrying.
+	nested *[]*report.Candidate
 }
 
-// Run executes archive extraction orchestration for opts.Dir.
-func Run(opts cli.Options, logger *log.Logger) (Stats, error) {
-	r := &runner{
-		opts: opts,
-		log:  logger,
+// Sink receives the progress of a run started with RunWithSink.
+type Sink interface {
+	// Log receives each log message.
+	Log(level log.Level, message string)
+	// Candidate receives a copy of each finished candidate record, nested
+	// ones included.
+	Candidate(record report.Candidate)
+}
+
+// Run executes archive extraction orchestration for opts.Dir. Once ctx is
+// done no further candidates start, candidates still extracting or moving
+// files are rolled back, and Run logs a partial summary and returns
+// ctx.Err().
+func Run(ctx context.Context, opts cli.Options, logger *log.Logger) (Stats, error) {
+	return run(ctx, opts, logger, nil)
+}
+
+// RunWithSink runs like Run but reports to sink instead of a logger.
+func RunWithSink(ctx context.Context, opts cli.Options, sink Sink) (Stats, error) {
+	return run(ctx, opts, log.NewWithSink(opts.Quiet, opts.Verbose, sink.Log), sink.Candidate)
+}
+
+func run(ctx context.Context, opts cli.Options, logger *log.Logger, onCandidate func(report.Candidate)) (Stats, error) {
+	runLock, err := lockRun(opts, logger)
+	if err != nil {
+		return Stats{}, err
 	}
+	defer releaseLock(runLock, logger)
 
-	stats, err := r.runDirectory(opts.Dir, opts.Depth)
+	r, err := newRunner(ctx, opts, logger)
 	if err != nil {
+		return Stats{}, err
+	}
+	r.onCandidate = onCandidate
+	var stats Stats
+	if opts.Archive != "" {
+		stats, err = r.runArchive(opts.Archive, opts.Depth)
+	} else {
+		stats, err = r.runDirectory(opts.Dir, opts.Depth)
+	}
+	r.finish(stats)
+	if errors.Is(err, context.Canceled) {
+		r.log.Errorf("Run canceled; sets not yet placed were rolled back.")
+		r.logSummary(stats)
 		return stats, err
 	}
+	if err != nil {
+		return stats, err
+	}
+	if opts.Plan == plan.JSON {
+		if err := plan.WriteJSON(planOutput, r.plan.Plan()); err != nil {
+			return stats, fmt.Errorf("write plan: %w", err)
+		}
+	}
 
 	r.logSummary(stats)
 	return stats, nil
 }
 
+// newRunner prepares the password sources, stores, caches, journal, and
+// webhook sender shared by every candidate of a run. Unavailable stores are
+// logged and skipped; only a journal required by --skip=journal and a
+// missing webhook secret are fatal. ctx is the run's context.
+func newRunner(ctx context.Context, opts cli.Options, logger *log.Logger) (*runner, error) {
+	r := &runner{
+		opts:      opts,
+		log:       logger,
+		ctx:       ctx,
+		jobs:      opts.Jobs,
+		placement: &placement{},
+		deadline:  runDeadline(opts),
+		passwords: passwords.DefaultChain(passwords.Config{
+			PasswordFile: opts.PasswordFile,
+			MappingFile:  opts.PasswordMap,
+			EnvVar:       opts.PasswordEnv,
+			Command:      opts.PasswordCommand,
+			Context:      ctx,
+			// Resolved at most once per run, and only if the password file
+			// turns out to be a vault.
+			Passphrase: sync.OnceValues(func() (string, error) {
+				return vaultPassphrase(ctx, opts)
+			}),
+		}),
+	}
+	if opts.PasswordFile != "" && passwords.ExposedPlaintextFile(opts.PasswordFile) {
+		logger.Errorf("Warning: password file %q is readable by other users; restrict it with chmod 600 or move its passwords into a vault with `passwords add`", opts.PasswordFile)
+	}
+	if !opts.NoPasswordPrompt {
+		r.prompt = newPasswordPrompter()
+	}
+	if opts.PasswordStore != "" {
+		store, err := openPasswordStore(opts.PasswordStore)
+		if err != nil {
+			logger.Errorf("Password store unavailable, trying passwords in source order: %v", err)
+		} else {
+			r.store = store
+		}
+	}
+
+	if opts.CKSFV {
+		if path := checksumCachePath(); path != "" {
+			cache, err := openChecksumCache(path)
+			if err != nil {
+				logger.Errorf("Checksum cache unavailable, hashing every volume: %v", err)
+			} else {
+				r.checksums = cache
+			}
+		}
+	}
+
+	if opts.Report != "" {
+		r.report = report.NewRecorder(opts.Dir)
+	}
+	if opts.DryRun {
+		r.plan = plan.NewRecorder(opts.Dir)
+	} else if opts.MetricsFile != "" {
+		r.metrics = metrics.New()
+	}
+
+	if opts.Journal != "" {
+		j, err := openJournal(opts.Journal)
+		if err != nil {
+			logger.Errorf("Journal unavailable, processed sets will not be recorded: %v", err)
+			if opts.SkipJournal {
+				return nil, fmt.Errorf("--skip=journal: %w", err)
+			}
+		} else {
+			r.journal = j
+		}
+	}
+
+	if opts.Webhook != "" {
+		var secret []byte
+		if opts.WebhookSecretEnv != "" {
+			secret = []byte(os.Getenv(opts.WebhookSecretEnv))
+			if len(secret) == 0 {
+				return nil, fmt.Errorf("--webhook-secret-env: environment variable %s is empty or unset", opts.WebhookSecretEnv)
+			}
+		}
+		r.webhook = webhook.New(ctx, webhook.Config{
+			URL:     opts.Webhook,
+			Secret:  secret,
+			Timeout: opts.WebhookTimeout,
+			Retries: opts.WebhookRetries,
+		}, logger.Errorf)
+	}
+	return r, nil
+}
+
+// finish persists state gathered during the run, writes the report and
+// metrics file with stats as its totals, and waits for webhook deliveries.
+func (r *runner) finish(stats Stats) {
+	if err := r.checksums.Save(); err != nil {
+		r.log.Errorf("Failed to update checksum cache: %v", err)
+	}
+	summary := stats.summary()
+	if r.webhook != nil && !r.opts.DryRun {
+		r.webhook.Send(webhook.Event{Type: webhook.RunFinished, Dir: r.opts.Dir, Summary: &summary})
+	}
+	r.webhook.Close()
+	if r.metrics != nil {
+		r.metrics.RunFinished(time.Now())
+		if r.opts.MetricsFile != "" {
+			if err := metrics.WriteFile(r.opts.MetricsFile, r.metrics); err != nil {
+				r.log.Errorf("Failed to write metrics: %v", err)
+			}
+		}
+	}
+	if r.report == nil {
+		return
+	}
+	doc := r.report.Report(summary)
+	if err := report.Write(r.opts.Report, doc); err != nil {
+		r.log.Errorf("Failed to write report: %v", err)
+		return
+	}
+	r.log.Verbosef("Wrote report %q", r.opts.Report)
+}
+
 func (r *runner) runDirectory(dir string, depth int) (Stats, error) {
 	candidates, err := scanCandidates(dir, scanDepthUnbounded)
 	if err != nil {
 		return Stats{}, err
 	}
+	return r.runCandidates(candidates, depth)
+}
+
+// runArchive runs the single set whose first volume is at path.
+func (r *runner) runArchive(path string, depth int) (Stats, error) {
+	isFirst, stem := finder.IsFirstVolume(filepath.Base(path))
+	if !isFirst {
+		return Stats{}, fmt.Errorf("%q is not the first volume of an archive set", path)
+	}
+	return r.runCandidates([]finder.Candidate{{Path: path, Stem: stem}}, depth)
+}
+
+func (r *runner) runCandidates(candidates []finder.Candidate, depth int) (Stats, error) {
+	if r.jobs > 1 && len(candidates) > 1 {
+		return r.runConcurrently(candidates, depth)
+	}
 
 	var stats Stats
-	for _, candidate := range candidates {
+	for i, candidate := range candidates {
+		if err := r.ctx.Err(); err != nil {
+			return stats, err
+		}
+		if r.pastDeadline() {
+			r.logDeadline(len(candidates) - i)
+			return stats, nil
+		}
 		candidateStats, err := r.processCandidate(candidate, depth)
 		stats.add(candidateStats)
 		if err != nil {
@@ -95,35 +380,125 @@ func (r *runner) runDirectory(dir string, depth int) (Stats, error) {
 	return stats, nil
 }
 
+// processCandidate extracts one candidate and completes its report record.
+// The candidate's journal entry is appended once the record is final.
 func (r *runner) processCandidate(candidate finder.Candidate, depth int) (Stats, error) {
+	if r.journal != nil {
+		withJournal := *r
+		withJournal.pending = &pendingJournal{}
+		r = &withJournal
+	}
+	record := r.report.Start(candidate.Path, r.parent)
+	stats, err := r.extractTimed(candidate, depth, record)
+	switch {
+	case err != nil:
+		if record.Error == nil {
+			fail(record, "", err)
+		}
+		record.Finish(report.Failed)
+	case record.Error != nil:
+		record.Finish(report.Failed)
+	case record.SkipReason != "":
+		record.Finish(report.Skipped)
+	case r.opts.DryRun:
+		record.Finish(report.DryRun)
+	default:
+		record.Finish(report.Extracted)
+	}
+	if err == nil && r.parent == "" && !r.opts.DryRun {
+		stats = r.runEventCommands(candidate, record, stats)
+		r.notifyWebhook(record)
+	}
+	r.finishJournal(record)
+	if r.nested != nil {
+		*r.nested = append(*r.nested, record)
+	} else {
+		r.publish(record)
+	}
+	// Sets that reach the dry-run step add themselves to the plan, hook
+	// failures included.
+	if r.plan != nil && record.Outcome != report.DryRun && (record.Error == nil || record.Error.Class != report.ClassHook) {
+		set := &plan.Set{Path: candidate.Path, Destination: destinationRoot(r.opts.OutputDir, filepath.Dir(candidate.Path)), Skip: record.SkipReason}
+		if record.Error != nil {
+			set.Error = record.Error.Message
+		}
+		r.plan.Add(set)
+	}
+	return stats, err
+}
+
+// publish counts a final record in the metrics and hands it to the sink.
+func (r *runner) publish(record *report.Candidate) {
+	r.metrics.Observe(record)
+	if r.onCandidate != nil {
+		r.onCandidate(*record)
+	}
+}
+
+func (r *runner) extractCandidate(candidate finder.Candidate, depth int, record *report.Candidate) (Stats, error) {
 	stats := Stats{ArchivesFound: 1}
 
 	ok, err := validateRarSignature(candidate.Path)
+	if r.retryable(err) {
+		return stats, &transientError{err}
+	}
 	if err != nil {
 		r.log.Errorf("Failed to inspect archive %q: %v", candidate.Path, err)
+		fail(record, report.ClassIO, err)
 		stats.Failures++
 		return stats, nil
 	}
 	if !ok {
 		r.log.Errorf("Skipping file %q because it does not appear to be a valid rar file.", candidate.Path)
+		fail(record, report.ClassNotRAR, errNotRAR)
 		stats.Failures++
 		return stats, nil
 	}
 
+	if r.opts.SkipJournal && !r.opts.Force && r.skipFromJournal(candidate) {
+		record.SkipReason = "journal"
+		stats.ArchivesSkipped++
+		return stats, nil
+	}
+
 	rarDir := filepath.Dir(candidate.Path)
 	destRoot := destinationRoot(r.opts.OutputDir, rarDir)
 
-	sfvErr := r.verifySFVIfPresent(rarDir, candidate.Stem)
-	if sfvErr != nil && !r.opts.Force {
-		r.log.Errorf("SFV verification failed for %q: %v", candidate.Path, sfvErr)
+	// In single-pass mode the manifest is only loaded here; volumes are
+	// hashed while they are extracted and verified afterwards.
+	var (
+		checksumErr      error
+		manifest         *checksum.Manifest
+		deferredManifest *checksum.Manifest
+	)
+	phase := time.Now()
+	if r.opts.SinglePassVerify {
+		deferredManifest, checksumErr = r.findChecksumManifest(candidate)
+		manifest = deferredManifest
+	} else {
+		manifest, checksumErr = r.verifyChecksumsIfPresent(candidate)
+	}
+	record.Durations.Checksum = report.Millis(time.Since(phase))
+	if err := r.ctx.Err(); err != nil {
+		return stats, err
+	}
+	if r.retryable(checksumErr) {
+		return stats, &transientError{checksumErr}
+	}
+	recordChecksum(record, r.opts.CKSFV, manifest, checksumErr)
+	if checksumErr != nil && !r.opts.Force {
+		r.log.Errorf("Checksum verification failed for %q: %v", candidate.Path, checksumErr)
+		r.logChecksumFailure(candidate, checksumErr)
+		fail(record, "", checksumErr)
 		stats.Failures++
 		return stats, nil
 	}
-	if sfvErr != nil && r.opts.Force {
-		r.log.Errorf("SFV verification failed for %q, continuing due to --force: %v", candidate.Path, sfvErr)
+	if checksumErr != nil && r.opts.Force {
+		r.log.Errorf("Checksum verification failed for %q, continuing due to --force: %v", candidate.Path, checksumErr)
+		r.logChecksumFailure(candidate, checksumErr)
 	}
 
-	if r.opts.SkipIfExists && !r.opts.Force && !r.opts.DryRun && sfvErr == nil {
+	if r.opts.SkipIfExists && !r.opts.Force && !r.opts.DryRun && checksumErr == nil {
 		// Script parity: skip checks are evaluated relative to the archive directory.
 		skipRoot := rarDir
 		skip, err := checkAlreadyExtracted(candidate.Path, skipRoot, r.opts.FullPath)
@@ -131,63 +506,175 @@ func (r *runner) processCandidate(candidate finder.Candidate, depth int) (Stats,
 			r.log.Verbosef("Skip-if-exists check failed for %q: %v", candidate.Path, err)
 		} else if skip {
 			r.log.Infof("File %q appears to have already been extracted, skipping.", candidate.Path)
+			record.SkipReason = "exists"
 			stats.ArchivesSkipped++
 			return stats, nil
 		}
 	}
 
 	if r.opts.DryRun {
-		r.log.Infof("Dry-run: would extract %q to %q", candidate.Path, destRoot)
-		if shouldRunHooks(r.opts.CleanHooks) {
-			if err := runCleanupSelection(r.opts.CleanHooks, destRoot, rarDir, candidate.Stem, true, r.log); err != nil {
-				r.log.Errorf("Cleanup hooks failed for %q: %v", candidate.Path, err)
-				stats.Failures++
-				return stats, nil
-			}
+		if err := r.planCandidate(candidate, destRoot, rarDir, depth, record); err != nil {
+			r.log.Errorf("Cleanup hooks failed for %q: %v", candidate.Path, err)
+			fail(record, report.ClassHook, err)
+			stats.Failures++
+			return stats, nil
 		}
 		stats.ArchivesExtracted++
 		return stats, nil
 	}
 
 	tmpDir, err := createExtractionTempDir(rarDir)
+	if r.retryable(err) {
+		return stats, &transientError{err}
+	}
 	if err != nil {
 		return stats, fmt.Errorf("create temp directory for %q: %w", candidate.Path, err)
 	}
 
-	extractResult, extractErr := extractArchiveWithRetries(
-		candidate.Path,
-		tmpDir,
-		r.opts.FullPath,
-		r.opts.MaxDictBytes,
-		r.opts.AllowSymlinks,
-		r.opts.PasswordFile,
-	)
+	phase = time.Now()
+	extractResult, extractErr := extractArchiveWithRetries(ExtractRequest{
+		ArchivePath:   candidate.Path,
+		TmpDir:        tmpDir,
+		FullPath:      r.opts.FullPath,
+		MaxDictBytes:  r.opts.MaxDictBytes,
+		AllowSymlinks: r.opts.AllowSymlinks,
+		PasswordFile:  r.opts.PasswordFile,
+		Passwords:     r.passwords,
+		Store:         r.store,
+		Prompt:        r.prompt,
+		HashAlgorithm: deferredAlgorithm(deferredManifest),
+
+		FileHashAlgorithm: fileHashAlgorithm(r.opts),
+		Context:           r.ctx,
+	})
 	if extractErr == nil {
 		if extractResult.UsedPassword {
-			r.log.Verbosef("Extraction of %q succeeded using password %q", candidate.Path, extractResult.Password)
+			// Never log the password itself; the source is enough to find it.
+			r.log.Verbosef("Extraction of %q succeeded using a password from %s", candidate.Path, extractResult.PasswordSource)
+			// Nested sets live in a temp directory whose name changes every
+			// run, so remembering them would only grow the store.
+			if r.parent == "" {
+				if err := r.store.Remember(passwords.TargetFor(candidate.Path), passwords.Candidate{Password: extractResult.Password, Source: extractResult.PasswordSource}); err != nil {
+					r.log.Errorf("Failed to update password store: %v", err)
+				}
+			}
 		}
 		r.log.Verbosef("Extracted %q using volumes: %v", candidate.Path, extractResult.Volumes)
 	}
+	recordExtraction(record, rarDir, extractResult, extractErr)
+
+	if extractErr == nil && deferredManifest != nil {
+		verifier := checksum.Verifier{Cache: r.checksums, Rehash: r.opts.Rehash, Precomputed: extractResult.VolumeSums, Context: r.ctx}
+		verifyStart := time.Now()
+		err := verifier.Verify(rarDir, *deferredManifest)
+		record.Durations.Checksum += report.Millis(time.Since(verifyStart))
+		if r.retryable(err) {
+			return r.retryAttempt(candidate, tmpDir, nil, err, stats, record)
+		}
+		recordChecksum(record, true, deferredManifest, err)
+		if err != nil {
+			if !r.opts.Force {
+				r.log.Errorf("Checksum verification failed for %q; discarding extracted files: %v", candidate.Path, err)
+				r.logChecksumFailure(candidate, err)
+				fail(record, "", err)
+				r.recordJournal(candidate, destRoot, extractResult, deferredManifest.Algorithm, nil, err)
+				if err := os.RemoveAll(tmpDir); err != nil {
+					return stats, fmt.Errorf("remove temp directory %q: %w", tmpDir, err)
+				}
+				stats.Failures++
+				return stats, nil
+			}
+			r.log.Errorf("Checksum verification failed for %q, keeping extracted files due to --force: %v", candidate.Path, err)
+			r.logChecksumFailure(candidate, err)
+		}
+	}
 
 	var nestedStats Stats
 	if extractErr == nil {
-		nestedStats, extractErr = r.runRecursive(tmpDir, depth-1)
+		nestedStats, extractErr = r.runRecursive(tmpDir, candidate.Path, depth-1)
 		stats.add(nestedStats)
 	}
+	record.Durations.Extract = report.Millis(time.Since(phase))
+	if err := r.ctx.Err(); err != nil {
+		return Stats{ArchivesFound: stats.ArchivesFound}, errors.Join(err, r.rollback(candidate, tmpDir, nil))
+	}
+	if r.retryable(extractErr) {
+		return r.retryAttempt(candidate, tmpDir, nil, extractErr, stats, record)
+	}
 
-	if err := moveExtractedArtifacts(tmpDir, destRoot, r.opts.AllowSymlinks); err != nil {
+	phase = time.Now()
+	r.placement.mu.RLock()
+	placed, err := moveExtractedArtifacts(r.ctx, tmpDir, destRoot, r.opts.AllowSymlinks, extractResult.FileSums, fileHashAlgorithm(r.opts))
+	var copyErr *fsutil.CopyVerificationError
+	switch {
+	case err != nil && r.ctx.Err() != nil:
+		r.placement.mu.RUnlock()
+		return Stats{ArchivesFound: stats.ArchivesFound}, errors.Join(r.ctx.Err(), r.rollback(candidate, tmpDir, placed))
+	case r.retryable(err):
+		r.placement.mu.RUnlock()
+		return r.retryAttempt(candidate, tmpDir, placed, err, stats, record)
+	case errors.As(err, &copyErr):
+		// The bad copy was removed; the rest of the set stays in the
+		// archive, which cleanup hooks must not delete without --force.
+		r.log.Errorf("Integrity check failed while moving %q: %v", candidate.Path, err)
+		if extractErr == nil {
+			extractErr = err
+		}
+	case err != nil:
+		r.placement.mu.RUnlock()
 		return stats, fmt.Errorf("move extracted artifacts for %q: %w", candidate.Path, err)
 	}
+	var manifestPath string
+	if extractErr == nil && r.opts.WriteManifest != "" {
+		r.placement.manifest.Lock()
+		var err error
+		manifestPath, err = writeExtractedManifest(destRoot, candidate.Stem, r.opts.WriteManifest, placed, extractResult.FileSums)
+		r.placement.manifest.Unlock()
+		if err != nil {
+			r.log.Errorf("Failed to write checksum manifest for %q: %v", candidate.Path, err)
+			extractErr = err
+		} else {
+			r.log.Verbosef("Wrote checksum manifest %q", manifestPath)
+		}
+	}
+	r.placement.mu.RUnlock()
+	record.Durations.Move = report.Millis(time.Since(phase))
+	recordPlaced(record, placed)
+	r.recordJournal(candidate, destRoot, extractResult, deferredAlgorithm(deferredManifest), placed, extractErr)
 	if err := os.RemoveAll(tmpDir); err != nil {
 		return stats, fmt.Errorf("remove temp directory %q: %w", tmpDir, err)
 	}
 
+	var errClass report.ErrorClass
 	if shouldRunHooks(r.opts.CleanHooks) {
 		if extractErr == nil || r.opts.Force {
-			if err := runCleanupSelection(r.opts.CleanHooks, destRoot, rarDir, candidate.Stem, false, r.log); err != nil {
+			phase = time.Now()
+			var removed []string
+			recordAction := recordHookAction(record)
+			onAction := func(action hooks.Action) {
+				if !action.DryRun && (action.Kind == "remove_file" || action.Kind == "remove_tree") {
+					removed = append(removed, action.Path)
+				}
+				recordAction(action)
+			}
+			r.placement.mu.Lock()
+			err := runCleanupSelection(r.opts.CleanHooks, destRoot, rarDir, candidate.Stem, false, r.forgetRemoved(onAction), r.log)
+			if manifestPath != "" && len(removed) > 0 {
+				// The manifest was written first so a failed write keeps the
+				// hooks from deleting the volumes; it must not list what they
+				// removed since.
+				if pruneErr := pruneExtractedManifest(manifestPath, removed); pruneErr != nil {
+					r.log.Errorf("Failed to update checksum manifest for %q: %v", candidate.Path, pruneErr)
+					err = errors.Join(err, pruneErr)
+				}
+			}
+			r.placement.mu.Unlock()
+			record.Durations.Hooks = report.Millis(time.Since(phase))
+			if err != nil {
 				r.log.Errorf("Cleanup hooks failed for %q: %v", candidate.Path, err)
 				if extractErr == nil {
 					extractErr = err
+					errClass = report.ClassHook
 				}
 			}
 		} else {
@@ -197,6 +684,7 @@ func (r *runner) processCandidate(candidate finder.Candidate, depth int) (Stats,
 
 	if extractErr != nil {
 		r.log.Errorf("Extraction failed for %q: %v", candidate.Path, extractErr)
+		fail(record, errClass, extractErr)
 		stats.Failures++
 		return stats, nil
 	}
@@ -205,26 +693,77 @@ func (r *runner) processCandidate(candidate finder.Candidate, depth int) (Stats,
 	return stats, nil
 }
 
-func (r *runner) verifySFVIfPresent(rarDir, stem string) error {
+// rollback undoes an extraction of candidate stopped before its placement
+// finished, because the run was canceled or the attempt is retried: files
+// already placed in the destination are removed and the temp directory is
+// deleted, so the set is left as it was before the run. Its volumes are
+// never touched before placement finishes. It returns the removal errors,
+// or nil when everything was removed.
+func (r *runner) rollback(candidate finder.Candidate, tmpDir string, placed []placedFile) error {
+	var errs []error
+	for _, file := range placed {
+		if err := os.Remove(file.Dest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	if err := os.RemoveAll(tmpDir); err != nil {
+		errs = append(errs, fmt.Errorf("remove temp directory %q: %w", tmpDir, err))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	r.log.Infof("Rolled back extraction of %q; removed its temp directory and %d placed file(s).", candidate.Path, len(placed))
+	return nil
+}
+
+// verifyChecksumsIfPresent verifies the strongest manifest covering
+// candidate's set, if any, and returns it. Volumes are hashed in parallel
+// and unchanged volumes reuse sums from the checksum cache.
+func (r *runner) verifyChecksumsIfPresent(candidate finder.Candidate) (*checksum.Manifest, error) {
+	manifest, err := r.findChecksumManifest(candidate)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+	verifier := checksum.Verifier{Cache: r.checksums, Rehash: r.opts.Rehash, Context: r.ctx}
+	return manifest, verifier.Verify(filepath.Dir(candidate.Path), *manifest)
+}
+
+// findChecksumManifest returns the manifest to verify for candidate's set:
+// the strongest `<stem>` manifest or any manifest in the archive directory
+// listing the set's volumes. It returns nil when verification is disabled
+// or no manifest applies.
+func (r *runner) findChecksumManifest(candidate finder.Candidate) (*checksum.Manifest, error) {
 	if !r.opts.CKSFV {
-		return nil
+		return nil, nil
 	}
 
-	sfvPath := filepath.Join(rarDir, stem+".sfv")
-	file, err := os.Open(sfvPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return err
+	firstVolume := filepath.Base(candidate.Path)
+	manifest, err := checksum.Discover(filepath.Dir(candidate.Path), candidate.Stem, func(name string) bool {
+		return finder.IsSetVolume(firstVolume, name)
+	})
+	if err != nil || manifest == nil {
+		return nil, err
 	}
-	defer file.Close()
+	r.log.Verbosef("Verifying %q against %s manifest %q (%d entries)", candidate.Path, manifest.Algorithm, manifest.Path, len(manifest.Entries))
+	return manifest, nil
+}
 
-	entries, err := sfv.Parse(file)
-	if err != nil {
-		return err
+// fileHashAlgorithm returns the algorithm extracted files are hashed with as
+// they are written. The sums back the --write-manifest output and verify
+// cross-device copies, so files are always hashed; CRC32 keeps that cheap
+// when no manifest is requested.
+func fileHashAlgorithm(opts cli.Options) checksum.Algorithm {
+	if opts.WriteManifest != "" {
+		return opts.WriteManifest
+	}
+	return checksum.CRC32
+}
+
+func deferredAlgorithm(manifest *checksum.Manifest) checksum.Algorithm {
+	if manifest == nil {
+		return ""
 	}
-	return sfv.Verify(rarDir, entries)
+	return manifest.Algorithm
 }
 
 func (r *runner) logSummary(stats Stats) {
@@ -241,6 +780,9 @@ func (r *runner) logSummary(stats Stats) {
 
 	if stats.Failures > 0 {
 		r.log.Errorf("%d failure(s)", stats.Failures)
+		if stats.TimedOut > 0 {
+			r.log.Errorf("%d timed out", stats.TimedOut)
+		}
 		if r.opts.AllowFailures && successes > 0 {
 			r.log.Infof("%d success(es)", successes)
 		}
@@ -254,30 +796,163 @@ func destinationRoot(outputDir, rarDir string) string {
 	return rarDir
 }
 
-func moveExtractedArtifacts(tmpDir, destRoot string, allowSymlinks bool) error {
+// placedFile records where an extracted file ended up after collision
+// suffixing.
+type placedFile struct {
+	// Rel is the path relative to the temp extraction directory.
+	Rel string
+	// Dest is the final destination path.
+	Dest    string
+	Symlink bool
+}
+
+// moveExtractedArtifacts moves every extracted file from tmpDir into
+// destRoot. Copies made across devices are verified before the temp file is
+// removed: against sums from extraction (keyed by tmpDir-relative path)
+// when available, otherwise against the temp file itself. Once ctx is done
+// no further file is moved; the files placed so far are returned with
+// ctx.Err().
+func moveExtractedArtifacts(ctx context.Context, tmpDir, destRoot string, allowSymlinks bool, sums map[string][]byte, algorithm checksum.Algorithm) ([]placedFile, error) {
 	files, emptyDirs, err := collectExtractedArtifacts(tmpDir, allowSymlinks)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	placed := make([]placedFile, 0, len(files))
 	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return placed, err
+		}
 		srcPath := filepath.Join(tmpDir, rel)
 		dstPath := filepath.Join(destRoot, rel)
 
+		info, err := os.Lstat(srcPath)
+		if err != nil {
+			return placed, err
+		}
 		if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
-			return err
+			return placed, err
 		}
-		if _, err := safeMovePath(srcPath, dstPath); err != nil {
-			return err
+		symlink := info.Mode()&os.ModeSymlink != 0
+		var verify func(string) error
+		if !symlink {
+			verify = copyVerifier(ctx, srcPath, sums[rel], algorithm)
+		}
+		finalPath, err := safeMovePath(ctx, srcPath, dstPath, verify)
+		if err != nil {
+			return placed, err
 		}
+		placed = append(placed, placedFile{Rel: rel, Dest: finalPath, Symlink: symlink})
 	}
 
 	for _, rel := range emptyDirs {
 		if err := os.MkdirAll(filepath.Join(destRoot, rel), 0o755); err != nil {
+			return placed, err
+		}
+	}
+	return placed, nil
+}
+
+// copyVerifier returns a check that a copy of src has the expected sum. When
+// expected is nil, src is hashed instead.
+func copyVerifier(ctx context.Context, src string, expected []byte, algorithm checksum.Algorithm) func(string) error {
+	return func(copied string) error {
+		want := expected
+		if want == nil {
+			var err error
+			if want, err = checksum.FileSumContext(ctx, src, algorithm); err != nil {
+				return err
+			}
+		}
+		got, err := checksum.FileSumContext(ctx, copied, algorithm)
+		if err != nil {
 			return err
 		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("%s mismatch: expected %x, got %x", algorithm, want, got)
+		}
+		return nil
 	}
-	return nil
+}
+
+// writeExtractedManifest writes a `<stem>.extracted` manifest to destRoot
+// listing every placed regular file under its final name. Sums computed
+// during extraction are used as-is; files without one, such as output of
+// nested archives, are hashed at their destination. Entries of an existing
+// manifest for the same stem are kept unless a placed file replaces them,
+// so re-extracting a set next to earlier output extends its manifest. It
+// returns the manifest's path.
+func writeExtractedManifest(destRoot, stem string, algorithm checksum.Algorithm, placed []placedFile, sums map[string][]byte) (string, error) {
+	entries := make([]checksum.Entry, 0, len(placed))
+	names := make(map[string]bool, len(placed))
+	for _, file := range placed {
+		if file.Symlink {
+			continue
+		}
+		rel, err := filepath.Rel(destRoot, file.Dest)
+		if err != nil {
+			return "", err
+		}
+		sum, ok := sums[file.Rel]
+		if !ok {
+			if sum, err = checksum.FileSum(file.Dest, algorithm); err != nil {
+				return "", err
+			}
+		}
+		name := filepath.ToSlash(rel)
+		names[name] = true
+		entries = append(entries, checksum.Entry{Name: name, Sum: sum})
+	}
+
+	manifestPath := filepath.Join(destRoot, checksum.GeneratedName(stem, algorithm))
+	existing, err := checksum.ParseFile(manifestPath)
+	switch {
+	case err == nil:
+		kept := make([]checksum.Entry, 0, len(existing.Entries)+len(entries))
+		for _, entry := range existing.Entries {
+			if !names[entry.Name] {
+				kept = append(kept, entry)
+			}
+		}
+		entries = append(kept, entries...)
+	case !errors.Is(err, os.ErrNotExist):
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := checksum.Write(&buf, algorithm, entries); err != nil {
+		return "", err
+	}
+	return manifestPath, fsutil.WriteFileAtomic(manifestPath, buf.Bytes(), 0o644)
+}
+
+// pruneExtractedManifest drops the entries of the manifest at manifestPath
+// for files that removed lists, either directly or under a removed
+// directory.
+func pruneExtractedManifest(manifestPath string, removed []string) error {
+	manifest, err := checksum.ParseFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(manifestPath)
+	kept := make([]checksum.Entry, 0, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		path := filepath.Join(dir, filepath.FromSlash(entry.Name))
+		if !slices.ContainsFunc(removed, func(gone string) bool {
+			return path == gone || strings.HasPrefix(path, gone+string(filepath.Separator))
+		}) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(manifest.Entries) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := checksum.Write(&buf, manifest.Algorithm, kept); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(manifestPath, buf.Bytes(), 0o644)
 }
 
 func collectExtractedArtifacts(tmpDir string, allowSymlinks bool) ([]string, []string, error) {
@@ -1,14 +1,38 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
 	"github.com/arodd/go-unrarall/internal/cli"
 	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/hooks"
+	"github.com/arodd/go-unrarall/internal/journal"
+	"github.com/arodd/go-unrarall/internal/lock"
 	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/passwords"
+	"github.com/arodd/go-unrarall/internal/plan"
+	"github.com/arodd/go-unrarall/internal/rar"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/arodd/go-unrarall/internal/webhook"
 	"github.com/nwaples/rardecode/v2"
 )
 
@@ -116,28 +140,21 @@ func TestRunRecursesIntoNestedArchives(t *testing.T) {
 			return "", errors.New("unexpected temp parent")
 		}
 	}
-	extractArchiveWithRetries = func(
-		archivePath string,
-		tmpDir string,
-		_ bool,
-		_ int64,
-		_ bool,
-		_ string,
-	) (PasswordExtractionResult, error) {
-		switch archivePath {
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		switch req.ArchivePath {
 		case topArchive:
-			if err := os.WriteFile(filepath.Join(tmpDir, "nested.rar"), []byte("x"), 0o644); err != nil {
+			if err := os.WriteFile(filepath.Join(req.TmpDir, "nested.rar"), []byte("x"), 0o644); err != nil {
 				return PasswordExtractionResult{}, err
 			}
-			if err := os.WriteFile(filepath.Join(tmpDir, "top.txt"), []byte("top"), 0o644); err != nil {
+			if err := os.WriteFile(filepath.Join(req.TmpDir, "top.txt"), []byte("top"), 0o644); err != nil {
 				return PasswordExtractionResult{}, err
 			}
-			return PasswordExtractionResult{Volumes: []string{archivePath}}, nil
+			return PasswordExtractionResult{Volumes: []string{req.ArchivePath}}, nil
 		case filepath.Join(topTmpDir, "nested.rar"):
-			if err := os.WriteFile(filepath.Join(tmpDir, "nested.txt"), []byte("nested"), 0o644); err != nil {
+			if err := os.WriteFile(filepath.Join(req.TmpDir, "nested.txt"), []byte("nested"), 0o644); err != nil {
 				return PasswordExtractionResult{}, err
 			}
-			return PasswordExtractionResult{Volumes: []string{archivePath}}, nil
+			return PasswordExtractionResult{Volumes: []string{req.ArchivePath}}, nil
 		default:
 			return PasswordExtractionResult{}, errors.New("unexpected archive path")
 		}
@@ -145,7 +162,7 @@ func TestRunRecursesIntoNestedArchives(t *testing.T) {
 	checkAlreadyExtracted = func(_ string, _ string, _ bool, _ ...rardecode.Option) (bool, error) {
 		return false, nil
 	}
-	runCleanupSelection = func(_ []string, _ string, _ string, _ string, _ bool, _ *log.Logger) error {
+	runCleanupSelection = func(_ []string, _ string, _ string, _ string, _ bool, _ func(hooks.Action), _ *log.Logger) error {
 		return nil
 	}
 
@@ -158,7 +175,7 @@ func TestRunRecursesIntoNestedArchives(t *testing.T) {
 		PasswordFile: filepath.Join(root, "passwords.txt"),
 	}
 
-	stats, err := Run(opts, log.New(true, false))
+	stats, err := Run(context.Background(), opts, log.New(true, false))
 	if err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
@@ -205,17 +222,10 @@ func TestRunForceStillRunsHooksAfterExtractionFailure(t *testing.T) {
 	createExtractionTempDir = func(parent string) (string, error) {
 		return os.MkdirTemp(parent, ".tmp-")
 	}
-	extractArchiveWithRetries = func(
-		archivePath string,
-		tmpDir string,
-		_ bool,
-		_ int64,
-		_ bool,
-		_ string,
-	) (PasswordExtractionResult, error) {
+	extractArchiveWithRetries = func(_ ExtractRequest) (PasswordExtractionResult, error) {
 		return PasswordExtractionResult{}, errors.New("decode failed")
 	}
-	runCleanupSelection = func(_ []string, _ string, _ string, _ string, _ bool, _ *log.Logger) error {
+	runCleanupSelection = func(_ []string, _ string, _ string, _ string, _ bool, _ func(hooks.Action), _ *log.Logger) error {
 		hookCalls++
 		return nil
 	}
@@ -230,7 +240,7 @@ func TestRunForceStillRunsHooksAfterExtractionFailure(t *testing.T) {
 		PasswordFile: filepath.Join(root, "passwords.txt"),
 	}
 
-	stats, err := Run(opts, log.New(true, false))
+	stats, err := Run(context.Background(), opts, log.New(true, false))
 	if err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
@@ -296,21 +306,14 @@ func TestRunDepthZeroStillProcessesDeepCandidates(t *testing.T) {
 	validateRarSignature = func(path string) (bool, error) {
 		return true, nil
 	}
-	extractArchiveWithRetries = func(
-		archivePath string,
-		tmpDir string,
-		_ bool,
-		_ int64,
-		_ bool,
-		_ string,
-	) (PasswordExtractionResult, error) {
-		if archivePath != deepArchive {
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.ArchivePath != deepArchive {
 			return PasswordExtractionResult{}, errors.New("unexpected archive path")
 		}
-		if err := os.WriteFile(filepath.Join(tmpDir, "payload.txt"), []byte("ok"), 0o644); err != nil {
+		if err := os.WriteFile(filepath.Join(req.TmpDir, "payload.txt"), []byte("ok"), 0o644); err != nil {
 			return PasswordExtractionResult{}, err
 		}
-		return PasswordExtractionResult{Volumes: []string{archivePath}}, nil
+		return PasswordExtractionResult{Volumes: []string{req.ArchivePath}}, nil
 	}
 
 	opts := cli.Options{
@@ -322,7 +325,7 @@ func TestRunDepthZeroStillProcessesDeepCandidates(t *testing.T) {
 		PasswordFile: filepath.Join(root, "passwords.txt"),
 	}
 
-	stats, err := Run(opts, log.New(true, false))
+	stats, err := Run(context.Background(), opts, log.New(true, false))
 	if err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
@@ -363,14 +366,7 @@ func TestRunDryRunBypassesSkipIfExists(t *testing.T) {
 		skipChecks++
 		return true, nil
 	}
-	extractArchiveWithRetries = func(
-		_ string,
-		_ string,
-		_ bool,
-		_ int64,
-		_ bool,
-		_ string,
-	) (PasswordExtractionResult, error) {
+	extractArchiveWithRetries = func(_ ExtractRequest) (PasswordExtractionResult, error) {
 		t.Fatal("extractArchiveWithRetries should not be called in dry-run mode")
 		return PasswordExtractionResult{}, nil
 	}
@@ -386,7 +382,7 @@ func TestRunDryRunBypassesSkipIfExists(t *testing.T) {
 		PasswordFile: filepath.Join(root, "passwords.txt"),
 	}
 
-	stats, err := Run(opts, log.New(true, false))
+	stats, err := Run(context.Background(), opts, log.New(true, false))
 	if err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
@@ -424,14 +420,7 @@ func TestRunSkipIfExistsChecksArchiveDirWhenOutputDirSet(t *testing.T) {
 		}
 		return true, nil
 	}
-	extractArchiveWithRetries = func(
-		_ string,
-		_ string,
-		_ bool,
-		_ int64,
-		_ bool,
-		_ string,
-	) (PasswordExtractionResult, error) {
+	extractArchiveWithRetries = func(_ ExtractRequest) (PasswordExtractionResult, error) {
 		t.Fatal("extractArchiveWithRetries should not run when skip-if-exists succeeds")
 		return PasswordExtractionResult{}, nil
 	}
@@ -447,7 +436,7 @@ func TestRunSkipIfExistsChecksArchiveDirWhenOutputDirSet(t *testing.T) {
 		PasswordFile: filepath.Join(root, "passwords.txt"),
 	}
 
-	stats, err := Run(opts, log.New(true, false))
+	stats, err := Run(context.Background(), opts, log.New(true, false))
 	if err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
@@ -470,6 +459,19 @@ func stubRunDependencies() func() {
 	oldCheckAlreadyExtracted := checkAlreadyExtracted
 	oldSafeMovePath := safeMovePath
 	oldRunCleanupSelection := runCleanupSelection
+	oldOpenPasswordStore := openPasswordStore
+	oldNewPasswordPrompter := newPasswordPrompter
+	newPasswordPrompter = func() passwords.Prompter { return nil }
+	oldChecksumCachePath := checksumCachePath
+	checksumCachePath = func() string { return "" }
+	oldMapArchiveVolumes := mapArchiveVolumes
+	oldSourceDevice := sourceDevice
+	oldListArchiveEntries := listArchiveEntries
+	oldPlanOutput := planOutput
+	oldRunEventCommand := runEventCommand
+	oldWaitRetry := waitRetry
+	oldResolvePassphrase := resolvePassphrase
+	resolvedPassphrase.value = ""
 
 	return func() {
 		scanCandidates = oldScanCandidates
@@ -479,5 +481,1386 @@ func stubRunDependencies() func() {
 		checkAlreadyExtracted = oldCheckAlreadyExtracted
 		safeMovePath = oldSafeMovePath
 		runCleanupSelection = oldRunCleanupSelection
+		openPasswordStore = oldOpenPasswordStore
+		newPasswordPrompter = oldNewPasswordPrompter
+		checksumCachePath = oldChecksumCachePath
+		mapArchiveVolumes = oldMapArchiveVolumes
+		sourceDevice = oldSourceDevice
+		listArchiveEntries = oldListArchiveEntries
+		planOutput = oldPlanOutput
+		runEventCommand = oldRunEventCommand
+		waitRetry = oldWaitRetry
+		resolvePassphrase = oldResolvePassphrase
+		resolvedPassphrase.value = ""
+	}
+}
+
+func TestRunRemembersPasswordWithoutLoggingIt(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "secret.rar")
+	if err := os.WriteFile(archivePath, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	storePath := filepath.Join(t.TempDir(), "passwords.json")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		switch {
+		case dir == root:
+			return []finder.Candidate{{Path: archivePath, Stem: "secret"}}, nil
+		case filepath.Dir(dir) == root:
+			return []finder.Candidate{{Path: filepath.Join(dir, "inner.rar"), Stem: "inner"}}, nil
+		default:
+			return nil, nil
+		}
+	}
+	validateRarSignature = func(path string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.Store == nil {
+			t.Fatal("expected password store to be passed to extraction")
+		}
+		return PasswordExtractionResult{
+			Volumes:        []string{req.ArchivePath},
+			UsedPassword:   true,
+			Password:       "hunter2",
+			PasswordSource: "password file",
+		}, nil
+	}
+
+	opts := cli.Options{
+		Dir:           root,
+		Depth:         1,
+		CKSFV:         false,
+		CleanHooks:    []string{"none"},
+		MaxDictBytes:  1 << 20,
+		PasswordFile:  filepath.Join(root, "passwords.txt"),
+		PasswordStore: storePath,
+	}
+
+	var infoBuf bytes.Buffer
+	var errBuf bytes.Buffer
+	stats, err := Run(context.Background(), opts, log.NewWithWriters(false, true, &infoBuf, &errBuf))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if stats.ArchivesExtracted != 2 {
+		t.Fatalf("ArchivesExtracted=%d, want the set and its nested set", stats.ArchivesExtracted)
+	}
+
+	output := infoBuf.String() + errBuf.String()
+	if strings.Contains(output, "hunter2") {
+		t.Fatalf("log output contains password: %q", output)
+	}
+	if !strings.Contains(output, "using a password from password file") {
+		t.Fatalf("expected password source in verbose output, got %q", output)
+	}
+
+	store, err := passwords.OpenStore(storePath)
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	ordered := store.Order(passwords.TargetFor(archivePath), []passwords.Candidate{
+		{Password: "other"},
+		{Password: "hunter2"},
+	})
+	if ordered[0].Password != "hunter2" {
+		t.Fatalf("expected remembered password first, got %v", ordered)
+	}
+
+	raw, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	var data struct {
+		Sets map[string]string `json:"sets"`
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("decode store: %v", err)
+	}
+	if _, ok := data.Sets[archivePath]; !ok || len(data.Sets) != 1 {
+		t.Fatalf("store sets=%v, want only the top-level set", data.Sets)
+	}
+}
+
+func TestVerifyChecksumsPrefersStrongestManifest(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "set.rar"), []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	// The SFV entry is correct, but the stronger SHA-256 manifest disagrees
+	// and must be the one verified.
+	if err := os.WriteFile(filepath.Join(root, "set.sfv"), []byte("set.rar b99acdde\n"), 0o644); err != nil {
+		t.Fatalf("write sfv: %v", err)
+	}
+	sha := strings.Repeat("0", 64) + "  set.rar\n"
+	if err := os.WriteFile(filepath.Join(root, "set.sha256"), []byte(sha), 0o644); err != nil {
+		t.Fatalf("write sha256: %v", err)
+	}
+
+	r := &runner{opts: cli.Options{CKSFV: true}, log: log.New(true, false)}
+	_, err := r.verifyChecksumsIfPresent(finder.Candidate{Path: filepath.Join(root, "set.rar"), Stem: "set"})
+	var verificationErr *checksum.VerificationError
+	if !errors.As(err, &verificationErr) {
+		t.Fatalf("error=%v, want *checksum.VerificationError", err)
+	}
+	if verificationErr.Algorithm != checksum.SHA256 {
+		t.Fatalf("verified algorithm=%q, want %q", verificationErr.Algorithm, checksum.SHA256)
+	}
+}
+
+func TestVerifyChecksumsUsesSharedManifestLinesForSet(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "set.rar"), []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	// The release-wide SFV also covers another set whose line is wrong; only
+	// the lines for this set's volumes may be verified.
+	sfv := "set.rar b99acdde\nother.rar 00000000\n"
+	if err := os.WriteFile(filepath.Join(root, "release.sfv"), []byte(sfv), 0o644); err != nil {
+		t.Fatalf("write sfv: %v", err)
+	}
+
+	r := &runner{opts: cli.Options{CKSFV: true}, log: log.New(true, false)}
+	if _, err := r.verifyChecksumsIfPresent(finder.Candidate{Path: filepath.Join(root, "set.rar"), Stem: "set"}); err != nil {
+		t.Fatalf("verifyChecksumsIfPresent returned error: %v", err)
+	}
+	_, err := r.verifyChecksumsIfPresent(finder.Candidate{Path: filepath.Join(root, "other.rar"), Stem: "other"})
+	var verificationErr *checksum.VerificationError
+	if !errors.As(err, &verificationErr) {
+		t.Fatalf("error=%v, want *checksum.VerificationError", err)
+	}
+}
+
+func TestRunSinglePassDiscardsOutputOnChecksumFailure(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "set.rar")
+	if err := os.WriteFile(archivePath, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "set.sfv"), []byte("set.rar 00000000\n"), 0o644); err != nil {
+		t.Fatalf("write sfv: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(_ string, _ int) ([]finder.Candidate, error) {
+		return []finder.Candidate{{Path: archivePath, Stem: "set"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extracted := false
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		extracted = true
+		if req.HashAlgorithm != checksum.CRC32 {
+			t.Fatalf("HashAlgorithm=%q, want %q", req.HashAlgorithm, checksum.CRC32)
+		}
+		if err := os.WriteFile(filepath.Join(req.TmpDir, "payload.mkv"), []byte("x"), 0o644); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		return PasswordExtractionResult{
+			Volumes:    []string{req.ArchivePath},
+			VolumeSums: map[string][]byte{req.ArchivePath: {0xb9, 0x9a, 0xcd, 0xde}},
+		}, nil
+	}
+
+	opts := cli.Options{
+		Dir:              root,
+		Depth:            0,
+		CKSFV:            true,
+		SinglePassVerify: true,
+		CleanHooks:       []string{"none"},
+		MaxDictBytes:     1 << 20,
+	}
+	stats, err := Run(context.Background(), opts, log.NewWithWriters(false, false, &bytes.Buffer{}, &bytes.Buffer{}))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if !extracted {
+		t.Fatal("single-pass mode must not block extraction on up-front verification")
+	}
+	if stats.Failures != 1 || stats.ArchivesExtracted != 0 {
+		t.Fatalf("stats=%+v, want one failure", stats)
+	}
+	if _, err := os.Stat(filepath.Join(root, "payload.mkv")); !os.IsNotExist(err) {
+		t.Fatalf("extracted output was moved despite checksum failure (stat err=%v)", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("read root: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("root contains %d entries, want only the archive and manifest", len(entries))
+	}
+}
+
+func TestRunWritesExtractedManifestWithFinalNames(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "set.rar")
+	if err := os.WriteFile(archivePath, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	// An earlier file forces the extracted payload onto a suffixed name.
+	if err := os.WriteFile(filepath.Join(root, "payload.mkv"), []byte("old"), 0o644); err != nil {
+		t.Fatalf("write existing payload: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(_ string, _ int) ([]finder.Candidate, error) {
+		return []finder.Candidate{{Path: archivePath, Stem: "set"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	// Deliberately not the real CRC of "new", to prove the sum from
+	// extraction is used instead of re-reading the file.
+	extractedSum := []byte{0x01, 0x02, 0x03, 0x04}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.FileHashAlgorithm != checksum.CRC32 {
+			t.Fatalf("FileHashAlgorithm=%q, want %q", req.FileHashAlgorithm, checksum.CRC32)
+		}
+		if err := os.WriteFile(filepath.Join(req.TmpDir, "payload.mkv"), []byte("new"), 0o644); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		if err := os.MkdirAll(filepath.Join(req.TmpDir, "extras"), 0o755); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		if err := os.WriteFile(filepath.Join(req.TmpDir, "extras", "unhashed.txt"), []byte("x"), 0o644); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		return PasswordExtractionResult{
+			Volumes:  []string{req.ArchivePath},
+			FileSums: map[string][]byte{"payload.mkv": extractedSum},
+		}, nil
+	}
+
+	opts := cli.Options{
+		Dir:           root,
+		CleanHooks:    []string{"none"},
+		MaxDictBytes:  1 << 20,
+		WriteManifest: checksum.CRC32,
+	}
+	stats, err := Run(context.Background(), opts, log.NewWithWriters(false, false, &bytes.Buffer{}, &bytes.Buffer{}))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if stats.Failures != 0 || stats.ArchivesExtracted != 1 {
+		t.Fatalf("stats=%+v, want one extraction", stats)
+	}
+
+	manifest, err := checksum.ParseFile(filepath.Join(root, "set.extracted.sfv"))
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+	want := []checksum.Entry{
+		{Name: "extras/unhashed.txt", Sum: []byte{0x8c, 0xdc, 0x16, 0x83}},
+		{Name: "payload.mkv.1", Sum: extractedSum},
+	}
+	if len(manifest.Entries) != len(want) {
+		t.Fatalf("entries=%+v, want %+v", manifest.Entries, want)
+	}
+	for i := range want {
+		if manifest.Entries[i].Name != want[i].Name || !bytes.Equal(manifest.Entries[i].Sum, want[i].Sum) {
+			t.Fatalf("entries[%d]=%+v, want %+v", i, manifest.Entries[i], want[i])
+		}
+	}
+}
+
+func TestRunLeavesFilesRemovedByHooksOutOfExtractedManifest(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "set.rar")
+	if err := os.WriteFile(archivePath, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(_ string, _ int) ([]finder.Candidate, error) {
+		return []finder.Candidate{{Path: archivePath, Stem: "set"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		for _, name := range []string{"set.mkv", "sample-set.mkv", filepath.Join("Sample", "clip.mkv")} {
+			path := filepath.Join(req.TmpDir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return PasswordExtractionResult{}, err
+			}
+			if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+				return PasswordExtractionResult{}, err
+			}
+		}
+		return PasswordExtractionResult{Volumes: []string{req.ArchivePath}}, nil
+	}
+
+	opts := cli.Options{
+		Dir:           root,
+		CleanHooks:    []string{"sample_videos", "sample_folders"},
+		MaxDictBytes:  1 << 20,
+		WriteManifest: checksum.CRC32,
+	}
+	stats, err := Run(context.Background(), opts, log.NewWithWriters(false, false, &bytes.Buffer{}, &bytes.Buffer{}))
+	if err != nil || stats.Failures != 0 || stats.ArchivesExtracted != 1 {
+		t.Fatalf("stats=%+v err=%v, want one extraction", stats, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sample-set.mkv")); !os.IsNotExist(err) {
+		t.Fatalf("sample video was not removed: %v", err)
+	}
+
+	manifest, err := checksum.ParseFile(filepath.Join(root, "set.extracted.sfv"))
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+	if len(manifest.Entries) != 1 || manifest.Entries[0].Name != "set.mkv" {
+		t.Fatalf("entries=%+v, want only set.mkv", manifest.Entries)
+	}
+	var stderr bytes.Buffer
+	if err := RunVerify(cli.VerifyOptions{Dir: root}, io.Discard, &stderr); err != nil {
+		t.Fatalf("RunVerify returned error: %v (%s)", err, stderr.String())
+	}
+}
+
+func TestRunFailsCandidateWhenCrossDeviceCopyMismatches(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "set.rar")
+	if err := os.WriteFile(archivePath, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(_ string, _ int) ([]finder.Candidate, error) {
+		return []finder.Candidate{{Path: archivePath, Stem: "set"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if err := os.WriteFile(filepath.Join(req.TmpDir, "payload.mkv"), []byte("x"), 0o644); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		return PasswordExtractionResult{
+			Volumes:  []string{req.ArchivePath},
+			FileSums: map[string][]byte{"payload.mkv": {0x8c, 0xdc, 0x16, 0x83}},
+		}, nil
+	}
+	// Simulate a cross-device fallback whose copy comes out corrupted.
+	safeMovePath = func(_ context.Context, src, dst string, verify func(string) error) (string, error) {
+		if err := os.WriteFile(dst, []byte("y"), 0o644); err != nil {
+			return "", err
+		}
+		if err := verify(dst); err != nil {
+			_ = os.Remove(dst)
+			return "", &fsutil.CopyVerificationError{Src: src, Dst: dst, Err: err}
+		}
+		return dst, os.Remove(src)
+	}
+	hookCalls := 0
+	runCleanupSelection = func(_ []string, _ string, _ string, _ string, _ bool, _ func(hooks.Action), _ *log.Logger) error {
+		hookCalls++
+		return nil
+	}
+
+	opts := cli.Options{
+		Dir:          root,
+		CleanHooks:   []string{"rar"},
+		MaxDictBytes: 1 << 20,
+	}
+	var stderr bytes.Buffer
+	stats, err := Run(context.Background(), opts, log.NewWithWriters(false, false, &bytes.Buffer{}, &stderr))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if stats.Failures != 1 || stats.ArchivesExtracted != 0 {
+		t.Fatalf("stats=%+v, want one failure", stats)
+	}
+	if hookCalls != 0 {
+		t.Fatalf("cleanup hooks ran %d time(s) after a failed copy", hookCalls)
+	}
+	if _, err := os.Stat(filepath.Join(root, "payload.mkv")); !os.IsNotExist(err) {
+		t.Fatalf("mismatched copy was left at the destination (stat err=%v)", err)
+	}
+	if !strings.Contains(stderr.String(), "crc32 mismatch") {
+		t.Fatalf("stderr=%q, want the mismatch reported", stderr.String())
+	}
+}
+
+func TestRunReportsEntriesSpanningBadVolumes(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "set.part1.rar")
+	for _, name := range []string{"set.part1.rar", "set.part2.rar"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("volume"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	sfv := "set.part1.rar b99acdde\nset.part2.rar 00000000\nset.part3.rar 00000000\nset.nfo 00000000\n"
+	if err := os.WriteFile(filepath.Join(root, "set.sfv"), []byte(sfv), 0o644); err != nil {
+		t.Fatalf("write sfv: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(_ string, _ int) ([]finder.Candidate, error) {
+		return []finder.Candidate{{Path: archivePath, Stem: "set"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	mapArchiveVolumes = func(string) (rar.VolumeMap, error) {
+		return rar.VolumeMap{
+			Volumes: []string{"set.part1.rar", "set.part2.rar", "set.part3.rar"},
+			Entries: []rar.EntrySpan{
+				{Name: "sample.mkv", FirstVolume: 0, LastVolume: 0},
+				{Name: "movie.mkv", FirstVolume: 0, LastVolume: 2},
+			},
+		}, nil
+	}
+
+	opts := cli.Options{
+		Dir:          root,
+		CKSFV:        true,
+		CleanHooks:   []string{"none"},
+		MaxDictBytes: 1 << 20,
+	}
+	var stderr bytes.Buffer
+	stats, err := Run(context.Background(), opts, log.NewWithWriters(false, false, &bytes.Buffer{}, &stderr))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if stats.Failures != 1 {
+		t.Fatalf("stats=%+v, want one failure", stats)
+	}
+	for _, want := range []string{
+		"set.part3.rar: missing (volume 3 of this set; entries that may span it: movie.mkv)",
+		"set.nfo: missing\n",
+		"set.part2.rar: mismatch: expected 00000000, got b99acdde (volume 2 of this set; entries that may span it: movie.mkv)",
+		"entries clear of bad volumes (recoverable with --force): sample.mkv",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("stderr=%q, want it to contain %q", stderr.String(), want)
+		}
+	}
+}
+
+func TestRunJobsLimitsConcurrencyPerDevice(t *testing.T) {
+	root := t.TempDir()
+	var candidates []finder.Candidate
+	for _, name := range []string{"ssd1", "hdd1", "ssd2", "hdd2", "ssd3"} {
+		dir := filepath.Join(root, name[:3])
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		path := filepath.Join(dir, name+".rar")
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatalf("write archive: %v", err)
+		}
+		candidates = append(candidates, finder.Candidate{Path: path, Stem: name})
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return candidates, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	sourceDevice = func(dir string) (fsutil.Device, error) {
+		if filepath.Base(dir) == "ssd" {
+			return fsutil.Device{ID: "ssd", SolidState: true}, nil
+		}
+		return fsutil.Device{ID: "hdd"}, nil
+	}
+
+	var (
+		mu         sync.Mutex
+		active     = map[string]int{}
+		peak       = map[string]int{}
+		ssdOverlap = make(chan struct{})
+		overlapped sync.Once
+	)
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		device := filepath.Base(filepath.Dir(req.ArchivePath))
+		mu.Lock()
+		active[device]++
+		peak[device] = max(peak[device], active[device])
+		if device == "ssd" && active[device] == 2 {
+			overlapped.Do(func() { close(ssdOverlap) })
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active[device]--
+			mu.Unlock()
+		}()
+
+		if device == "ssd" {
+			select {
+			case <-ssdOverlap:
+			case <-time.After(5 * time.Second):
+				return PasswordExtractionResult{}, errors.New("solid-state candidates never overlapped")
+			}
+		}
+		name := strings.TrimSuffix(filepath.Base(req.ArchivePath), ".rar") + ".mkv"
+		if err := os.WriteFile(filepath.Join(req.TmpDir, name), []byte("x"), 0o644); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		return PasswordExtractionResult{Volumes: []string{req.ArchivePath}}, nil
+	}
+
+	opts := cli.Options{
+		Dir:          root,
+		CleanHooks:   []string{"none"},
+		MaxDictBytes: 1 << 20,
+		Jobs:         3,
+	}
+	var stdout bytes.Buffer
+	stats, err := Run(context.Background(), opts, log.NewWithWriters(false, true, &stdout, &stdout))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if stats.ArchivesFound != 5 || stats.ArchivesExtracted != 5 || stats.Failures != 0 {
+		t.Fatalf("stats=%+v, want 5 found and extracted", stats)
+	}
+	if peak["hdd"] != 1 {
+		t.Fatalf("peak concurrent hdd candidates=%d, want 1", peak["hdd"])
+	}
+	if peak["ssd"] < 2 {
+		t.Fatalf("peak concurrent ssd candidates=%d, want >= 2", peak["ssd"])
+	}
+	for _, candidate := range candidates {
+		want := "[" + candidate.Stem + "] Extracted " + strconv.Quote(candidate.Path)
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("output=%q, want it to contain %q", stdout.String(), want)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(candidate.Path), candidate.Stem+".mkv")); err != nil {
+			t.Fatalf("extracted file for %s missing: %v", candidate.Stem, err)
+		}
+	}
+}
+
+func TestRunSkipJournalSkipsSetsExtractedBefore(t *testing.T) {
+	root := t.TempDir()
+	output := t.TempDir()
+	archivePath := filepath.Join(root, "set.rar")
+	if err := os.WriteFile(archivePath, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: archivePath, Stem: "set"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractions := 0
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		extractions++
+		if err := os.WriteFile(filepath.Join(req.TmpDir, "movie.mkv"), []byte("x"), 0o644); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		return PasswordExtractionResult{Volumes: []string{"set.rar"}}, nil
+	}
+
+	opts := cli.Options{
+		Dir:          root,
+		OutputDir:    output,
+		CleanHooks:   []string{"none"},
+		MaxDictBytes: 1 << 20,
+		SkipJournal:  true,
+		Journal:      filepath.Join(t.TempDir(), "journal.jsonl"),
+	}
+	run := func() Stats {
+		t.Helper()
+		stats, err := Run(context.Background(), opts, log.New(true, false))
+		if err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		return stats
+	}
+
+	if stats := run(); stats.ArchivesExtracted != 1 {
+		t.Fatalf("first run stats=%+v, want one extraction", stats)
+	}
+	j, err := journal.Open(opts.Journal)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	entry, ok := j.Lookup(archivePath)
+	if !ok {
+		t.Fatal("journal has no entry for the set")
+	}
+	wantFile := filepath.Join(output, "movie.mkv")
+	if entry.Outcome != journal.Extracted || entry.Destination != output || len(entry.Files) != 1 || entry.Files[0] != wantFile {
+		t.Fatalf("entry=%+v, want extracted to %q with file %q", entry, output, wantFile)
+	}
+
+	// The output was moved elsewhere; the journal still knows the set.
+	if err := os.Remove(wantFile); err != nil {
+		t.Fatalf("remove output: %v", err)
+	}
+	if stats := run(); stats.ArchivesSkipped != 1 || extractions != 1 {
+		t.Fatalf("second run stats=%+v after %d extraction(s), want a journal skip", stats, extractions)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(archivePath, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if stats := run(); stats.ArchivesExtracted != 1 || extractions != 2 {
+		t.Fatalf("third run stats=%+v after %d extraction(s), want the changed set extracted again", stats, extractions)
+	}
+}
+
+func TestRunJournalsSetsFailedAfterPlacementAsFailed(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail makes the first run fail after the files are placed.
+		fail func(opts *cli.Options, failing *bool)
+	}{
+		{
+			name: "cleanup hooks",
+			fail: func(opts *cli.Options, failing *bool) {
+				opts.CleanHooks = []string{"rar"}
+				runCleanupSelection = func(_ []string, _ string, _ string, _ string, _ bool, _ func(hooks.Action), _ *log.Logger) error {
+					if *failing {
+						return errors.New("permission denied")
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name: "on-extracted command",
+			fail: func(opts *cli.Options, failing *bool) {
+				opts.OnExtracted = "index"
+				runEventCommand = func(context.Context, hooks.EventCommand, hooks.Event) error {
+					if *failing {
+						return errors.New("exited with status 1")
+					}
+					return nil
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			archivePath := filepath.Join(root, "set.rar")
+			if err := os.WriteFile(archivePath, []byte("volume"), 0o644); err != nil {
+				t.Fatalf("write archive: %v", err)
+			}
+
+			restore := stubRunDependencies()
+			defer restore()
+
+			scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+				if dir != root {
+					return nil, nil
+				}
+				return []finder.Candidate{{Path: archivePath, Stem: "set"}}, nil
+			}
+			validateRarSignature = func(string) (bool, error) {
+				return true, nil
+			}
+			extractions := 0
+			extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+				extractions++
+				return PasswordExtractionResult{Volumes: []string{"set.rar"}}, os.WriteFile(filepath.Join(req.TmpDir, "movie.mkv"), []byte("x"), 0o644)
+			}
+
+			opts := cli.Options{
+				Dir:          root,
+				OutputDir:    t.TempDir(),
+				CleanHooks:   []string{"none"},
+				MaxDictBytes: 1 << 20,
+				SkipJournal:  true,
+				Journal:      filepath.Join(t.TempDir(), "journal.jsonl"),
+			}
+			failing := true
+			tt.fail(&opts, &failing)
+
+			stats, err := Run(context.Background(), opts, log.New(true, false))
+			if err != nil || stats.Failures != 1 {
+				t.Fatalf("first run stats=%+v err=%v, want the set failed", stats, err)
+			}
+			j, err := journal.Open(opts.Journal)
+			if err != nil {
+				t.Fatalf("open journal: %v", err)
+			}
+			if entry, ok := j.Lookup(archivePath); !ok || entry.Outcome != journal.Failed {
+				t.Fatalf("entry=%+v, want the set journaled as failed", entry)
+			}
+
+			failing = false
+			stats, err = Run(context.Background(), opts, log.New(true, false))
+			if err != nil || stats.ArchivesExtracted != 1 || extractions != 2 {
+				t.Fatalf("second run stats=%+v err=%v after %d extraction(s), want the set extracted again", stats, err, extractions)
+			}
+		})
+	}
+}
+
+func TestRunKeepsNestedVolumesOutOfChecksumCache(t *testing.T) {
+	root := t.TempDir()
+	output := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "checksums.json")
+	writeSet := func(dir, stem string) error {
+		volume := []byte(stem + " volume")
+		if err := os.WriteFile(filepath.Join(dir, stem+".rar"), volume, 0o644); err != nil {
+			return err
+		}
+		line := fmt.Sprintf("%s.rar %08X\n", stem, crc32.ChecksumIEEE(volume))
+		return os.WriteFile(filepath.Join(dir, stem+".sfv"), []byte(line), 0o644)
+	}
+	if err := writeSet(root, "outer"); err != nil {
+		t.Fatalf("write outer set: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	checksumCachePath = func() string { return cachePath }
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		stem := "inner"
+		if dir == root {
+			stem = "outer"
+		}
+		if _, err := os.Stat(filepath.Join(dir, stem+".rar")); err != nil {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: filepath.Join(dir, stem+".rar"), Stem: stem}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if filepath.Base(req.ArchivePath) == "outer.rar" {
+			return PasswordExtractionResult{}, writeSet(req.TmpDir, "inner")
+		}
+		return PasswordExtractionResult{}, os.WriteFile(filepath.Join(req.TmpDir, "movie.mkv"), []byte("x"), 0o644)
+	}
+
+	opts := cli.Options{Dir: root, OutputDir: output, Depth: 1, CKSFV: true, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20}
+	stats, err := Run(context.Background(), opts, log.New(true, false))
+	if err != nil || stats.Failures != 0 || stats.ArchivesExtracted != 2 {
+		t.Fatalf("stats=%+v err=%v, want both sets extracted", stats, err)
+	}
+
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("read checksum cache: %v", err)
+	}
+	if !strings.Contains(string(data), "outer.rar") {
+		t.Fatalf("cache=%s, want the top-level volume cached", data)
+	}
+	if strings.Contains(string(data), "inner.rar") {
+		t.Fatalf("cache=%s, want no nested volumes", data)
+	}
+}
+
+func TestRunWritesReport(t *testing.T) {
+	root := t.TempDir()
+	good := filepath.Join(root, "good.rar")
+	locked := filepath.Join(root, "locked.rar")
+	for _, path := range []string{good, locked} {
+		if err := os.WriteFile(path, []byte("volume"), 0o644); err != nil {
+			t.Fatalf("write archive: %v", err)
+		}
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: good, Stem: "good"}, {Path: locked, Stem: "locked"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.ArchivePath == locked {
+			return PasswordExtractionResult{}, &PasswordRequiredError{ArchivePath: locked, Cause: rardecode.ErrArchiveEncrypted}
+		}
+		if err := os.WriteFile(filepath.Join(req.TmpDir, "movie.mkv"), []byte("movie"), 0o644); err != nil {
+			return PasswordExtractionResult{}, err
+		}
+		return PasswordExtractionResult{
+			Volumes:        []string{"good.rar"},
+			UsedPassword:   true,
+			Password:       "hunter2",
+			PasswordSource: "password.txt",
+		}, nil
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	stats, err := Run(context.Background(), cli.Options{
+		Dir:          root,
+		CleanHooks:   []string{"rar"},
+		MaxDictBytes: 1 << 20,
+		Report:       reportPath,
+	}, log.New(true, false))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	if bytes.Contains(data, []byte("hunter2")) {
+		t.Fatalf("report contains the password:\n%s", data)
+	}
+	var doc report.Report
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if doc.Summary.Extracted != stats.ArchivesExtracted || doc.Summary.Failures != stats.Failures || len(doc.Candidates) != 2 {
+		t.Fatalf("summary=%+v with %d candidate(s), want stats %+v and 2 candidates", doc.Summary, len(doc.Candidates), stats)
+	}
+
+	extracted := doc.Candidates[0]
+	wantFile := report.File{Path: filepath.Join(root, "movie.mkv"), Size: 5}
+	if extracted.Outcome != report.Extracted || len(extracted.Files) != 1 || extracted.Files[0] != wantFile {
+		t.Fatalf("extracted record=%+v, want file %+v", extracted, wantFile)
+	}
+	if extracted.Password != (report.Password{Required: true, Source: "password.txt"}) || extracted.BytesRead != 6 || extracted.BytesWritten != 5 {
+		t.Fatalf("extracted record=%+v, want password from password.txt, 6 bytes read, 5 written", extracted)
+	}
+	if extracted.Checksum.Result != report.ChecksumDisabled {
+		t.Fatalf("checksum=%+v, want disabled", extracted.Checksum)
+	}
+	wantAction := hooks.Action{Hook: "rar", Kind: "remove_file", Path: good}
+	if len(extracted.Hooks) != 1 || extracted.Hooks[0] != wantAction {
+		t.Fatalf("hooks=%+v, want [%+v]", extracted.Hooks, wantAction)
+	}
+
+	failed := doc.Candidates[1]
+	if failed.Outcome != report.Failed || failed.Error == nil || failed.Error.Class != report.ClassPassword || !failed.Password.Required {
+		t.Fatalf("failed record=%+v, want a password failure", failed)
+	}
+}
+
+func TestRunDryRunWritesPlan(t *testing.T) {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "release.rar")
+	for _, name := range []string{"release.rar", "movie.mkv"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("x"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(string, int) ([]finder.Candidate, error) {
+		return []finder.Candidate{{Path: archivePath, Stem: "release"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(ExtractRequest) (PasswordExtractionResult, error) {
+		t.Fatal("extractArchiveWithRetries should not be called in dry-run mode")
+		return PasswordExtractionResult{}, nil
+	}
+	listArchiveEntries = func(fsys fs.FS, path string, want func(rar.ListedFile) bool, _ int64, _ ...rardecode.Option) ([]rar.ListedFile, map[string][]byte, error) {
+		if fsys == nil {
+			files := []rar.ListedFile{
+				{Name: "Release/movie.mkv", Size: 100},
+				{Name: "Release/release.nfo", Size: 10},
+				{Name: "Release/Subs/subs.rar", Size: 4},
+			}
+			if !want(files[2]) || want(files[0]) {
+				t.Fatalf("want selected the wrong entries for reading")
+			}
+			return files, map[string][]byte{"Release/Subs/subs.rar": []byte("Rar!")}, nil
+		}
+		if _, err := fs.Stat(fsys, path); err != nil || path != "subs.rar" {
+			t.Fatalf("nested listing of %q: %v", path, err)
+		}
+		return []rar.ListedFile{{Name: "eng.srt", Size: 3}}, nil, nil
+	}
+	var out bytes.Buffer
+	planOutput = &out
+
+	_, err := Run(context.Background(), cli.Options{
+		Dir:          root,
+		Depth:        1,
+		DryRun:       true,
+		Plan:         plan.JSON,
+		CleanHooks:   []string{"nfo", "rar"},
+		MaxDictBytes: 1 << 20,
+	}, log.New(true, false))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	var doc plan.Plan
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("decode plan: %v\n%s", err, out.String())
+	}
+	if len(doc.Sets) != 1 {
+		t.Fatalf("sets=%+v, want one", doc.Sets)
+	}
+	set := doc.Sets[0]
+	wantFiles := []plan.File{
+		{Entry: "Release/movie.mkv", Target: filepath.Join(root, "movie.mkv.1"), Size: 100, Renamed: true},
+		{Entry: "Release/release.nfo", Target: filepath.Join(root, "release.nfo"), Size: 10},
+		{Entry: "Release/Subs/subs.rar", Target: filepath.Join(root, "subs.rar"), Size: 4},
+	}
+	if set.Error != "" || !reflect.DeepEqual(set.Files, wantFiles) {
+		t.Fatalf("files=%+v (error %q), want %+v", set.Files, set.Error, wantFiles)
+	}
+	if len(set.Nested) != 1 || len(set.Nested[0].Files) != 1 || set.Nested[0].Files[0].Target != filepath.Join(root, "eng.srt") {
+		t.Fatalf("nested=%+v, want subs.rar extracting eng.srt to %q", set.Nested, root)
+	}
+	wantHooks := []hooks.Action{
+		{Hook: "nfo", Kind: "remove_file", Path: filepath.Join(root, "release.nfo"), DryRun: true},
+		{Hook: "rar", Kind: "remove_file", Path: archivePath, DryRun: true},
+	}
+	if !reflect.DeepEqual(set.Hooks, wantHooks) {
+		t.Fatalf("hooks=%+v, want %+v", set.Hooks, wantHooks)
+	}
+	if _, err := os.Stat(filepath.Join(root, "release.nfo")); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote files: stat err=%v", err)
+	}
+}
+
+func TestRunEventCommandsFeedCandidateOutcome(t *testing.T) {
+	root := t.TempDir()
+	output := t.TempDir()
+	good := filepath.Join(root, "good.rar")
+	bad := filepath.Join(root, "bad.rar")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: bad, Stem: "bad"}, {Path: good, Stem: "good"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.ArchivePath == bad {
+			return PasswordExtractionResult{}, rardecode.ErrBadHeaderCRC
+		}
+		return PasswordExtractionResult{}, os.WriteFile(filepath.Join(req.TmpDir, "movie.mkv"), []byte("x"), 0o644)
+	}
+	var events []hooks.Event
+	runEventCommand = func(_ context.Context, command hooks.EventCommand, event hooks.Event) error {
+		if command.Timeout != time.Minute {
+			t.Fatalf("Timeout=%s, want 1m", command.Timeout)
+		}
+		events = append(events, event)
+		if command.Command == "index" {
+			return errors.New("exited with status 2")
+		}
+		return nil
+	}
+
+	stats, err := Run(context.Background(), cli.Options{
+		Dir:            root,
+		OutputDir:      output,
+		CleanHooks:     []string{"none"},
+		MaxDictBytes:   1 << 20,
+		OnExtracted:    "index",
+		OnFailed:       "alert",
+		OnFinished:     "log",
+		CommandTimeout: time.Minute,
+	}, log.New(true, false))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if stats.ArchivesExtracted != 0 || stats.Failures != 2 {
+		t.Fatalf("stats=%+v, want the failing --on-extracted command to fail the good set", stats)
+	}
+
+	want := []hooks.Event{
+		{Name: "failed", Archive: bad, Stem: "bad", Destination: output, Status: "failed", ErrorClass: "corrupt", Error: rardecode.ErrBadHeaderCRC.Error()},
+		{Name: "finished", Archive: bad, Stem: "bad", Destination: output, Status: "failed", ErrorClass: "corrupt", Error: rardecode.ErrBadHeaderCRC.Error()},
+		{Name: "extracted", Archive: good, Stem: "good", Destination: output, Files: []string{filepath.Join(output, "movie.mkv")}, Status: "extracted"},
+		{Name: "finished", Archive: good, Stem: "good", Destination: output, Files: []string{filepath.Join(output, "movie.mkv")}, Status: "failed", ErrorClass: "command", Error: "--on-extracted: exited with status 2"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events=%+v\nwant %+v", events, want)
+	}
+}
+
+func TestRunPostsWebhookEvents(t *testing.T) {
+	root := t.TempDir()
+	output := t.TempDir()
+	good := filepath.Join(root, "good.rar")
+	bad := filepath.Join(root, "bad.rar")
+
+	var (
+		mu     sync.Mutex
+		events []webhook.Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event webhook.Event
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+		// A failing endpoint must not fail the run.
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: bad, Stem: "bad"}, {Path: good, Stem: "good"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.ArchivePath == bad {
+			return PasswordExtractionResult{}, rardecode.ErrBadHeaderCRC
+		}
+		return PasswordExtractionResult{}, os.WriteFile(filepath.Join(req.TmpDir, "movie.mkv"), []byte("x"), 0o644)
+	}
+
+	stats, err := Run(context.Background(), cli.Options{
+		Dir:            root,
+		OutputDir:      output,
+		CleanHooks:     []string{"none"},
+		MaxDictBytes:   1 << 20,
+		Webhook:        srv.URL,
+		WebhookTimeout: time.Second,
+	}, log.New(true, false))
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if stats.ArchivesExtracted != 1 || stats.Failures != 1 {
+		t.Fatalf("stats=%+v, want webhook failures to leave outcomes alone", stats)
+	}
+
+	// Run waits for deliveries before returning.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 3 {
+		t.Fatalf("received %d events, want 3", len(events))
+	}
+	if e := events[0]; e.Type != webhook.CandidateFailed || e.Candidate.Path != bad || e.Candidate.Error.Class != report.ClassCorrupt {
+		t.Fatalf("first event=%+v, want the corrupt failure of bad.rar", e)
+	}
+	if e := events[1]; e.Type != webhook.CandidateExtracted || e.Candidate.Path != good || len(e.Candidate.Files) != 1 {
+		t.Fatalf("second event=%+v, want good.rar extracted with its file", e)
+	}
+	if e := events[2]; e.Type != webhook.RunFinished || e.Dir != root || *e.Summary != (report.Summary{Found: 2, Extracted: 1, Failures: 1}) {
+		t.Fatalf("last event=%+v, want the run summary", e)
+	}
+}
+
+func TestRunWritesMetricsFile(t *testing.T) {
+	root := t.TempDir()
+	output := t.TempDir()
+	metricsFile := filepath.Join(t.TempDir(), "unrarall.prom")
+	good := filepath.Join(root, "good.rar")
+	bad := filepath.Join(root, "bad.rar")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: bad, Stem: "bad"}, {Path: good, Stem: "good"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.ArchivePath == bad {
+			return PasswordExtractionResult{}, rardecode.ErrBadHeaderCRC
+		}
+		return PasswordExtractionResult{UsedPassword: true, PasswordSource: "password file", PasswordAttempts: 2}, os.WriteFile(filepath.Join(req.TmpDir, "movie.mkv"), []byte("12345"), 0o644)
+	}
+
+	if _, err := Run(context.Background(), cli.Options{
+		Dir:          root,
+		OutputDir:    output,
+		CleanHooks:   []string{"none"},
+		MaxDictBytes: 1 << 20,
+		MetricsFile:  metricsFile,
+	}, log.New(true, false)); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(metricsFile)
+	if err != nil {
+		t.Fatalf("read metrics file: %v", err)
+	}
+	for _, want := range []string{
+		"unrarall_archives_found_total 2\n",
+		"unrarall_archives_extracted_total 1\n",
+		"unrarall_archives_failed_total{class=\"corrupt\"} 1\n",
+		"unrarall_written_bytes_total 5\n",
+		"unrarall_password_attempts_total{result=\"failure\"} 1\n",
+		"unrarall_password_attempts_total{result=\"success\"} 1\n",
+		"unrarall_runs_total 1\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("metrics file missing %q:\n%s", want, data)
+		}
+	}
+}
+
+type recordingSink struct {
+	mu         sync.Mutex
+	messages   []string
+	candidates []report.Candidate
+}
+
+func (s *recordingSink) Log(_ log.Level, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+func (s *recordingSink) Candidate(record report.Candidate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candidates = append(s.candidates, record)
+}
+
+type cancelingSink struct {
+	recordingSink
+	cancel context.CancelFunc
+}
+
+func (s *cancelingSink) Candidate(record report.Candidate) {
+	s.recordingSink.Candidate(record)
+	s.cancel()
+}
+
+func TestRunWithSinkStopsDispatchWhenCanceled(t *testing.T) {
+	root := t.TempDir()
+	first := filepath.Join(root, "a.rar")
+	second := filepath.Join(root, "b.rar")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: first, Stem: "a"}, {Path: second, Stem: "b"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var extracted []string
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		extracted = append(extracted, filepath.Base(req.ArchivePath))
+		return PasswordExtractionResult{}, nil
+	}
+
+	// Cancel once the first set has been placed.
+	sink := &cancelingSink{cancel: cancel}
+	stats, err := RunWithSink(ctx, cli.Options{Dir: root, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20, Verbose: true}, sink)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunWithSink error=%v, want context.Canceled", err)
+	}
+	if stats.ArchivesExtracted != 1 || len(extracted) != 1 {
+		t.Fatalf("stats=%+v extracted=%v, want only the first set", stats, extracted)
+	}
+	if len(sink.candidates) != 1 || sink.candidates[0].Path != first || sink.candidates[0].Outcome != report.Extracted {
+		t.Fatalf("sink candidates=%+v, want the extracted first set", sink.candidates)
+	}
+	if len(sink.messages) == 0 {
+		t.Fatal("expected log messages in the sink")
+	}
+}
+
+func TestRunArchiveProcessesOnlyThatSet(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "movie.part1.rar")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(string, int) ([]finder.Candidate, error) {
+		t.Fatal("the directory was scanned")
+		return nil, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.ArchivePath != archive {
+			t.Fatalf("extracted %q, want %q", req.ArchivePath, archive)
+		}
+		return PasswordExtractionResult{}, nil
+	}
+
+	opts := cli.Options{Dir: root, Archive: archive, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20}
+	stats, err := RunWithSink(context.Background(), opts, &recordingSink{})
+	if err != nil || stats.ArchivesExtracted != 1 {
+		t.Fatalf("stats=%+v err=%v, want the archive extracted", stats, err)
+	}
+
+	opts.Archive = filepath.Join(root, "movie.part2.rar")
+	if _, err := RunWithSink(context.Background(), opts, &recordingSink{}); err == nil {
+		t.Fatal("expected an error for a volume that does not start a set")
+	}
+}
+
+func TestRunSkipsSetsLockedByAnotherRun(t *testing.T) {
+	root := t.TempDir()
+	free := filepath.Join(root, "free.rar")
+	held := filepath.Join(root, "held.rar")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(string, int) ([]finder.Candidate, error) {
+		return []finder.Candidate{{Path: free, Stem: "free"}, {Path: held, Stem: "held"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	opts := cli.Options{Dir: root, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if _, err := os.Stat(lock.SetPath(req.ArchivePath)); err != nil && !opts.NoLock {
+			t.Fatalf("set lock while extracting: %v", err)
+		}
+		return PasswordExtractionResult{}, nil
+	}
+
+	setLock, err := lock.AcquireSet(held)
+	if err != nil {
+		t.Fatalf("lock set: %v", err)
+	}
+	defer setLock.Release()
+
+	sink := &recordingSink{}
+	stats, err := RunWithSink(context.Background(), opts, sink)
+	if err != nil || stats.ArchivesExtracted != 1 || stats.ArchivesSkipped != 1 || ExitCode(stats, false) != 0 {
+		t.Fatalf("stats=%+v err=%v, want one set extracted and the locked one skipped", stats, err)
+	}
+	var reasons []string
+	for _, record := range sink.candidates {
+		reasons = append(reasons, filepath.Base(record.Path)+":"+record.SkipReason)
+	}
+	if strings.Join(reasons, ",") != "free.rar:,held.rar:busy" {
+		t.Fatalf("records=%v, want held.rar skipped as busy", reasons)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(setLock.Path()) {
+		t.Fatalf("root holds %v after the run, want only the other run's set lock", entries)
+	}
+
+	runLock, err := lock.AcquireRun(root)
+	if err != nil {
+		t.Fatalf("lock tree: %v", err)
+	}
+	defer runLock.Release()
+	if _, err := Run(context.Background(), opts, log.NewWithWriters(true, false, &bytes.Buffer{}, &bytes.Buffer{})); !errors.Is(err, lock.ErrBusy) {
+		t.Fatalf("Run err=%v, want busy while another run holds the tree", err)
+	}
+
+	opts.NoLock = true
+	stats, err = Run(context.Background(), opts, log.NewWithWriters(true, false, &bytes.Buffer{}, &bytes.Buffer{}))
+	if err != nil || stats.ArchivesExtracted != 2 {
+		t.Fatalf("--no-lock stats=%+v err=%v, want the locks ignored", stats, err)
+	}
+}
+
+func TestRunRollsBackSetCanceledWhileMoving(t *testing.T) {
+	root := t.TempDir()
+	out := t.TempDir()
+	first := filepath.Join(root, "a.rar")
+	second := filepath.Join(root, "b.rar")
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: first, Stem: "a"}, {Path: second, Stem: "b"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if req.ArchivePath != first {
+			t.Fatalf("extracted %q after cancellation", req.ArchivePath)
+		}
+		for _, name := range []string{"one.mkv", "two.mkv"} {
+			if err := os.WriteFile(filepath.Join(req.TmpDir, name), []byte(name), 0o644); err != nil {
+				return PasswordExtractionResult{}, err
+			}
+		}
+		return PasswordExtractionResult{}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	safeMovePath = func(ctx context.Context, src, dst string, verify func(string) error) (string, error) {
+		// The signal arrives once the first file is in place.
+		defer cancel()
+		return fsutil.SafeMoveContext(ctx, src, dst, verify)
+	}
+
+	sink := &recordingSink{}
+	opts := cli.Options{Dir: root, OutputDir: out, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20}
+	stats, err := RunWithSink(ctx, opts, sink)
+	if !errors.Is(err, context.Canceled) || stats.ArchivesExtracted != 0 || stats.Failures != 0 {
+		t.Fatalf("stats=%+v err=%v, want nothing extracted and context.Canceled", stats, err)
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 0 {
+		t.Fatalf("output holds %v after rollback, want nothing", entries)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Fatalf("archive directory holds %v after rollback, want no temp directory or lock", entries)
+	}
+	if len(sink.candidates) != 1 || sink.candidates[0].Error == nil || sink.candidates[0].Error.Class != report.ClassCanceled {
+		t.Fatalf("records=%+v, want the first set failed as canceled", sink.candidates)
 	}
 }
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/metrics"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/arodd/go-unrarall/internal/server"
+)
+
+// serveShutdownGrace is how long open event streams get to finish once
+// serving stops.
+const serveShutdownGrace = 5 * time.Second
+
+// serveTokenPath is where a generated API token is written for clients.
+var serveTokenPath = func() string {
+	return fsutil.StatePath("serve.token")
+}
+
+// Serve runs the HTTP control API on opts.Listen until ctx is done, running
+// submitted jobs one at a time with RunWithSink. When ctx is done the
+// running job is canceled and queued jobs are dropped. Without
+// --token-env, a token is generated and written, readable only by the
+// server user, to the serve.token state file for the lifetime of the
+// server.
+func Serve(ctx context.Context, opts cli.ServeOptions, logger *log.Logger) error {
+	var token string
+	if opts.TokenEnv != "" {
+		token = os.Getenv(opts.TokenEnv)
+		if token == "" {
+			return fmt.Errorf("--token-env: environment variable %s is empty or unset", opts.TokenEnv)
+		}
+	}
+
+	m := metrics.New()
+	if opts.MetricsAddr != "" {
+		srv, err := serveMetrics(opts.MetricsAddr, m)
+		if err != nil {
+			return fmt.Errorf("--metrics-addr: %w", err)
+		}
+		defer srv.Close()
+		logger.Infof("Serving metrics on http://%s/metrics.", srv.Addr())
+	}
+
+	listener, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return fmt.Errorf("--listen: %w", err)
+	}
+	api, err := server.New(server.Config{
+		Run:     runJob,
+		Log:     logger,
+		Token:   token,
+		Addr:    listener.Addr().String(),
+		History: opts.History,
+		Metrics: m,
+	})
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	if token == "" {
+		path := serveTokenPath()
+		if path == "" {
+			listener.Close()
+			return errors.New("no state directory to write the generated API token to; set --token-env")
+		}
+		if err := fsutil.WriteFileAtomic(path, []byte(api.Token()+"\n"), 0o600); err != nil {
+			listener.Close()
+			return fmt.Errorf("write API token: %w", err)
+		}
+		defer os.Remove(path)
+		logger.Infof("Wrote the API token to %q; send it as `Authorization: Bearer <token>`.", path)
+	}
+	httpServer := &http.Server{Handler: api.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	go func() { served <- httpServer.Serve(listener) }()
+	logger.Infof("Serving the API on http://%s.", listener.Addr())
+
+	api.Run(ctx)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownGrace)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		httpServer.Close()
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logger.Infof("Stopped serving.")
+	return nil
+}
+
+// runJob runs one API job.
+func runJob(ctx context.Context, opts cli.Options, sink server.Sink) (report.Summary, int, error) {
+	stats, err := RunWithSink(ctx, opts, sink)
+	return stats.summary(), ExitCode(stats, opts.AllowFailures), err
+}
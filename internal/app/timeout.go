@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// runDeadline returns when a run started now reaches --max-runtime, or the
+// zero time without a limit.
+func runDeadline(opts cli.Options) time.Time {
+	if opts.MaxRuntime <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(opts.MaxRuntime)
+}
+
+// pastDeadline reports whether a top-level run has reached --max-runtime
+// and must not start another candidate. Nested runs belong to a candidate
+// already started and always continue.
+func (r *runner) pastDeadline() bool {
+	return r.parent == "" && !r.deadline.IsZero() && !time.Now().Before(r.deadline)
+}
+
+func (r *runner) logDeadline(remaining int) {
+	r.log.Infof("Max runtime of %s reached; leaving %d archive set(s) for the next run.", r.opts.MaxRuntime, remaining)
+}
+
+// extractTimed extracts a top-level candidate under its time limit: the
+// earlier of --archive-timeout and, with --max-runtime-policy=abort, the
+// run deadline. A candidate still running when the limit passes is rolled
+// back like a canceled one and fails with the class timeout.
+func (r *runner) extractTimed(candidate finder.Candidate, depth int, record *report.Candidate) (Stats, error) {
+	deadline, limit := r.candidateDeadline()
+	if deadline.IsZero() {
+		return r.extractLocked(candidate, depth, record)
+	}
+
+	ctx, cancel := context.WithDeadline(r.ctx, deadline)
+	defer cancel()
+	timed := *r
+	timed.ctx = ctx
+	stats, err := timed.extractLocked(candidate, depth, record)
+	if err == nil || ctx.Err() == nil || r.ctx.Err() != nil {
+		return stats, err
+	}
+	r.log.Errorf("Extraction of %q stopped at the %s limit and was rolled back.", candidate.Path, limit)
+	fail(record, report.ClassTimeout, fmt.Errorf("%s limit reached: %w", limit, err))
+	return Stats{ArchivesFound: stats.ArchivesFound, Failures: 1, TimedOut: 1}, nil
+}
+
+// candidateDeadline returns the time limit of a candidate starting now and
+// the option that sets it, or the zero time when it has none. Dry runs
+// and nested candidates have no limit of their own.
+func (r *runner) candidateDeadline() (time.Time, string) {
+	if r.parent != "" || r.opts.DryRun {
+		return time.Time{}, ""
+	}
+	var (
+		deadline time.Time
+		limit    string
+	)
+	if r.opts.ArchiveTimeout > 0 {
+		deadline = time.Now().Add(r.opts.ArchiveTimeout)
+		limit = fmt.Sprintf("--archive-timeout %s", r.opts.ArchiveTimeout)
+	}
+	if r.opts.MaxRuntimePolicy == cli.RuntimeAbort && !r.deadline.IsZero() && (deadline.IsZero() || r.deadline.Before(deadline)) {
+		deadline = r.deadline
+		limit = fmt.Sprintf("--max-runtime %s", r.opts.MaxRuntime)
+	}
+	return deadline, limit
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/rar"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/nwaples/rardecode/v2"
+)
+
+// stubTwoSets stubs a tree holding a.rar and b.rar whose extraction is
+// extract.
+func stubTwoSets(root string, extract func(ExtractRequest) (PasswordExtractionResult, error)) {
+	scanCandidates = func(dir string, _ int) ([]finder.Candidate, error) {
+		if dir != root {
+			return nil, nil
+		}
+		return []finder.Candidate{{Path: filepath.Join(root, "a.rar"), Stem: "a"}, {Path: filepath.Join(root, "b.rar"), Stem: "b"}}, nil
+	}
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	extractArchiveWithRetries = extract
+}
+
+func writeExtracted(req ExtractRequest) (PasswordExtractionResult, error) {
+	name := filepath.Base(req.ArchivePath) + ".mkv"
+	return PasswordExtractionResult{}, os.WriteFile(filepath.Join(req.TmpDir, name), []byte(name), 0o644)
+}
+
+func TestRunRollsBackSetsPastArchiveTimeout(t *testing.T) {
+	root := t.TempDir()
+	out := t.TempDir()
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	stubTwoSets(root, func(req ExtractRequest) (PasswordExtractionResult, error) {
+		if _, err := writeExtracted(req); err != nil || filepath.Base(req.ArchivePath) != "a.rar" {
+			return PasswordExtractionResult{}, err
+		}
+		// The decoder crawls until the limit passes.
+		<-req.Context.Done()
+		return PasswordExtractionResult{}, req.Context.Err()
+	})
+
+	sink := &recordingSink{}
+	opts := cli.Options{Dir: root, OutputDir: out, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20, ArchiveTimeout: 50 * time.Millisecond}
+	stats, err := RunWithSink(context.Background(), opts, sink)
+	if err != nil {
+		t.Fatalf("RunWithSink returned error: %v", err)
+	}
+	if stats != (Stats{ArchivesFound: 2, ArchivesExtracted: 1, Failures: 1, TimedOut: 1}) {
+		t.Fatalf("stats=%+v, want one set extracted and one timed out", stats)
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 1 || entries[0].Name() != "b.rar.mkv" {
+		t.Fatalf("output holds %v, want only the second set's file", entries)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Fatalf("archive directory holds %v, want the timed-out temp directory removed", entries)
+	}
+	if len(sink.candidates) != 2 || sink.candidates[0].Error == nil || sink.candidates[0].Error.Class != report.ClassTimeout {
+		t.Fatalf("records=%+v, want the first set failed as timeout", sink.candidates)
+	}
+}
+
+func TestRunStopsPasswordCommandsAtArchiveTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+	root := t.TempDir()
+	out := t.TempDir()
+
+	restore := stubRunDependencies()
+	defer restore()
+
+	wrongPassword := func(string, string, bool, rar.OpenSettings) ([]string, error) {
+		return nil, rardecode.ErrBadPassword
+	}
+	stubTwoSets(root, func(req ExtractRequest) (PasswordExtractionResult, error) {
+		return extractArchiveWithPasswords(wrongPassword, acceptPassword, req)
+	})
+
+	sink := &recordingSink{}
+	opts := cli.Options{
+		Dir:              root,
+		OutputDir:        out,
+		CleanHooks:       []string{"none"},
+		MaxDictBytes:     1 << 20,
+		ArchiveTimeout:   50 * time.Millisecond,
+		PasswordCommand:  "exec sleep 30",
+		NoPasswordPrompt: true,
+	}
+	start := time.Now()
+	stats, err := RunWithSink(context.Background(), opts, sink)
+	if err != nil {
+		t.Fatalf("RunWithSink returned error: %v", err)
+	}
+	if waited := time.Since(start); waited > 10*time.Second {
+		t.Fatalf("run waited %s for the password command past the archive timeout", waited)
+	}
+	if stats.TimedOut != 2 {
+		t.Fatalf("stats=%+v, want both sets timed out", stats)
+	}
+}
+
+func TestRunStopsStartingSetsAtMaxRuntime(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   Stats
+	}{
+		{policy: cli.RuntimeFinish, want: Stats{ArchivesFound: 1, ArchivesExtracted: 1}},
+		{policy: cli.RuntimeAbort, want: Stats{ArchivesFound: 1, Failures: 1, TimedOut: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			root := t.TempDir()
+			out := t.TempDir()
+
+			restore := stubRunDependencies()
+			defer restore()
+
+			stubTwoSets(root, func(req ExtractRequest) (PasswordExtractionResult, error) {
+				if filepath.Base(req.ArchivePath) == "b.rar" {
+					t.Errorf("started %q after the deadline", req.ArchivePath)
+				}
+				select {
+				case <-req.Context.Done():
+					return PasswordExtractionResult{}, req.Context.Err()
+				case <-time.After(100 * time.Millisecond):
+				}
+				return writeExtracted(req)
+			})
+
+			opts := cli.Options{Dir: root, OutputDir: out, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20, MaxRuntime: 50 * time.Millisecond, MaxRuntimePolicy: tt.policy}
+			stats, err := RunWithSink(context.Background(), opts, &recordingSink{})
+			if err != nil || stats != tt.want {
+				t.Fatalf("stats=%+v err=%v, want %+v", stats, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/passwords"
+)
+
+// RunPasswords executes the passwords subcommand against opts.Vault. Listed
+// passwords go to stdout; prompts and status messages go to stderr.
+func RunPasswords(opts cli.PasswordsOptions, stdin *os.File, stdout, stderr io.Writer) error {
+	existing, exists, err := readVaultFile(opts.Vault)
+	if err != nil {
+		return err
+	}
+	if !exists && opts.Action != cli.VaultAdd {
+		return fmt.Errorf("vault %q does not exist", opts.Vault)
+	}
+
+	passphrase, err := passwords.ResolvePassphrase(passwords.PassphraseOptions{
+		FD:      opts.PassphraseFD,
+		In:      stdin,
+		Out:     stderr,
+		Confirm: !exists,
+	})
+	if err != nil {
+		return err
+	}
+
+	var current []string
+	if exists {
+		current, err = passwords.OpenVault(existing, passphrase)
+		if err != nil {
+			return fmt.Errorf("open vault %q: %w", opts.Vault, err)
+		}
+	}
+
+	if opts.Action == cli.VaultList {
+		for _, password := range current {
+			fmt.Fprintln(stdout, password)
+		}
+		return nil
+	}
+
+	values, err := vaultInputPasswords(stdin, stderr)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return errors.New("no passwords given")
+	}
+
+	changed := 0
+	switch opts.Action {
+	case cli.VaultAdd:
+		for _, value := range values {
+			if slices.Contains(current, value) {
+				continue
+			}
+			current = append(current, value)
+			changed++
+		}
+	case cli.VaultRemove:
+		kept := current[:0]
+		for _, password := range current {
+			if slices.Contains(values, password) {
+				changed++
+				continue
+			}
+			kept = append(kept, password)
+		}
+		current = kept
+		if changed == 0 {
+			return fmt.Errorf("none of the given passwords are in vault %q", opts.Vault)
+		}
+	}
+
+	if err := passwords.SaveVault(opts.Vault, current, passphrase); err != nil {
+		return fmt.Errorf("write vault %q: %w", opts.Vault, err)
+	}
+	verb := "Added"
+	if opts.Action == cli.VaultRemove {
+		verb = "Removed"
+	}
+	fmt.Fprintf(stderr, "%s %d password(s); vault %q now holds %d.\n", verb, changed, opts.Vault, len(current))
+	return nil
+}
+
+// resolvedPassphrase keeps the vault passphrase once a run has resolved it,
+// so later watch passes and serve jobs neither prompt again nor re-read
+// --passphrase-fd.
+var resolvedPassphrase struct {
+	sync.Mutex
+	value string
+}
+
+// vaultPassphrase returns the vault passphrase for a run of opts, resolving
+// it at most once per process. A prompt ends once ctx is done. With --no-password-prompt, which watch and
+// serve jobs imply, only the environment and --passphrase-fd are consulted,
+// so a run without a terminal never waits on stdin. Failures are not kept,
+// so a later run may still succeed.
+func vaultPassphrase(ctx context.Context, opts cli.Options) (string, error) {
+	resolvedPassphrase.Lock()
+	defer resolvedPassphrase.Unlock()
+	if resolvedPassphrase.value != "" {
+		return resolvedPassphrase.value, nil
+	}
+	source := passwords.PassphraseOptions{FD: opts.PassphraseFD, Out: os.Stderr, Context: ctx}
+	if !opts.NoPasswordPrompt {
+		source.In = os.Stdin
+	}
+	value, err := resolvePassphrase(source)
+	if err != nil {
+		return "", err
+	}
+	resolvedPassphrase.value = value
+	return value, nil
+}
+
+// readVaultFile loads an existing vault. An empty or missing file reports
+// exists=false; a plaintext password file is refused so it is never
+// overwritten.
+func readVaultFile(path string) (data []byte, exists bool, err error) {
+	data, err = os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) == 0 {
+		return nil, false, nil
+	}
+	if !passwords.IsVault(data) {
+		return nil, false, fmt.Errorf("%q is a plaintext password file; choose another --vault path and import it with `passwords add < %s`", path, path)
+	}
+	return data, true, nil
+}
+
+func vaultInputPasswords(stdin *os.File, stderr io.Writer) ([]string, error) {
+	if passwords.IsTerminal(stdin) {
+		value, err := passwords.PromptSecret(stdin, stderr, "Password: ")
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			return nil, nil
+		}
+		return []string{value}, nil
+	}
+	return passwords.ReadLines(stdin)
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/passwords"
+)
+
+// stdinWith returns a file holding lines, one per line, to pass as stdin.
+func stdinWith(t *testing.T, lines ...string) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("write stdin: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open stdin: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestRunPasswordsAddListRemove(t *testing.T) {
+	t.Setenv(passwords.VaultPassphraseEnv, "vault-pass")
+
+	vault := filepath.Join(t.TempDir(), "passwords.vault")
+	run := func(action string, values ...string) string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		opts := cli.PasswordsOptions{Action: action, Vault: vault, PassphraseFD: -1}
+		if err := RunPasswords(opts, stdinWith(t, values...), &stdout, &stderr); err != nil {
+			t.Fatalf("RunPasswords(%s) returned error: %v", action, err)
+		}
+		return stdout.String()
+	}
+
+	run(cli.VaultAdd, "alpha", "beta")
+	run(cli.VaultAdd, "beta", "gamma")
+	run(cli.VaultRemove, "alpha")
+
+	if got, want := run(cli.VaultList), "beta\ngamma\n"; got != want {
+		t.Fatalf("list output=%q, want %q", got, want)
+	}
+
+	data, err := os.ReadFile(vault)
+	if err != nil {
+		t.Fatalf("read vault: %v", err)
+	}
+	if strings.Contains(string(data), "gamma") {
+		t.Fatal("vault file contains a plaintext password")
+	}
+}
+
+func TestRunPasswordsRefusesPlaintextFile(t *testing.T) {
+	t.Setenv(passwords.VaultPassphraseEnv, "vault-pass")
+
+	path := filepath.Join(t.TempDir(), ".unrar_passwords")
+	if err := os.WriteFile(path, []byte("secret\n"), 0o600); err != nil {
+		t.Fatalf("write plaintext file: %v", err)
+	}
+
+	opts := cli.PasswordsOptions{Action: cli.VaultAdd, Vault: path, PassphraseFD: -1}
+	if err := RunPasswords(opts, stdinWith(t, "new"), &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error when vault path is a plaintext password file")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "secret\n" {
+		t.Fatalf("plaintext file changed: %q, %v", data, err)
+	}
+}
+
+func TestVaultPassphraseResolvedOncePerProcess(t *testing.T) {
+	restore := stubRunDependencies()
+	defer restore()
+
+	var calls []passwords.PassphraseOptions
+	fail := true
+	resolvePassphrase = func(opts passwords.PassphraseOptions) (string, error) {
+		calls = append(calls, opts)
+		if fail {
+			return "", errors.New("no passphrase available")
+		}
+		return "vault-pass", nil
+	}
+
+	opts := cli.Options{PassphraseFD: -1, NoPasswordPrompt: true}
+	if _, err := vaultPassphrase(context.Background(), opts); err == nil {
+		t.Fatal("expected the failed resolution to be returned")
+	}
+	fail = false
+	for range 2 {
+		if got, err := vaultPassphrase(context.Background(), opts); err != nil || got != "vault-pass" {
+			t.Fatalf("vaultPassphrase()=%q, %v, want vault-pass", got, err)
+		}
+	}
+	if len(calls) != 2 {
+		t.Fatalf("resolved %d times, want a retry after the failure and none after success", len(calls))
+	}
+	for _, call := range calls {
+		if call.In != nil {
+			t.Fatal("passphrase prompt read stdin despite --no-password-prompt")
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/cli"
+)
+
+// RunVerify executes the verify subcommand: every extracted-output manifest
+// under opts.Dir is checked against the files next to it. Problems go to
+// stderr; clean manifests are reported on stdout in verbose mode.
+func RunVerify(opts cli.VerifyOptions, stdout, stderr io.Writer) error {
+	var manifests []string
+	err := filepath.WalkDir(opts.Dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.Type().IsRegular() && checksum.IsGenerated(d.Name()) {
+			manifests = append(manifests, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return fmt.Errorf("no extracted-output manifests found under %q", opts.Dir)
+	}
+
+	failed, files := 0, 0
+	for _, path := range manifests {
+		manifest, err := checksum.ParseFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "FAILED %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		files += len(manifest.Entries)
+
+		err = checksum.Verify(filepath.Dir(path), manifest)
+		var verificationErr *checksum.VerificationError
+		switch {
+		case err == nil:
+			if opts.Verbose {
+				fmt.Fprintf(stdout, "OK %s (%d files)\n", path, len(manifest.Entries))
+			}
+		case errors.As(err, &verificationErr):
+			fmt.Fprintf(stderr, "FAILED %s: %v\n", path, err)
+			for _, name := range verificationErr.Missing {
+				fmt.Fprintf(stderr, "  missing  %s\n", name)
+			}
+			for _, mismatch := range verificationErr.Mismatches {
+				fmt.Fprintf(stderr, "  changed  %s (expected %s, got %s)\n", mismatch.Name, mismatch.Expected, mismatch.Actual)
+			}
+			failed++
+		default:
+			fmt.Fprintf(stderr, "FAILED %s: %v\n", path, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d manifest(s) failed verification", failed, len(manifests))
+	}
+	fmt.Fprintf(stdout, "Verified %d manifest(s) covering %d file(s).\n", len(manifests), files)
+	return nil
+}
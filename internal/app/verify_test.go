@@ -0,0 +1,59 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/cli"
+)
+
+func TestRunVerifyChecksManifestsRecursively(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "Show", "S01")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "episode.mkv"), []byte("payload"), 0o644); err != nil {
+		t.Fatalf("write payload: %v", err)
+	}
+	sum, err := checksum.FileSum(filepath.Join(dir, "episode.mkv"), checksum.SHA256)
+	if err != nil {
+		t.Fatalf("FileSum returned error: %v", err)
+	}
+	var manifest bytes.Buffer
+	if err := checksum.Write(&manifest, checksum.SHA256, []checksum.Entry{{Name: "episode.mkv", Sum: sum}}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksum.GeneratedName("episode", checksum.SHA256)), manifest.Bytes(), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	// Release manifests describe volumes and are not rechecked.
+	if err := os.WriteFile(filepath.Join(dir, "episode.sfv"), []byte("episode.rar 00000000\n"), 0o644); err != nil {
+		t.Fatalf("write release sfv: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := RunVerify(cli.VerifyOptions{Dir: root}, &stdout, &stderr); err != nil {
+		t.Fatalf("RunVerify returned error: %v (stderr %q)", err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Verified 1 manifest(s) covering 1 file(s).") {
+		t.Fatalf("stdout=%q", stdout.String())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "episode.mkv"), []byte("rotted!"), 0o644); err != nil {
+		t.Fatalf("rewrite payload: %v", err)
+	}
+	stderr.Reset()
+	if err := RunVerify(cli.VerifyOptions{Dir: root}, &stdout, &stderr); err == nil {
+		t.Fatal("expected RunVerify to fail after the payload changed")
+	}
+	if !strings.Contains(stderr.String(), "changed  episode.mkv") {
+		t.Fatalf("stderr=%q, want the changed file named", stderr.String())
+	}
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/finder"
+	"github.com/arodd/go-unrarall/internal/lock"
+	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/metrics"
+	"github.com/arodd/go-unrarall/internal/report"
+	"github.com/arodd/go-unrarall/internal/watch"
+)
+
+var (
+	newChangeNotifier  = watch.NewNotifier
+	reloadWatchOptions = cli.ReloadWatchOptions
+	serveMetrics       = metrics.Serve
+	// changeQuietPeriod is how long a burst of change notifications must
+	// pause before the tree is scanned.
+	changeQuietPeriod = 2 * time.Second
+)
+
+type watcher struct {
+	opts     cli.WatchOptions
+	log      *log.Logger
+	tracker  watch.Tracker
+	notifier *watch.Notifier
+	// metrics accumulate across passes for --metrics-addr and
+	// --metrics-file.
+	metrics *metrics.Metrics
+	// runLock is held on the watched tree while Watch runs.
+	runLock *lock.Lock
+}
+
+// Watch scans opts.Options.Dir in passes until ctx is done, processing each
+// archive set once its volumes have been unchanged for opts.Settle and none
+// is missing. A pass runs at start, after change notifications pause, every
+// opts.Rescan, and when a settling set is due. A receive on reload re-parses
+// the options. Once ctx is done, a pass in progress starts no further sets
+// and rolls back those not yet placed before Watch returns.
+// Metrics accumulate across passes and are served on opts.MetricsAddr while
+// Watch runs. The run lock on the tree is held throughout, and sets another
+// run holds busy are tried again on the next pass.
+func Watch(ctx context.Context, opts cli.WatchOptions, reload <-chan os.Signal, logger *log.Logger) error {
+	w := &watcher{opts: opts, log: logger, tracker: watch.Tracker{Settle: opts.Settle}, metrics: metrics.New()}
+	runLock, err := lockRun(opts.Options, logger)
+	if err != nil {
+		return err
+	}
+	w.runLock = runLock
+	defer func() { releaseLock(w.runLock, logger) }()
+	if opts.MetricsAddr != "" {
+		srv, err := serveMetrics(opts.MetricsAddr, w.metrics)
+		if err != nil {
+			return fmt.Errorf("--metrics-addr: %w", err)
+		}
+		defer srv.Close()
+		logger.Infof("Serving metrics on http://%s/metrics.", srv.Addr())
+	}
+	w.startNotifier()
+	defer func() { w.notifier.Close() }()
+	logger.Infof("Watching %q for archive sets (settle %s, rescan every %s).", opts.Options.Dir, opts.Settle, opts.Rescan)
+
+	rescan := time.NewTicker(opts.Rescan)
+	defer rescan.Stop()
+	next := time.NewTimer(0)
+	defer next.Stop()
+
+	for {
+		var changes <-chan struct{}
+		if w.notifier != nil {
+			changes = w.notifier.C
+		}
+		select {
+		case <-ctx.Done():
+			logger.Infof("Stopped watching %q.", w.opts.Options.Dir)
+			return nil
+		case <-reload:
+			if w.reload() {
+				rescan.Reset(w.opts.Rescan)
+			}
+			next.Reset(0)
+		case <-changes:
+			next.Reset(changeQuietPeriod)
+		case <-rescan.C:
+			next.Reset(0)
+		case <-next.C:
+			if wait := w.pass(ctx); wait > 0 {
+				next.Reset(wait)
+			}
+		}
+	}
+}
+
+func (w *watcher) startNotifier() {
+	notifier, err := newChangeNotifier(w.opts.Options.Dir)
+	if err != nil {
+		w.log.Infof("Change notifications unavailable, rescanning every %s: %v", w.opts.Rescan, err)
+		w.notifier = nil
+		return
+	}
+	w.notifier = notifier
+}
+
+// reload applies re-parsed options, keeping the current ones when they do
+// not parse or name a tree another run holds. Output options (--quiet,
+// --verbose, --log-file) keep their startup values.
+func (w *watcher) reload() bool {
+	opts, err := reloadWatchOptions(w.opts)
+	if err != nil {
+		w.log.Errorf("Reload failed, keeping the current options: %v", err)
+		return false
+	}
+	dirChanged := opts.Options.Dir != w.opts.Options.Dir
+	if dirChanged {
+		runLock, err := lockRun(opts.Options, w.log)
+		if err != nil {
+			w.log.Errorf("Reload failed, keeping the current options: %v", err)
+			return false
+		}
+		releaseLock(w.runLock, w.log)
+		w.runLock = runLock
+	}
+	w.opts = opts
+	w.tracker.Settle = opts.Settle
+	if dirChanged {
+		w.notifier.Close()
+		w.tracker = watch.Tracker{Settle: opts.Settle}
+		w.startNotifier()
+	}
+	w.log.Infof("Reloaded options; watching %q (settle %s, rescan every %s).", opts.Options.Dir, opts.Settle, opts.Rescan)
+	return true
+}
+
+// pass scans the tree once and processes every ready set. It returns how
+// long until a settling set is due, or 0.
+func (w *watcher) pass(ctx context.Context) time.Duration {
+	candidates, err := scanCandidates(w.opts.Options.Dir, scanDepthUnbounded)
+	if err != nil {
+		w.log.Errorf("Scan of %q failed: %v", w.opts.Options.Dir, err)
+		return 0
+	}
+
+	byPath := make(map[string]finder.Candidate, len(candidates))
+	signatures := make(map[string]string, len(candidates))
+	for _, candidate := range candidates {
+		signature, err := setSignature(candidate.Path)
+		if err != nil {
+			w.log.Verbosef("Cannot inspect the volumes of %q yet: %v", candidate.Path, err)
+			continue
+		}
+		byPath[candidate.Path] = candidate
+		signatures[candidate.Path] = signature
+	}
+	ready, wait := w.tracker.Observe(time.Now(), signatures)
+	slices.Sort(ready)
+
+	var batch []finder.Candidate
+	for _, path := range ready {
+		// Whatever the outcome, the set is not tried again until one of
+		// its volumes changes or appears, unless another run held it.
+		w.tracker.Done(path)
+		if err := missingVolume(path); err != nil {
+			w.log.Verbosef("Waiting for missing volumes of %q: %v", path, err)
+			continue
+		}
+		batch = append(batch, byPath[path])
+	}
+	if wait > 0 {
+		w.log.Verbosef("Waiting up to %s for archive sets to settle.", wait.Round(time.Second))
+	}
+	if len(batch) == 0 || ctx.Err() != nil {
+		return wait
+	}
+
+	r, err := newRunner(ctx, w.opts.Options, w.log)
+	if err != nil {
+		w.log.Errorf("Watch pass failed: %v", err)
+		return wait
+	}
+	r.metrics = w.metrics
+	var (
+		mu       sync.Mutex
+		busy     []string
+		finished = make(map[string]bool, len(batch))
+	)
+	r.onCandidate = func(record report.Candidate) {
+		if record.Parent != "" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		finished[record.Path] = true
+		if record.SkipReason == skipBusy {
+			busy = append(busy, record.Path)
+		}
+	}
+	stats, err := r.runCandidates(batch, w.opts.Options.Depth)
+	r.finish(stats)
+	for _, path := range busy {
+		w.tracker.Retry(path)
+	}
+	// Sets left unstarted at --max-runtime wait for the next pass.
+	if r.pastDeadline() {
+		for _, candidate := range batch {
+			if !finished[candidate.Path] {
+				w.tracker.Retry(candidate.Path)
+			}
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		w.log.Infof("Watch pass canceled; sets not yet placed were rolled back.")
+		r.logSummary(stats)
+		return wait
+	}
+	if err != nil {
+		w.log.Errorf("Watch pass failed: %v", err)
+		return wait
+	}
+	r.logSummary(stats)
+	return wait
+}
+
+// setSignature describes the names, sizes, and mtimes of every volume of
+// the set whose first volume is at path.
+func setSignature(path string) (string, error) {
+	dir := filepath.Dir(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	first := filepath.Base(path)
+	var b strings.Builder
+	for _, entry := range entries {
+		if entry.Name() != first && !finder.IsSetVolume(first, entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s\x00%d\x00%d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// missingVolume lists the set's headers and reports a volume the listing
+// needed but could not find. Other listing problems are left for extraction
+// to report.
+func missingVolume(path string) error {
+	volumes, err := mapArchiveVolumes(path)
+	if err == nil && errors.Is(volumes.Err, fs.ErrNotExist) {
+		return volumes.Err
+	}
+	return nil
+}
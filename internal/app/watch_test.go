@@ -0,0 +1,195 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/lock"
+	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/metrics"
+	"github.com/arodd/go-unrarall/internal/rar"
+	"github.com/arodd/go-unrarall/internal/watch"
+)
+
+func TestWatchProcessesSetsOnceTheyAreComplete(t *testing.T) {
+	root := t.TempDir()
+	writeVolume := func(name string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte("volume"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	writeVolume("ready.rar")
+	writeVolume("late.part1.rar")
+
+	restore := stubRunDependencies()
+	defer restore()
+	oldNotifier := newChangeNotifier
+	defer func() { newChangeNotifier = oldNotifier }()
+	newChangeNotifier = func(string) (*watch.Notifier, error) {
+		return nil, watch.ErrUnsupported
+	}
+	oldServeMetrics := serveMetrics
+	defer func() { serveMetrics = oldServeMetrics }()
+	var served *metrics.Metrics
+	serveMetrics = func(addr string, m *metrics.Metrics) (*metrics.Server, error) {
+		served = m
+		return metrics.Serve(addr, m)
+	}
+
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	mapArchiveVolumes = func(path string) (rar.VolumeMap, error) {
+		part2 := filepath.Join(root, "late.part2.rar")
+		if filepath.Base(path) == "late.part1.rar" {
+			if _, err := os.Stat(part2); err != nil {
+				return rar.VolumeMap{Err: &fs.PathError{Op: "open", Path: part2, Err: fs.ErrNotExist}}, nil
+			}
+		}
+		return rar.VolumeMap{}, nil
+	}
+	extracted := make(chan string, 10)
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		extracted <- filepath.Base(req.ArchivePath)
+		return PasswordExtractionResult{}, nil
+	}
+
+	opts := cli.WatchOptions{
+		Options: cli.Options{
+			Dir:          root,
+			CleanHooks:   []string{"none"},
+			MaxDictBytes: 1 << 20,
+		},
+		Rescan:      10 * time.Millisecond,
+		MetricsAddr: "127.0.0.1:0",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, opts, nil, log.NewWithWriters(false, false, &bytes.Buffer{}, &bytes.Buffer{}))
+	}()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-extracted:
+			if got != want {
+				t.Fatalf("extracted %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q was not extracted", want)
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case got := <-extracted:
+			t.Fatalf("extracted %q again or too early", got)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	expect("ready.rar")
+	expectNone()
+
+	writeVolume("late.part2.rar")
+	expect("late.part1.rar")
+	expectNone()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Watch returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not stop after cancellation")
+	}
+
+	// Metrics accumulate across passes.
+	var scrape bytes.Buffer
+	if _, err := served.WriteTo(&scrape); err != nil {
+		t.Fatalf("render metrics: %v", err)
+	}
+	if !strings.Contains(scrape.String(), "unrarall_archives_extracted_total 2\n") {
+		t.Fatalf("metrics=%s\nwant both sets counted", scrape.String())
+	}
+}
+
+func TestWatchRetriesSetsAnotherRunHolds(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "movie.rar")
+	if err := os.WriteFile(archive, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	restore := stubRunDependencies()
+	defer restore()
+	oldNotifier := newChangeNotifier
+	defer func() { newChangeNotifier = oldNotifier }()
+	newChangeNotifier = func(string) (*watch.Notifier, error) {
+		return nil, watch.ErrUnsupported
+	}
+
+	validateRarSignature = func(string) (bool, error) {
+		return true, nil
+	}
+	mapArchiveVolumes = func(string) (rar.VolumeMap, error) {
+		return rar.VolumeMap{}, nil
+	}
+	extracted := make(chan string, 10)
+	extractArchiveWithRetries = func(req ExtractRequest) (PasswordExtractionResult, error) {
+		extracted <- filepath.Base(req.ArchivePath)
+		return PasswordExtractionResult{}, nil
+	}
+
+	setLock, err := lock.AcquireSet(archive)
+	if err != nil {
+		t.Fatalf("lock set: %v", err)
+	}
+	opts := cli.WatchOptions{
+		Options: cli.Options{Dir: root, CleanHooks: []string{"none"}, MaxDictBytes: 1 << 20},
+		Rescan:  10 * time.Millisecond,
+	}
+	logger := log.NewWithWriters(true, false, &bytes.Buffer{}, &bytes.Buffer{})
+
+	runLock, err := lock.AcquireRun(root)
+	if err != nil {
+		t.Fatalf("lock tree: %v", err)
+	}
+	if err := Watch(context.Background(), opts, nil, logger); !errors.Is(err, lock.ErrBusy) {
+		t.Fatalf("Watch err=%v, want busy while another run holds the tree", err)
+	}
+	runLock.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, opts, nil, logger)
+	}()
+	select {
+	case got := <-extracted:
+		t.Fatalf("extracted %q while another run held it", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	setLock.Release()
+	select {
+	case <-extracted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the set was not retried once released")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Watch returned error: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package checksum parses and verifies checksum manifests: CRC32 .sfv files
+// and md5sum-style .md5, .sha1, and .sha256 files.
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"strings"
+)
+
+// Algorithm names a manifest checksum algorithm.
+type Algorithm string
+
+// Supported algorithms.
+const (
+	CRC32  Algorithm = "crc32"
+	MD5    Algorithm = "md5"
+	SHA1   Algorithm = "sha1"
+	SHA256 Algorithm = "sha256"
+)
+
+// ByStrength lists algorithms from strongest to weakest. When a set ships
+// several manifests, the strongest one is verified.
+var ByStrength = []Algorithm{SHA256, SHA1, MD5, CRC32}
+
+// New returns a fresh hash for a. CRC32 sums are big-endian, matching the
+// hex digits written in .sfv files.
+func (a Algorithm) New() hash.Hash {
+	switch a {
+	case CRC32:
+		return crc32.NewIEEE()
+	case MD5:
+		return md5.New()
+	case SHA1:
+		return sha1.New()
+	case SHA256:
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// Size returns the digest length of a in bytes.
+func (a Algorithm) Size() int {
+	switch a {
+	case CRC32:
+		return crc32.Size
+	case MD5:
+		return md5.Size
+	case SHA1:
+		return sha1.Size
+	case SHA256:
+		return sha256.Size
+	default:
+		return 0
+	}
+}
+
+// Ext returns the manifest file extension for a, including the dot.
+func (a Algorithm) Ext() string {
+	if a == CRC32 {
+		return ".sfv"
+	}
+	return "." + string(a)
+}
+
+// AlgorithmForExt returns the algorithm whose manifests use extension ext.
+// The comparison is case-insensitive.
+func AlgorithmForExt(ext string) (Algorithm, bool) {
+	ext = strings.ToLower(ext)
+	for _, a := range ByStrength {
+		if a.Ext() == ext {
+			return a, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,193 @@
+package checksum
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+const (
+	cacheVersion = 1
+	// CacheFileName is the cache file name inside the unrarall state directory.
+	CacheFileName = "checksums.json"
+	// cacheTTL is how long a record is kept without being used. Files on a
+	// share that is not mounted cannot be told apart from deleted ones, so
+	// records are only dropped when they age out or are forgotten.
+	cacheTTL = 90 * 24 * time.Hour
+	// cacheTouchInterval limits how often a lookup refreshes a record's
+	// last use, so runs that only read the cache rarely rewrite it.
+	cacheTouchInterval = 24 * time.Hour
+)
+
+// DefaultCachePath returns the default location of the checksum cache, or an
+// empty string when no state directory is available.
+func DefaultCachePath() string {
+	return fsutil.StatePath(CacheFileName)
+}
+
+// Cache remembers file checksums across runs. A record is reused only while
+// the file's size, modification time, and inode are unchanged.
+type Cache struct {
+	path string
+
+	mu    sync.Mutex
+	data  cacheData
+	dirty bool
+}
+
+type cacheData struct {
+	Version int                    `json:"version"`
+	Files   map[string]cacheRecord `json:"files"`
+}
+
+type cacheRecord struct {
+	Size    int64             `json:"size"`
+	ModTime int64             `json:"mtime_ns"`
+	Inode   uint64            `json:"inode,omitempty"`
+	Sums    map[string]string `json:"sums"`
+	// Used is when the record was last stored or looked up, in Unix
+	// seconds. Records written before it existed count as used on load.
+	Used int64 `json:"used,omitempty"`
+}
+
+// OpenCache loads the cache at path. A missing file yields an empty cache that
+// is created on the first Save with new records. A nil *Cache is valid and
+// caches nothing.
+func OpenCache(path string) (*Cache, error) {
+	c := &Cache{path: path, data: cacheData{Version: cacheVersion}}
+
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(raw, &c.data); err != nil {
+			return nil, fmt.Errorf("parse checksum cache %q: %w", path, err)
+		}
+		if c.data.Version != cacheVersion {
+			return nil, fmt.Errorf("checksum cache %q has unsupported version %d", path, c.data.Version)
+		}
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return nil, err
+	}
+	if c.data.Files == nil {
+		c.data.Files = make(map[string]cacheRecord)
+	}
+	now := time.Now().Unix()
+	for path, record := range c.data.Files {
+		if record.Used == 0 {
+			record.Used = now
+			c.data.Files[path] = record
+		}
+	}
+	return c, nil
+}
+
+// Lookup returns the cached algorithm sum for path if info still matches the
+// cached file identity.
+func (c *Cache) Lookup(path string, algorithm Algorithm, info os.FileInfo) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	record, ok := c.data.Files[path]
+	if !ok || !record.matches(info) {
+		return nil, false
+	}
+	sum, err := hex.DecodeString(record.Sums[string(algorithm)])
+	if err != nil || len(sum) != algorithm.Size() {
+		return nil, false
+	}
+	if now := time.Now(); now.Sub(time.Unix(record.Used, 0)) >= cacheTouchInterval {
+		record.Used = now.Unix()
+		c.data.Files[path] = record
+		c.dirty = true
+	}
+	return sum, true
+}
+
+// Put records sum for path. Sums for other algorithms are kept while the file
+// identity is unchanged.
+func (c *Cache) Put(path string, algorithm Algorithm, info os.FileInfo, sum []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	record, ok := c.data.Files[path]
+	if !ok || !record.matches(info) {
+		record = cacheRecord{
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+			Inode:   fileInode(info),
+			Sums:    make(map[string]string),
+		}
+	}
+	record.Sums[string(algorithm)] = hex.EncodeToString(sum)
+	record.Used = time.Now().Unix()
+	c.data.Files[path] = record
+	c.dirty = true
+}
+
+// Forget drops the record for path, a file the caller removed.
+func (c *Cache) Forget(path string) {
+	if c == nil {
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.data.Files[path]; ok {
+		delete(c.data.Files, path)
+		c.dirty = true
+	}
+}
+
+// Save writes the cache if it changed, dropping records unused for longer
+// than cacheTTL. Files that cannot be found are kept until then, since they
+// may only be on a share that is not mounted.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	expired := time.Now().Add(-cacheTTL).Unix()
+	for path, record := range c.data.Files {
+		if record.Used < expired {
+			delete(c.data.Files, path)
+		}
+	}
+
+	raw, err := json.Marshal(c.data)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(c.path, raw, 0o600); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+func (r cacheRecord) matches(info os.FileInfo) bool {
+	return r.Size == info.Size() &&
+		r.ModTime == info.ModTime().UnixNano() &&
+		r.Inode == fileInode(info)
+}
@@ -0,0 +1,144 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVerifierReusesCachedSumsUntilRehash(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	volume := filepath.Join(root, "set.rar")
+	if err := os.WriteFile(volume, []byte("original"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	sum := md5.Sum([]byte("original"))
+	manifest := Manifest{Algorithm: MD5, Entries: []Entry{{Name: "set.rar", Sum: sum[:]}}}
+
+	cachePath := filepath.Join(t.TempDir(), "checksums.json")
+	cache, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatalf("OpenCache returned error: %v", err)
+	}
+	if err := (Verifier{Cache: cache}).Verify(root, manifest); err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	// Rewrite the file in place with the same size and mtime: only the
+	// cached sum can make verification pass now.
+	info, err := os.Stat(volume)
+	if err != nil {
+		t.Fatalf("stat volume: %v", err)
+	}
+	file, err := os.OpenFile(volume, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open volume: %v", err)
+	}
+	if _, err := file.WriteAt([]byte("tampered"), 0); err != nil {
+		t.Fatalf("rewrite volume: %v", err)
+	}
+	file.Close()
+	if err := os.Chtimes(volume, time.Now(), info.ModTime()); err != nil {
+		t.Fatalf("restore mtime: %v", err)
+	}
+
+	reopened, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatalf("OpenCache returned error: %v", err)
+	}
+	if err := (Verifier{Cache: reopened}).Verify(root, manifest); err != nil {
+		t.Fatalf("cached Verify returned error: %v", err)
+	}
+
+	var verificationErr *VerificationError
+	err = Verifier{Cache: reopened, Rehash: true}.Verify(root, manifest)
+	if !errors.As(err, &verificationErr) || len(verificationErr.Mismatches) != 1 {
+		t.Fatalf("rehash Verify error=%v, want one mismatch", err)
+	}
+}
+
+func TestVerifierReportsEntriesInManifestOrder(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	manifest := Manifest{Algorithm: MD5}
+	var want []string
+	for i := range 12 {
+		name := fmt.Sprintf("set.r%02d", i)
+		if i%3 == 0 {
+			want = append(want, name)
+		} else if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		sum := md5.Sum([]byte(name))
+		manifest.Entries = append(manifest.Entries, Entry{Name: name, Sum: sum[:]})
+	}
+
+	err := Verifier{Workers: 3}.Verify(root, manifest)
+	var verificationErr *VerificationError
+	if !errors.As(err, &verificationErr) {
+		t.Fatalf("Verify returned %v, want *VerificationError", err)
+	}
+	if !reflect.DeepEqual(verificationErr.Missing, want) || len(verificationErr.Mismatches) != 0 {
+		t.Fatalf("missing=%v mismatches=%v, want missing %v", verificationErr.Missing, verificationErr.Mismatches, want)
+	}
+}
+
+func TestCacheSaveKeepsMissingFilesUntilForgotten(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	volume := filepath.Join(root, "gone.rar")
+	if err := os.WriteFile(volume, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	info, err := os.Stat(volume)
+	if err != nil {
+		t.Fatalf("stat volume: %v", err)
+	}
+
+	cachePath := filepath.Join(t.TempDir(), "checksums.json")
+	cache, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatalf("OpenCache returned error: %v", err)
+	}
+	cache.Put(volume, CRC32, info, []byte{1, 2, 3, 4})
+	stale := filepath.Join(root, "stale.rar")
+	cache.Put(stale, CRC32, info, []byte{5, 6, 7, 8})
+	record := cache.data.Files[stale]
+	record.Used = time.Now().Add(-cacheTTL - time.Hour).Unix()
+	cache.data.Files[stale] = record
+	// The volume may only be on a share that is not mounted.
+	if err := os.Remove(volume); err != nil {
+		t.Fatalf("remove volume: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	reopened, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatalf("OpenCache returned error: %v", err)
+	}
+	if _, ok := reopened.data.Files[volume]; !ok || len(reopened.data.Files) != 1 {
+		t.Fatalf("cache records=%v, want the missing volume kept and the expired record dropped", reopened.data.Files)
+	}
+
+	reopened.Forget(volume)
+	if err := reopened.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if again, err := OpenCache(cachePath); err != nil || len(again.data.Files) != 0 {
+		t.Fatalf("cache records=%v err=%v, want the forgotten volume dropped", again.data.Files, err)
+	}
+}
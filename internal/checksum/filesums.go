@@ -0,0 +1,45 @@
+package checksum
+
+import (
+	"hash"
+	"maps"
+	"sync"
+)
+
+// FileSums collects sums of files as they are written, so a manifest of
+// extracted output can be produced without reading the files back.
+type FileSums struct {
+	algorithm Algorithm
+
+	mu   sync.Mutex
+	sums map[string][]byte
+}
+
+// NewFileSums returns an empty FileSums computing algorithm sums.
+func NewFileSums(algorithm Algorithm) *FileSums {
+	return &FileSums{algorithm: algorithm, sums: make(map[string][]byte)}
+}
+
+// Algorithm returns the algorithm sums are computed with.
+func (f *FileSums) Algorithm() Algorithm {
+	return f.algorithm
+}
+
+// New returns a fresh hash for one file.
+func (f *FileSums) New() hash.Hash {
+	return f.algorithm.New()
+}
+
+// Record stores sum for name, replacing any earlier sum for the same name.
+func (f *FileSums) Record(name string, sum []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sums[name] = sum
+}
+
+// Sums returns a copy of the recorded sums.
+func (f *FileSums) Sums() map[string][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return maps.Clone(f.sums)
+}
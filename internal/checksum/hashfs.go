@@ -0,0 +1,93 @@
+package checksum
+
+import (
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// HashingFS is an fs.FS over OS paths that hashes every file as it is read.
+// It is meant to be handed to the RAR decoder so volume checksums are
+// computed during extraction instead of in a separate pass.
+//
+// Opened files deliberately do not implement io.Seeker, which forces the
+// decoder to read volumes front to back. Closing a file hashes any unread
+// remainder, so each fully closed file yields a whole-file sum.
+type HashingFS struct {
+	algorithm Algorithm
+
+	mu   sync.Mutex
+	sums map[string][]byte
+}
+
+// NewHashingFS returns a HashingFS computing algorithm sums.
+func NewHashingFS(algorithm Algorithm) *HashingFS {
+	return &HashingFS{algorithm: algorithm, sums: make(map[string][]byte)}
+}
+
+// Open implements fs.FS. name is an OS path, as passed by the decoder.
+func (h *HashingFS) Open(name string) (fs.File, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	key, err := filepath.Abs(name)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &hashingFile{file: file, hash: h.algorithm.New(), key: key, owner: h}, nil
+}
+
+// Sums returns whole-file sums keyed by absolute path for every file that
+// was read to the end and closed.
+func (h *HashingFS) Sums() map[string][]byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make(map[string][]byte, len(h.sums))
+	for key, sum := range h.sums {
+		out[key] = sum
+	}
+	return out
+}
+
+func (h *HashingFS) record(key string, sum []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sums[key] = sum
+}
+
+type hashingFile struct {
+	file  *os.File
+	hash  hash.Hash
+	key   string
+	owner *HashingFS
+	// failed is set once a read error makes the running sum unusable.
+	failed bool
+}
+
+func (f *hashingFile) Stat() (fs.FileInfo, error) {
+	return f.file.Stat()
+}
+
+func (f *hashingFile) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	f.hash.Write(p[:n])
+	if err != nil && err != io.EOF {
+		f.failed = true
+	}
+	return n, err
+}
+
+func (f *hashingFile) Close() error {
+	if !f.failed {
+		if _, err := io.Copy(f.hash, f.file); err == nil {
+			f.owner.record(f.key, f.hash.Sum(nil))
+		}
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,44 @@
+package checksum
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashingFSHashesWholeFileOnClose(t *testing.T) {
+	t.Parallel()
+
+	content := bytes.Repeat([]byte("volume-data "), 4096)
+	path := filepath.Join(t.TempDir(), "set.rar")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+
+	hashing := NewHashingFS(SHA256)
+	file, err := hashing.Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if _, ok := file.(io.Seeker); ok {
+		t.Fatal("hashing file must not implement io.Seeker")
+	}
+	if _, err := io.CopyN(io.Discard, file, 100); err != nil {
+		t.Fatalf("partial read: %v", err)
+	}
+	if len(hashing.Sums()) != 0 {
+		t.Fatal("sum recorded before the file was closed")
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	want := sha256.Sum256(content)
+	got, ok := hashing.Sums()[path]
+	if !ok || !bytes.Equal(got, want[:]) {
+		t.Fatalf("Sums()[%q]=%x, want %x", path, got, want)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package checksum
+
+import "os"
+
+// fileInode returns 0 on platforms without inode numbers; size and mtime
+// alone identify the file there.
+func fileInode(os.FileInfo) uint64 {
+	return 0
+}
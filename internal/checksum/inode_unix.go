@@ -0,0 +1,17 @@
+//go:build unix
+
+package checksum
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode number of info, so a file replaced in place
+// with identical size and mtime is still re-hashed.
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package checksum
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Discover returns the manifest to verify for the archive set with the given
+// stem in dir, or nil when none applies. belongs reports whether a base file
+// name is one of the set's volumes.
+//
+// `<stem>.ext` manifests apply in full. Any other manifest in dir applies
+// when it lists at least one of the set's volumes, and is narrowed to those
+// entries so manifests covering several sets only verify this one. The
+// manifest listing the most of the set's volumes wins, so a weaker manifest
+// of the whole set beats a stronger one of a few volumes; among those the
+// strongest wins, then the `<stem>` manifest, then the first by name. Other
+// manifests that fail to parse are skipped, since they may not describe
+// this set at all. Manifests of extracted output (see GeneratedName) are
+// never considered.
+func Discover(dir, stem string, belongs func(name string) bool) (*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var (
+		best         *Manifest
+		bestOwn      bool
+		bestCoverage int
+		bestScore    int
+	)
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		algorithm, ok := AlgorithmForExt(ext)
+		if !ok || !entry.Type().IsRegular() || IsGenerated(name) {
+			continue
+		}
+		own := name == stem+algorithm.Ext()
+
+		manifest, err := ParseFile(filepath.Join(dir, name))
+		if err != nil {
+			if own {
+				return nil, err
+			}
+			continue
+		}
+		if !own {
+			manifest.Entries = setEntries(manifest.Entries, belongs)
+			if len(manifest.Entries) == 0 {
+				continue
+			}
+		}
+
+		covered := coverage(manifest.Entries, belongs)
+		score := strength(algorithm)
+		switch {
+		case best == nil, covered > bestCoverage:
+		case covered < bestCoverage, score < bestScore:
+			continue
+		case score == bestScore && (!own || bestOwn):
+			continue
+		}
+		best, bestOwn, bestCoverage, bestScore = &manifest, own, covered, score
+	}
+	return best, nil
+}
+
+// setEntries keeps entries naming a file directly in the manifest's
+// directory that belongs to the set.
+func setEntries(entries []Entry, belongs func(name string) bool) []Entry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		rel := NormalizeEntryName(entry.Name)
+		if strings.ContainsRune(rel, filepath.Separator) || !belongs(rel) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// coverage counts the distinct set volumes that entries list.
+func coverage(entries []Entry, belongs func(name string) bool) int {
+	seen := make(map[string]bool)
+	for _, entry := range setEntries(slices.Clone(entries), belongs) {
+		seen[NormalizeEntryName(entry.Name)] = true
+	}
+	return len(seen)
+}
+
+func strength(algorithm Algorithm) int {
+	for i, a := range ByStrength {
+		if a == algorithm {
+			return len(ByStrength) - i
+		}
+	}
+	return 0
+}
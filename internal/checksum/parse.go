@@ -0,0 +1,223 @@
+package checksum
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Entry is one file listed in a manifest.
+type Entry struct {
+	Name string
+	Sum  []byte
+	// Binary records the md5sum `*` marker. It does not change how the file
+	// is hashed.
+	Binary bool
+}
+
+// Manifest is a parsed checksum manifest.
+type Manifest struct {
+	Path      string
+	Algorithm Algorithm
+	Entries   []Entry
+}
+
+// ParseFile parses the manifest at path, choosing the algorithm from its
+// extension.
+func ParseFile(path string) (Manifest, error) {
+	algorithm, ok := AlgorithmForExt(filepath.Ext(path))
+	if !ok {
+		return Manifest{}, fmt.Errorf("%q is not a checksum manifest", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer file.Close()
+
+	entries, err := Parse(file, algorithm)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("parse %q: %w", path, err)
+	}
+	return Manifest{Path: path, Algorithm: algorithm, Entries: entries}, nil
+}
+
+// Parse parses manifest contents for algorithm. CRC32 manifests use the SFV
+// `name CRC` layout; the others accept md5sum-style `HEX  name` and
+// `HEX *name` lines as well as BSD-style `ALG (name) = HEX` lines.
+func Parse(r io.Reader, algorithm Algorithm) ([]Entry, error) {
+	if algorithm.Size() == 0 {
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	scanner := bufio.NewScanner(r)
+	entries := make([]Entry, 0, 16)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		var (
+			entry Entry
+			err   error
+		)
+		if algorithm == CRC32 {
+			entry, err = parseSFVLine(line)
+		} else {
+			entry, err = parseSumLine(line, algorithm)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", strings.TrimPrefix(algorithm.Ext(), "."), lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func parseSFVLine(line string) (Entry, error) {
+	last := len(line) - 1
+	for last >= 0 && unicode.IsSpace(rune(line[last])) {
+		last--
+	}
+	if last < 0 {
+		return Entry{}, fmt.Errorf("empty line")
+	}
+
+	crcEnd := last + 1
+	for last >= 0 && isHexDigit(line[last]) {
+		last--
+	}
+
+	crcStart := last + 1
+	if crcEnd-crcStart != 2*CRC32.Size() {
+		return Entry{}, fmt.Errorf("invalid crc field")
+	}
+	if last < 0 || !unicode.IsSpace(rune(line[last])) {
+		return Entry{}, fmt.Errorf("missing separator before crc")
+	}
+
+	name := strings.TrimSpace(line[:last+1])
+	if name == "" {
+		return Entry{}, fmt.Errorf("missing filename")
+	}
+
+	sum, err := hex.DecodeString(line[crcStart:crcEnd])
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid crc %q: %w", line[crcStart:crcEnd], err)
+	}
+	return Entry{Name: name, Sum: sum}, nil
+}
+
+func parseSumLine(line string, algorithm Algorithm) (Entry, error) {
+	if entry, ok, err := parseTaggedLine(line, algorithm); ok {
+		return entry, err
+	}
+
+	// GNU coreutils prefixes a line with a backslash when the file name
+	// contains a backslash or newline and escapes those characters.
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	hexLen := 2 * algorithm.Size()
+	if len(line) < hexLen+2 {
+		return Entry{}, fmt.Errorf("line too short")
+	}
+	digest := line[:hexLen]
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid %s digest %q", algorithm, digest)
+	}
+
+	rest := line[hexLen:]
+	if rest[0] != ' ' && rest[0] != '\t' {
+		return Entry{}, fmt.Errorf("missing separator after digest")
+	}
+	rest = rest[1:]
+	binary := false
+	switch rest[0] {
+	case '*':
+		binary = true
+		rest = rest[1:]
+	case ' ':
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return Entry{}, fmt.Errorf("missing filename")
+	}
+	if escaped {
+		rest = unescapeName(rest)
+	}
+	return Entry{Name: rest, Sum: sum, Binary: binary}, nil
+}
+
+// parseTaggedLine parses BSD-style `SHA256 (name) = HEX` lines. ok is false
+// when line is not in that form.
+func parseTaggedLine(line string, algorithm Algorithm) (Entry, bool, error) {
+	open := strings.Index(line, " (")
+	closing := strings.LastIndex(line, ") = ")
+	if open <= 0 || closing < open {
+		return Entry{}, false, nil
+	}
+	tag := strings.ToLower(strings.ReplaceAll(line[:open], "-", ""))
+	if tag != string(algorithm) {
+		return Entry{}, false, nil
+	}
+
+	name := line[open+2 : closing]
+	digest := strings.TrimSpace(line[closing+4:])
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != algorithm.Size() {
+		return Entry{}, true, fmt.Errorf("invalid %s digest %q", algorithm, digest)
+	}
+	if name == "" {
+		return Entry{}, true, fmt.Errorf("missing filename")
+	}
+	return Entry{Name: name, Sum: sum, Binary: true}, true, nil
+}
+
+func unescapeName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) {
+			switch name[i+1] {
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case 'r':
+				b.WriteByte('\r')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+func isHexDigit(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
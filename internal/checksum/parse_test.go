@@ -0,0 +1,81 @@
+package checksum
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseSumFormats(t *testing.T) {
+	t.Parallel()
+
+	md5Empty := "d41d8cd98f00b204e9800998ecf8427e"
+	content := "# comment\n" +
+		md5Empty + "  text name.rar\n" +
+		strings.ToUpper(md5Empty) + " *binary.r00\r\n" +
+		"MD5 (tagged (1).r01) = " + md5Empty + "\n" +
+		"\\" + md5Empty + "  back\\\\slash\\nnewline.r02\n"
+
+	entries, err := Parse(strings.NewReader(content), MD5)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	want := []struct {
+		name   string
+		binary bool
+	}{
+		{name: "text name.rar", binary: false},
+		{name: "binary.r00", binary: true},
+		{name: "tagged (1).r01", binary: true},
+		{name: "back\\slash\nnewline.r02", binary: false},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Parse returned %d entries, want %d", len(entries), len(want))
+	}
+	for i, w := range want {
+		if entries[i].Name != w.name || entries[i].Binary != w.binary {
+			t.Fatalf("entry[%d]=%+v, want name %q binary %v", i, entries[i], w.name, w.binary)
+		}
+		if got := hex.EncodeToString(entries[i].Sum); got != md5Empty {
+			t.Fatalf("entry[%d] sum=%s, want %s", i, got, md5Empty)
+		}
+	}
+}
+
+func TestParseSFV(t *testing.T) {
+	t.Parallel()
+
+	entries, err := Parse(strings.NewReader("; comment\r\nmovie one.mkv A1B2C3D4\r\n"), CRC32)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "movie one.mkv" || hex.EncodeToString(entries[0].Sum) != "a1b2c3d4" {
+		t.Fatalf("entries=%+v", entries)
+	}
+}
+
+func TestParseRejectsMalformedLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		line      string
+	}{
+		{name: "short digest", algorithm: SHA1, line: "d41d8cd98f00b204e9800998ecf8427e  file.rar"},
+		{name: "missing name", algorithm: MD5, line: "d41d8cd98f00b204e9800998ecf8427e  "},
+		{name: "non-hex digest", algorithm: MD5, line: "zz1d8cd98f00b204e9800998ecf8427e  file.rar"},
+		{name: "wrong tagged algorithm size", algorithm: SHA256, line: "SHA256 (file.rar) = d41d8cd98f00b204e9800998ecf8427e"},
+		{name: "sfv without crc", algorithm: CRC32, line: "missing-crc-value"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := Parse(strings.NewReader(tc.line+"\n"), tc.algorithm); err == nil {
+				t.Fatalf("expected parse error for %q", tc.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+// Mismatch describes a file whose checksum differs from its manifest entry.
+// Expected and Actual are lowercase hex digests.
+type Mismatch struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+// VerificationError captures all missing and mismatched manifest entries.
+type VerificationError struct {
+	Algorithm  Algorithm
+	Manifest   string
+	Missing    []string
+	Mismatches []Mismatch
+}
+
+// Error implements the error interface.
+func (e *VerificationError) Error() string {
+	kind := "sfv"
+	if e.Algorithm != "" && e.Algorithm != CRC32 {
+		kind = string(e.Algorithm)
+	}
+	return fmt.Sprintf(
+		"%s verification failed: %d missing, %d mismatched",
+		kind,
+		len(e.Missing),
+		len(e.Mismatches),
+	)
+}
+
+// DefaultWorkers bounds how many files a Verifier hashes concurrently when
+// Workers is unset.
+var DefaultWorkers = min(runtime.NumCPU(), 4)
+
+// Verifier checks manifests against files on disk.
+type Verifier struct {
+	// Workers bounds concurrent hashing; values < 1 use DefaultWorkers.
+	Workers int
+	// Cache, when set, supplies sums for unchanged files and records new ones.
+	Cache *Cache
+	// Rehash ignores cached sums. Fresh sums are still recorded.
+	Rehash bool
+	// Precomputed holds sums already computed for this manifest's
+	// algorithm, keyed by absolute path, for example by a HashingFS during
+	// extraction. Matching files are not read again.
+	Precomputed map[string][]byte
+	// Context, when set, stops hashing once done; Verify then fails with
+	// its error.
+	Context context.Context
+}
+
+// Verify checks every entry of m against files under baseDir without a cache.
+func Verify(baseDir string, m Manifest) error {
+	return Verifier{}.Verify(baseDir, m)
+}
+
+type entryResult struct {
+	sum []byte
+	err error
+}
+
+// Verify checks every entry of m against files under baseDir, hashing up to
+// v.Workers files at a time. Missing and mismatched entries are reported in
+// manifest order.
+func (v Verifier) Verify(baseDir string, m Manifest) error {
+	workers := v.Workers
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	results := make([]entryResult, len(m.Entries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(m.Entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				target := filepath.Join(baseDir, NormalizeEntryName(m.Entries[i].Name))
+				sum, err := v.fileSum(target, m.Algorithm)
+				results[i] = entryResult{sum: sum, err: err}
+			}
+		}()
+	}
+	for i := range m.Entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	missing := make([]string, 0)
+	mismatches := make([]Mismatch, 0)
+	for i, entry := range m.Entries {
+		actual, err := results[i].sum, results[i].err
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				missing = append(missing, entry.Name)
+				continue
+			}
+			return fmt.Errorf("verify %q: %w", entry.Name, err)
+		}
+
+		if !bytes.Equal(actual, entry.Sum) {
+			mismatches = append(mismatches, Mismatch{
+				Name:     entry.Name,
+				Expected: hex.EncodeToString(entry.Sum),
+				Actual:   hex.EncodeToString(actual),
+			})
+		}
+	}
+
+	if len(missing) > 0 || len(mismatches) > 0 {
+		return &VerificationError{
+			Algorithm:  m.Algorithm,
+			Manifest:   m.Path,
+			Missing:    missing,
+			Mismatches: mismatches,
+		}
+	}
+	return nil
+}
+
+func (v Verifier) fileSum(path string, algorithm Algorithm) ([]byte, error) {
+	if v.Cache == nil && v.Precomputed == nil {
+		return FileSumContext(v.Context, path, algorithm)
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	if sum, ok := v.Precomputed[key]; ok {
+		v.Cache.Put(key, algorithm, info, sum)
+		return sum, nil
+	}
+	if !v.Rehash {
+		if sum, ok := v.Cache.Lookup(key, algorithm, info); ok {
+			return sum, nil
+		}
+	}
+
+	sum, err := FileSumContext(v.Context, key, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	// A file modified while it was hashed must not be cached under its
+	// earlier identity.
+	if after, err := os.Stat(key); err == nil && after.Size() == info.Size() && after.ModTime().Equal(info.ModTime()) {
+		v.Cache.Put(key, algorithm, info, sum)
+	}
+	return sum, nil
+}
+
+// NormalizeEntryName converts a manifest entry name to a native relative path.
+// Manifests written on Windows use backslash separators.
+func NormalizeEntryName(name string) string {
+	normalized := strings.ReplaceAll(name, "\\", "/")
+	return filepath.FromSlash(normalized)
+}
+
+// FileSum hashes the file at path with algorithm.
+func FileSum(path string, algorithm Algorithm) ([]byte, error) {
+	return FileSumContext(context.Background(), path, algorithm)
+}
+
+// FileSumContext is FileSum that stops reading once ctx is done and returns
+// ctx.Err(). A nil ctx is never done.
+func FileSumContext(ctx context.Context, path string, algorithm Algorithm) ([]byte, error) {
+	h := algorithm.New()
+	if h == nil {
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, fsutil.ContextReader(ctx, file)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,195 @@
+package checksum
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyReportsMissingAndMismatchedEntries(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "ok.rar"), []byte("ok"), 0o644); err != nil {
+		t.Fatalf("write ok file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bad.r00"), []byte("bad"), 0o644); err != nil {
+		t.Fatalf("write bad file: %v", err)
+	}
+
+	okSum := sha256.Sum256([]byte("ok"))
+	badSum := sha256.Sum256([]byte("expected"))
+	manifest := Manifest{
+		Path:      filepath.Join(root, "set.sha256"),
+		Algorithm: SHA256,
+		Entries: []Entry{
+			{Name: "ok.rar", Sum: okSum[:]},
+			{Name: "bad.r00", Sum: badSum[:]},
+			{Name: "missing.r01", Sum: okSum[:]},
+		},
+	}
+
+	err := Verify(root, manifest)
+	var verificationErr *VerificationError
+	if !errors.As(err, &verificationErr) {
+		t.Fatalf("Verify returned %v, want *VerificationError", err)
+	}
+	if verificationErr.Algorithm != SHA256 || verificationErr.Manifest != manifest.Path {
+		t.Fatalf("error=%+v, want algorithm and manifest recorded", verificationErr)
+	}
+	if len(verificationErr.Missing) != 1 || verificationErr.Missing[0] != "missing.r01" {
+		t.Fatalf("missing=%v", verificationErr.Missing)
+	}
+	actual := sha256.Sum256([]byte("bad"))
+	want := Mismatch{Name: "bad.r00", Expected: hex.EncodeToString(badSum[:]), Actual: hex.EncodeToString(actual[:])}
+	if len(verificationErr.Mismatches) != 1 || verificationErr.Mismatches[0] != want {
+		t.Fatalf("mismatches=%+v, want [%+v]", verificationErr.Mismatches, want)
+	}
+}
+
+func TestVerifierStopsWhenCanceled(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "set.rar"), []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	sum := sha256.Sum256([]byte("volume"))
+	manifest := Manifest{Algorithm: SHA256, Entries: []Entry{{Name: "set.rar", Sum: sum[:]}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := (Verifier{Context: ctx}).Verify(root, manifest); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Verify returned %v, want context.Canceled", err)
+	}
+	if err := (Verifier{Context: context.Background()}).Verify(root, manifest); err != nil {
+		t.Fatalf("Verify returned %v, want success", err)
+	}
+}
+
+func TestDiscoverChoosesApplicableManifest(t *testing.T) {
+	t.Parallel()
+
+	md5Of := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	belongs := func(name string) bool {
+		return name == "title.part01.rar" || name == "title.part02.rar"
+	}
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantFile    string
+		wantEntries []string
+	}{
+		{
+			name:  "no manifest",
+			files: map[string]string{"readme.txt": "x"},
+		},
+		{
+			name: "group manifest narrowed to set volumes",
+			files: map[string]string{
+				"grp-title.sfv": "title.part01.rar 00000000\nother.part01.rar 00000000\ntitle.nfo 00000000\n",
+			},
+			wantFile:    "grp-title.sfv",
+			wantEntries: []string{"title.part01.rar"},
+		},
+		{
+			name: "unrelated manifest ignored",
+			files: map[string]string{
+				"other.sfv": "other.part01.rar 00000000\n",
+			},
+		},
+		{
+			name: "stronger manifest wins",
+			files: map[string]string{
+				"title.sfv":     "title.part01.rar 00000000\n",
+				"grp-title.md5": md5Of("a") + "  title.part02.rar\n",
+			},
+			wantFile:    "grp-title.md5",
+			wantEntries: []string{"title.part02.rar"},
+		},
+		{
+			name: "wider coverage beats strength",
+			files: map[string]string{
+				"grp-title.md5": md5Of("a") + "  title.part02.rar\n",
+				"title.sfv":     "title.part01.rar 00000000\ntitle.part02.rar 00000000\n",
+			},
+			wantFile:    "title.sfv",
+			wantEntries: []string{"title.part01.rar", "title.part02.rar"},
+		},
+		{
+			name: "stem manifest wins ties and applies in full",
+			files: map[string]string{
+				"a-title.sfv": "title.part01.rar 00000000\n",
+				"title.sfv":   "title.part01.rar 00000000\ntitle.nfo 00000000\n",
+			},
+			wantFile:    "title.sfv",
+			wantEntries: []string{"title.part01.rar", "title.nfo"},
+		},
+		{
+			name: "malformed unrelated manifest skipped",
+			files: map[string]string{
+				"broken.sha1": "not a checksum line\n",
+				"grp.sfv":     "title.part02.rar 00000000\n",
+			},
+			wantFile:    "grp.sfv",
+			wantEntries: []string{"title.part02.rar"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+			}
+
+			manifest, err := Discover(root, "title", belongs)
+			if err != nil {
+				t.Fatalf("Discover returned error: %v", err)
+			}
+			if tc.wantFile == "" {
+				if manifest != nil {
+					t.Fatalf("Discover=%q, want none", manifest.Path)
+				}
+				return
+			}
+			if manifest == nil || filepath.Base(manifest.Path) != tc.wantFile {
+				t.Fatalf("Discover=%v, want %s", manifest, tc.wantFile)
+			}
+			var names []string
+			for _, entry := range manifest.Entries {
+				names = append(names, entry.Name)
+			}
+			if !reflect.DeepEqual(names, tc.wantEntries) {
+				t.Fatalf("entries=%v, want %v", names, tc.wantEntries)
+			}
+		})
+	}
+}
+
+func TestDiscoverFailsOnMalformedStemManifest(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "title.sfv"), []byte("garbage\n"), 0o644); err != nil {
+		t.Fatalf("write sfv: %v", err)
+	}
+	if _, err := Discover(root, "title", func(string) bool { return true }); err == nil {
+		t.Fatal("expected parse error for the set's own manifest")
+	}
+}
@@ -0,0 +1,65 @@
+package checksum
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// GeneratedSuffix marks manifests unrarall writes for extracted output, as
+// in `<stem>.extracted.sfv`. It keeps them apart from release manifests
+// describing archive volumes.
+const GeneratedSuffix = ".extracted"
+
+// GeneratedName returns the name of the extracted-output manifest for stem.
+func GeneratedName(stem string, algorithm Algorithm) string {
+	return stem + GeneratedSuffix + algorithm.Ext()
+}
+
+// IsGenerated reports whether name is an extracted-output manifest.
+func IsGenerated(name string) bool {
+	ext := filepath.Ext(name)
+	if _, ok := AlgorithmForExt(ext); !ok {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(strings.TrimSuffix(name, ext)), GeneratedSuffix)
+}
+
+// Write writes entries as an algorithm manifest that Parse reads back:
+// `name CRC` lines for CRC32 and md5sum-style `HEX  name` lines otherwise.
+// Entry names should use forward slashes.
+func Write(w io.Writer, algorithm Algorithm, entries []Entry) error {
+	if algorithm.Size() == 0 {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		if len(entry.Sum) != algorithm.Size() {
+			return fmt.Errorf("%s sum for %q has %d bytes, want %d", algorithm, entry.Name, len(entry.Sum), algorithm.Size())
+		}
+		digest := hex.EncodeToString(entry.Sum)
+		if algorithm == CRC32 {
+			if strings.ContainsAny(entry.Name, "\r\n") {
+				return fmt.Errorf("sfv cannot list %q: name contains a line break", entry.Name)
+			}
+			fmt.Fprintf(bw, "%s %s\n", entry.Name, strings.ToUpper(digest))
+			continue
+		}
+		// Match GNU coreutils: escape backslashes and line breaks and flag
+		// the line with a leading backslash.
+		if strings.ContainsAny(entry.Name, "\\\n\r") {
+			fmt.Fprintf(bw, "\\%s  %s\n", digest, escapeName(entry.Name))
+			continue
+		}
+		fmt.Fprintf(bw, "%s  %s\n", digest, entry.Name)
+	}
+	return bw.Flush()
+}
+
+func escapeName(name string) string {
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r").Replace(name)
+}
@@ -0,0 +1,70 @@
+package checksum
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWriteRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		entries   []Entry
+	}{
+		{
+			name:      "sfv",
+			algorithm: CRC32,
+			entries: []Entry{
+				{Name: "movie name.mkv", Sum: []byte{0xb9, 0x9a, 0xcd, 0xde}},
+				{Name: "subs/movie.srt", Sum: []byte{0x00, 0x00, 0x00, 0x01}},
+			},
+		},
+		{
+			name:      "sha256 with escaped name",
+			algorithm: SHA256,
+			entries: []Entry{
+				{Name: "plain.mkv", Sum: bytes.Repeat([]byte{0xab}, 32)},
+				{Name: "back\\slash\nline.txt", Sum: bytes.Repeat([]byte{0x01}, 32)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := Write(&buf, tc.algorithm, tc.entries); err != nil {
+				t.Fatalf("Write returned error: %v", err)
+			}
+			got, err := Parse(&buf, tc.algorithm)
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.entries) {
+				t.Fatalf("round trip=%+v, want %+v", got, tc.entries)
+			}
+		})
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		GeneratedName("Movie", CRC32):  true,
+		GeneratedName("Movie", SHA256): true,
+		"Movie.EXTRACTED.SFV":          true,
+		"Movie.sfv":                    false,
+		"Movie.extracted.txt":          false,
+		"extracted.sfv":                false,
+	}
+	for name, want := range tests {
+		if got := IsGenerated(name); got != want {
+			t.Fatalf("IsGenerated(%q)=%v, want %v", name, got, want)
+		}
+	}
+}
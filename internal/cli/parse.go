@@ -4,12 +4,18 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
 	"github.com/arodd/go-unrarall/internal/hooks"
+	"github.com/arodd/go-unrarall/internal/journal"
+	"github.com/arodd/go-unrarall/internal/passwords"
+	"github.com/arodd/go-unrarall/internal/plan"
 )
 
 // Options contains parsed command-line options.
@@ -27,26 +33,131 @@ type Options struct {
 	Verbose       bool
 	AllowFailures bool
 
-	CKSFV        bool
-	PasswordFile string
+	// SkipJournal skips sets the journal records as extracted from
+	// unchanged volumes.
+	SkipJournal bool
+	// Journal is the journal file path, or empty when journaling is off. The
+	// default journal is only used with SkipJournal; other runs record
+	// sets only when --journal names a file.
+	Journal string
+	// Report is the path of the JSON run report, or empty for none.
+	Report string
+	// Plan is the dry-run plan format, "text" or "json"; empty unless
+	// DryRun is set.
+	Plan string
+
+	CKSFV bool
+	// Rehash ignores cached checksums and hashes every volume again.
+	Rehash bool
+	// SinglePassVerify hashes volumes while extracting them instead of in a
+	// separate pass before extraction.
+	SinglePassVerify bool
+	// WriteManifest, when set, writes a manifest of the extracted files
+	// with this algorithm next to them.
+	WriteManifest checksum.Algorithm
+
+	PasswordFile  string
+	PasswordMap   string
+	PasswordStore string
+	// PasswordCommand and PasswordEnv name external password sources.
+	PasswordCommand string
+	PasswordEnv     string
+	// NoPasswordPrompt disables the interactive prompt on a terminal.
+	NoPasswordPrompt bool
+	// PassphraseFD is the file descriptor holding the vault passphrase, or
+	// -1 when unset.
+	PassphraseFD int
 
 	CleanHooks   []string
 	MaxDictBytes int64
 
+	// Jobs bounds how many top-level candidates are processed at once.
+	Jobs int
+	// JobsPerDevice bounds concurrent candidates whose volumes share a
+	// source device; 0 picks Jobs on solid-state devices and 1 otherwise.
+	JobsPerDevice int
+
+	// OnExtracted, OnFailed, and OnFinished are shell commands run after a
+	// top-level set is extracted, fails, or finishes with any outcome.
+	OnExtracted string
+	OnFailed    string
+	OnFinished  string
+	// CommandTimeout bounds each --on-* command; 0 means no limit.
+	CommandTimeout time.Duration
+
+	// Webhook is the URL run events are posted to, or empty for none.
+	Webhook string
+	// WebhookSecretEnv names the environment variable holding the HMAC
+	// key that signs webhook bodies.
+	WebhookSecretEnv string
+	// WebhookTimeout bounds each delivery attempt.
+	WebhookTimeout time.Duration
+	// WebhookRetries is the number of attempts after a failed first one.
+	WebhookRetries int
+
+	// MetricsFile is where Prometheus metrics are written in textfile
+	// format after each run, or empty for none.
+	MetricsFile string
+
+	// NoLock disables the run lock and the per-set lock files.
+	NoLock bool
+
+	// ArchiveTimeout bounds how long one top-level set may take before it
+	// is rolled back; 0 means no limit.
+	ArchiveTimeout time.Duration
+	// MaxRuntime is how long after its start a run (or watch pass) stops
+	// starting sets; 0 means no limit.
+	MaxRuntime time.Duration
+	// MaxRuntimePolicy is what happens to sets running at the MaxRuntime
+	// deadline: RuntimeFinish or RuntimeAbort.
+	MaxRuntimePolicy string
+
+	// IORetries is how many times a set is started over after a transient
+	// filesystem error.
+	IORetries int
+	// IORetryDelay is the wait before the first retry; it doubles after
+	// each one.
+	IORetryDelay time.Duration
+
+	// Archive, when set, limits the run to the set whose first volume it
+	// names; Dir is then its directory.
+	Archive string
+
 	ShowHelp    bool
 	ShowVersion bool
 }
 
+// --max-runtime-policy values.
+const (
+	// RuntimeFinish lets sets running at the deadline finish.
+	RuntimeFinish = "finish"
+	// RuntimeAbort rolls back sets running at the deadline.
+	RuntimeAbort = "abort"
+)
+
 // ParseArgs parses and validates command-line arguments.
 func ParseArgs(args []string) (Options, error) {
+	return parseOptions("unrarall", args[1:], nil)
+}
+
+// parseOptions parses one-shot options from args, which excludes the
+// program name. register, when set, adds subcommand flags to the set.
+func parseOptions(name string, args []string, register func(*flag.FlagSet)) (Options, error) {
 	opts := defaultOptions()
-	fs := flag.NewFlagSet("unrarall", flag.ContinueOnError)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
+	if register != nil {
+		register(fs)
+	}
 
 	var (
-		disableCK bool
-		cleanSpec string
-		logFile   requiredPathFlag
+		disableCK       bool
+		noPasswordStore bool
+		noJournal       bool
+		skipSpec        string
+		cleanSpec       string
+		manifestSpec    string
+		logFile         requiredPathFlag
 	)
 
 	fs.BoolVar(&opts.Verbose, "verbose", false, "")
@@ -55,26 +166,59 @@ func ParseArgs(args []string) (Options, error) {
 	fs.BoolVar(&opts.Quiet, "q", false, "")
 	fs.BoolVar(&opts.DryRun, "dry", false, "")
 	fs.BoolVar(&opts.DryRun, "d", false, "")
+	fs.StringVar(&opts.Plan, "plan", "", "")
 	fs.BoolVar(&opts.Force, "force", false, "")
 	fs.BoolVar(&opts.Force, "f", false, "")
 	fs.BoolVar(&opts.AllowFailures, "allow-failures", false, "")
 	fs.BoolVar(&disableCK, "disable-cksfv", false, "")
 	fs.BoolVar(&disableCK, "s", false, "")
+	fs.BoolVar(&opts.Rehash, "rehash", false, "")
+	fs.BoolVar(&opts.SinglePassVerify, "single-pass", false, "")
+	fs.StringVar(&manifestSpec, "write-manifest", "", "")
 	fs.BoolVar(&opts.FullPath, "full-path", false, "")
 	fs.BoolVar(&opts.AllowSymlinks, "allow-symlinks", false, "")
 	fs.IntVar(&opts.Depth, "depth", 4, "")
 	fs.BoolVar(&opts.SkipIfExists, "skip-if-exists", false, "")
+	fs.StringVar(&skipSpec, "skip", "", "")
+	fs.StringVar(&opts.Journal, "journal", opts.Journal, "")
+	fs.BoolVar(&noJournal, "no-journal", false, "")
+	fs.StringVar(&opts.Report, "report", "", "")
 	fs.StringVar(&opts.OutputDir, "output", "", "")
 	fs.StringVar(&opts.OutputDir, "o", "", "")
 	fs.Var(&logFile, "log-file", "")
 	fs.StringVar(&opts.PasswordFile, "password-file", opts.PasswordFile, "")
+	fs.StringVar(&opts.PasswordMap, "password-map", "", "")
+	fs.StringVar(&opts.PasswordStore, "password-store", opts.PasswordStore, "")
+	fs.BoolVar(&noPasswordStore, "no-password-store", false, "")
+	fs.StringVar(&opts.PasswordCommand, "password-command", "", "")
+	fs.StringVar(&opts.PasswordEnv, "password-env", "", "")
+	fs.BoolVar(&opts.NoPasswordPrompt, "no-password-prompt", false, "")
+	fs.IntVar(&opts.PassphraseFD, "passphrase-fd", -1, "")
 	fs.StringVar(&cleanSpec, "clean", "none", "")
 	fs.Int64Var(&opts.MaxDictBytes, "max-dict", 1<<30, "")
+	fs.IntVar(&opts.Jobs, "jobs", 1, "")
+	fs.IntVar(&opts.Jobs, "j", 1, "")
+	fs.IntVar(&opts.JobsPerDevice, "jobs-per-device", 0, "")
+	fs.StringVar(&opts.OnExtracted, "on-extracted", "", "")
+	fs.StringVar(&opts.OnFailed, "on-failed", "", "")
+	fs.StringVar(&opts.OnFinished, "on-finished", "", "")
+	fs.DurationVar(&opts.CommandTimeout, "command-timeout", 10*time.Minute, "")
+	fs.StringVar(&opts.Webhook, "webhook", "", "")
+	fs.StringVar(&opts.WebhookSecretEnv, "webhook-secret-env", "", "")
+	fs.DurationVar(&opts.WebhookTimeout, "webhook-timeout", 10*time.Second, "")
+	fs.IntVar(&opts.WebhookRetries, "webhook-retries", 3, "")
+	fs.StringVar(&opts.MetricsFile, "metrics-file", "", "")
+	fs.BoolVar(&opts.NoLock, "no-lock", false, "")
+	fs.DurationVar(&opts.ArchiveTimeout, "archive-timeout", 0, "")
+	fs.DurationVar(&opts.MaxRuntime, "max-runtime", 0, "")
+	fs.StringVar(&opts.MaxRuntimePolicy, "max-runtime-policy", RuntimeFinish, "")
+	fs.IntVar(&opts.IORetries, "io-retries", 0, "")
+	fs.DurationVar(&opts.IORetryDelay, "io-retry-delay", time.Second, "")
 	fs.BoolVar(&opts.ShowVersion, "version", false, "")
 	fs.BoolVar(&opts.ShowHelp, "help", false, "")
 	fs.BoolVar(&opts.ShowHelp, "h", false, "")
 
-	if err := fs.Parse(args[1:]); err != nil {
+	if err := fs.Parse(args); err != nil {
 		return Options{}, err
 	}
 
@@ -88,12 +232,47 @@ func ParseArgs(args []string) (Options, error) {
 	if opts.MaxDictBytes <= 0 {
 		return Options{}, fmt.Errorf("--max-dict must be > 0")
 	}
+	if opts.Jobs < 1 {
+		return Options{}, fmt.Errorf("--jobs must be >= 1")
+	}
+	if opts.JobsPerDevice < 0 {
+		return Options{}, fmt.Errorf("--jobs-per-device must be >= 0")
+	}
+	if opts.CommandTimeout < 0 {
+		return Options{}, fmt.Errorf("--command-timeout must be >= 0")
+	}
+	if err := validateTimeLimits(opts); err != nil {
+		return Options{}, err
+	}
+	if strings.Contains(opts.PasswordEnv, "=") {
+		return Options{}, fmt.Errorf("--password-env must name an environment variable")
+	}
+	if err := validateWebhook(opts); err != nil {
+		return Options{}, err
+	}
 
 	hooks, err := parseCleanHooks(cleanSpec)
 	if err != nil {
 		return Options{}, err
 	}
 	opts.CleanHooks = hooks
+	opts.WriteManifest, err = parseManifestFormat(manifestSpec)
+	if err != nil {
+		return Options{}, err
+	}
+	if err := parseSkipModes(skipSpec, &opts); err != nil {
+		return Options{}, err
+	}
+	switch opts.Plan {
+	case "":
+		if opts.DryRun {
+			opts.Plan = plan.Text
+		}
+	case plan.Text, plan.JSON:
+		opts.DryRun = true
+	default:
+		return Options{}, fmt.Errorf("invalid --plan format %q (want text or json)", opts.Plan)
+	}
 	opts.CKSFV = !disableCK
 
 	if logFile.set {
@@ -125,6 +304,49 @@ func ParseArgs(args []string) (Options, error) {
 			return Options{}, fmt.Errorf("failed to resolve output directory path: %w", err)
 		}
 	}
+	if noPasswordStore {
+		opts.PasswordStore = ""
+	}
+	if opts.PasswordStore != "" {
+		opts.PasswordStore, err = filepath.Abs(opts.PasswordStore)
+		if err != nil {
+			return Options{}, fmt.Errorf("failed to resolve password store path: %w", err)
+		}
+	}
+	journalSet := false
+	fs.Visit(func(f *flag.Flag) {
+		journalSet = journalSet || f.Name == "journal"
+	})
+	if noJournal || (!journalSet && !opts.SkipJournal) {
+		opts.Journal = ""
+	}
+	if opts.Journal != "" {
+		opts.Journal, err = filepath.Abs(opts.Journal)
+		if err != nil {
+			return Options{}, fmt.Errorf("failed to resolve journal path: %w", err)
+		}
+	}
+	if opts.SkipJournal && opts.Journal == "" {
+		return Options{}, fmt.Errorf("--skip=journal requires a journal; set --journal FILE")
+	}
+	if opts.Report != "" {
+		opts.Report, err = filepath.Abs(opts.Report)
+		if err != nil {
+			return Options{}, fmt.Errorf("failed to resolve report path: %w", err)
+		}
+	}
+	if opts.MetricsFile != "" {
+		opts.MetricsFile, err = filepath.Abs(opts.MetricsFile)
+		if err != nil {
+			return Options{}, fmt.Errorf("failed to resolve metrics file path: %w", err)
+		}
+	}
+	if opts.PasswordMap != "" {
+		opts.PasswordMap, err = filepath.Abs(opts.PasswordMap)
+		if err != nil {
+			return Options{}, fmt.Errorf("failed to resolve password map path: %w", err)
+		}
+	}
 
 	if err := validatePaths(opts); err != nil {
 		return Options{}, err
@@ -133,16 +355,68 @@ func ParseArgs(args []string) (Options, error) {
 	return opts, nil
 }
 
+func validateTimeLimits(opts Options) error {
+	if opts.ArchiveTimeout < 0 {
+		return fmt.Errorf("--archive-timeout must be >= 0")
+	}
+	if opts.MaxRuntime < 0 {
+		return fmt.Errorf("--max-runtime must be >= 0")
+	}
+	if opts.MaxRuntimePolicy != RuntimeFinish && opts.MaxRuntimePolicy != RuntimeAbort {
+		return fmt.Errorf("invalid --max-runtime-policy %q (want finish or abort)", opts.MaxRuntimePolicy)
+	}
+	if opts.IORetries < 0 {
+		return fmt.Errorf("--io-retries must be >= 0")
+	}
+	if opts.IORetryDelay <= 0 {
+		return fmt.Errorf("--io-retry-delay must be > 0")
+	}
+	return nil
+}
+
+func validateWebhook(opts Options) error {
+	if opts.WebhookTimeout <= 0 {
+		return fmt.Errorf("--webhook-timeout must be > 0")
+	}
+	if opts.WebhookRetries < 0 {
+		return fmt.Errorf("--webhook-retries must be >= 0")
+	}
+	if strings.Contains(opts.WebhookSecretEnv, "=") {
+		return fmt.Errorf("--webhook-secret-env must name an environment variable")
+	}
+	if opts.Webhook == "" {
+		if opts.WebhookSecretEnv != "" {
+			return fmt.Errorf("--webhook-secret-env requires --webhook")
+		}
+		return nil
+	}
+	u, err := url.Parse(opts.Webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid --webhook URL %q (want http:// or https://)", opts.Webhook)
+	}
+	return nil
+}
+
 func defaultOptions() Options {
 	return Options{
 		Depth:         4,
 		CKSFV:         true,
 		CleanHooks:    []string{"none"},
 		MaxDictBytes:  1 << 30,
+		Jobs:          1,
 		PasswordFile:  defaultPasswordFile(),
+		PasswordStore: passwords.DefaultStorePath(),
+		Journal:       journal.DefaultPath(),
+		PassphraseFD:  -1,
 		ShowHelp:      false,
 		ShowVersion:   false,
 		AllowFailures: false,
+
+		WebhookTimeout: 10 * time.Second,
+		WebhookRetries: 3,
+
+		MaxRuntimePolicy: RuntimeFinish,
+		IORetryDelay:     time.Second,
 	}
 }
 
@@ -203,6 +477,40 @@ func parseCleanHooks(spec string) ([]string, error) {
 	return out, nil
 }
 
+// parseManifestFormat maps a --write-manifest value to its algorithm. An
+// empty value disables manifest writing.
+func parseManifestFormat(spec string) (checksum.Algorithm, error) {
+	switch strings.ToLower(strings.TrimSpace(spec)) {
+	case "":
+		return "", nil
+	case "sfv":
+		return checksum.CRC32, nil
+	case "sha256":
+		return checksum.SHA256, nil
+	default:
+		return "", fmt.Errorf("--write-manifest must be sfv or sha256, got %q", spec)
+	}
+}
+
+// parseSkipModes applies a comma-separated --skip value: "exists" is the
+// same as --skip-if-exists and "journal" enables SkipJournal.
+func parseSkipModes(spec string, opts *Options) error {
+	if strings.TrimSpace(spec) == "" {
+		return nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "exists":
+			opts.SkipIfExists = true
+		case "journal":
+			opts.SkipJournal = true
+		default:
+			return fmt.Errorf("--skip modes must be journal or exists, got %q", part)
+		}
+	}
+	return nil
+}
+
 func isKnownHook(name string) bool {
 	return hooks.IsKnown(name)
 }
@@ -4,6 +4,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/checksum"
+	"github.com/arodd/go-unrarall/internal/journal"
 )
 
 func TestParseArgsSecurityDefaults(t *testing.T) {
@@ -170,3 +174,305 @@ func TestParseArgsRejectsEmptyCleanSpec(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestParseArgsResolvesPasswordMap(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	mapPath := filepath.Join("conf", "passwords.map")
+
+	opts, err := ParseArgs([]string{"unrarall", "--password-map", mapPath, root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+
+	absMapPath, err := filepath.Abs(mapPath)
+	if err != nil {
+		t.Fatalf("filepath.Abs returned error: %v", err)
+	}
+	if opts.PasswordMap != absMapPath {
+		t.Fatalf("PasswordMap=%q, want %q", opts.PasswordMap, absMapPath)
+	}
+}
+
+func TestParseArgsNoPasswordStoreDisablesStore(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", "--password-store", filepath.Join(root, "store.json"), "--no-password-store", root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.PasswordStore != "" {
+		t.Fatalf("PasswordStore=%q, want empty", opts.PasswordStore)
+	}
+}
+
+func TestParseArgsExternalPasswordSources(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", "--password-env", "ARCHIVE_PASSWORDS", "--password-command", "pass show rar", "--no-password-prompt", dir})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.PasswordEnv != "ARCHIVE_PASSWORDS" || opts.PasswordCommand != "pass show rar" || !opts.NoPasswordPrompt {
+		t.Fatalf("options=%+v, want external password sources set", opts)
+	}
+
+	if _, err := ParseArgs([]string{"unrarall", "--password-env", "A=B", dir}); err == nil {
+		t.Fatal("expected error for --password-env containing '='")
+	}
+}
+
+func TestParseArgsRehash(t *testing.T) {
+	t.Parallel()
+
+	opts, err := ParseArgs([]string{"unrarall", "--rehash", t.TempDir()})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if !opts.Rehash {
+		t.Fatal("expected Rehash to be set")
+	}
+}
+
+func TestParseArgsWriteManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	tests := []struct {
+		value string
+		want  checksum.Algorithm
+	}{
+		{value: "sfv", want: checksum.CRC32},
+		{value: "SHA256", want: checksum.SHA256},
+	}
+	for _, tc := range tests {
+		opts, err := ParseArgs([]string{"unrarall", "--write-manifest=" + tc.value, dir})
+		if err != nil {
+			t.Fatalf("ParseArgs(%q) returned error: %v", tc.value, err)
+		}
+		if opts.WriteManifest != tc.want {
+			t.Fatalf("WriteManifest=%q, want %q", opts.WriteManifest, tc.want)
+		}
+	}
+	if _, err := ParseArgs([]string{"unrarall", "--write-manifest=md5", dir}); err == nil {
+		t.Fatal("expected error for unsupported --write-manifest format")
+	}
+}
+
+func TestParseArgsJobs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", dir})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.Jobs != 1 || opts.JobsPerDevice != 0 {
+		t.Fatalf("Jobs=%d JobsPerDevice=%d, want 1 and 0", opts.Jobs, opts.JobsPerDevice)
+	}
+
+	opts, err = ParseArgs([]string{"unrarall", "-j", "4", "--jobs-per-device", "2", dir})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.Jobs != 4 || opts.JobsPerDevice != 2 {
+		t.Fatalf("Jobs=%d JobsPerDevice=%d, want 4 and 2", opts.Jobs, opts.JobsPerDevice)
+	}
+
+	for _, args := range [][]string{{"--jobs", "0"}, {"--jobs-per-device", "-1"}} {
+		if _, err := ParseArgs(append(append([]string{"unrarall"}, args...), dir)); err == nil {
+			t.Fatalf("ParseArgs(%v) expected error", args)
+		}
+	}
+}
+
+func TestParseArgsSkipModes(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	journalPath := filepath.Join(root, "journal.jsonl")
+	opts, err := ParseArgs([]string{"unrarall", "--skip=journal,EXISTS", "--journal", journalPath, root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if !opts.SkipJournal || !opts.SkipIfExists || opts.Journal != journalPath {
+		t.Fatalf("options=%+v, want both skip modes and journal %q", opts, journalPath)
+	}
+
+	if _, err := ParseArgs([]string{"unrarall", "--skip=hash", root}); err == nil {
+		t.Fatal("expected error for unknown --skip mode")
+	}
+	if _, err := ParseArgs([]string{"unrarall", "--skip=journal", "--no-journal", root}); err == nil {
+		t.Fatal("expected error for --skip=journal without a journal")
+	}
+	opts, err = ParseArgs([]string{"unrarall", "--no-journal", root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.Journal != "" {
+		t.Fatalf("Journal=%q, want empty", opts.Journal)
+	}
+
+	// Only runs that skip by the default journal record to it.
+	opts, err = ParseArgs([]string{"unrarall", root})
+	if err != nil || opts.Journal != "" {
+		t.Fatalf("Journal=%q err=%v, want no journal by default", opts.Journal, err)
+	}
+	if path := journal.DefaultPath(); path != "" {
+		opts, err = ParseArgs([]string{"unrarall", "--skip=journal", root})
+		if err != nil || opts.Journal != path {
+			t.Fatalf("Journal=%q err=%v, want the default journal %q with --skip=journal", opts.Journal, err, path)
+		}
+	}
+}
+
+func TestParseArgsReport(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	opts, err := ParseArgs([]string{"unrarall", "--report", "run.json", root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if want := filepath.Join(root, "run.json"); opts.Report != want {
+		t.Fatalf("Report=%q, want %q", opts.Report, want)
+	}
+}
+
+func TestParseArgsPlan(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	tests := []struct {
+		args     []string
+		wantPlan string
+		wantDry  bool
+	}{
+		{args: []string{"unrarall", root}},
+		{args: []string{"unrarall", "--dry", root}, wantPlan: "text", wantDry: true},
+		{args: []string{"unrarall", "--plan=json", root}, wantPlan: "json", wantDry: true},
+	}
+	for _, tt := range tests {
+		opts, err := ParseArgs(tt.args)
+		if err != nil {
+			t.Fatalf("ParseArgs(%q) returned error: %v", tt.args, err)
+		}
+		if opts.Plan != tt.wantPlan || opts.DryRun != tt.wantDry {
+			t.Fatalf("ParseArgs(%q): Plan=%q DryRun=%v, want %q %v", tt.args, opts.Plan, opts.DryRun, tt.wantPlan, tt.wantDry)
+		}
+	}
+	if _, err := ParseArgs([]string{"unrarall", "--plan=yaml", root}); err == nil {
+		t.Fatal("expected error for unknown --plan format")
+	}
+}
+
+func TestParseArgsEventCommands(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", "--on-extracted", "index.sh", "--on-finished", "log.sh", root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.OnExtracted != "index.sh" || opts.OnFinished != "log.sh" || opts.OnFailed != "" || opts.CommandTimeout != 10*time.Minute {
+		t.Fatalf("options=%+v, want commands with the default 10m timeout", opts)
+	}
+	if _, err := ParseArgs([]string{"unrarall", "--command-timeout=-1s", root}); err == nil {
+		t.Fatal("expected error for negative --command-timeout")
+	}
+}
+
+func TestParseArgsMetricsFile(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", "--metrics-file", filepath.Join("textfile", "unrarall.prom"), root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if !filepath.IsAbs(opts.MetricsFile) || filepath.Base(opts.MetricsFile) != "unrarall.prom" {
+		t.Fatalf("MetricsFile=%q, want an absolute path to unrarall.prom", opts.MetricsFile)
+	}
+	if _, err := ParseArgs([]string{"unrarall", "--metrics-addr", ":9101", root}); err == nil {
+		t.Fatal("expected --metrics-addr to be rejected outside watch mode")
+	}
+}
+
+func TestParseArgsNoLock(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", root})
+	if err != nil || opts.NoLock {
+		t.Fatalf("NoLock=%v err=%v, want locking on by default", opts.NoLock, err)
+	}
+	opts, err = ParseArgs([]string{"unrarall", "--no-lock", root})
+	if err != nil || !opts.NoLock {
+		t.Fatalf("NoLock=%v err=%v, want --no-lock to disable locking", opts.NoLock, err)
+	}
+}
+
+func TestParseArgsTimeLimitsAndRetries(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", root})
+	if err != nil || opts.ArchiveTimeout != 0 || opts.MaxRuntime != 0 || opts.MaxRuntimePolicy != RuntimeFinish || opts.IORetries != 0 || opts.IORetryDelay != time.Second {
+		t.Fatalf("options=%+v err=%v, want no limits, the finish policy, and no retries", opts, err)
+	}
+	opts, err = ParseArgs([]string{"unrarall", "--archive-timeout", "30m", "--max-runtime", "2h", "--max-runtime-policy", "abort", root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.ArchiveTimeout != 30*time.Minute || opts.MaxRuntime != 2*time.Hour || opts.MaxRuntimePolicy != RuntimeAbort {
+		t.Fatalf("options=%+v, want the parsed limits", opts)
+	}
+	opts, err = ParseArgs([]string{"unrarall", "--io-retries", "3", "--io-retry-delay", "5s", root})
+	if err != nil || opts.IORetries != 3 || opts.IORetryDelay != 5*time.Second {
+		t.Fatalf("options=%+v err=%v, want 3 retries 5s apart", opts, err)
+	}
+
+	for _, args := range [][]string{
+		{"--archive-timeout=-1s"},
+		{"--max-runtime=-1s"},
+		{"--max-runtime-policy", "wait"},
+		{"--io-retries=-1"},
+		{"--io-retry-delay=0s"},
+	} {
+		if _, err := ParseArgs(append(append([]string{"unrarall"}, args...), root)); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+}
+
+func TestParseArgsWebhook(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	opts, err := ParseArgs([]string{"unrarall", "--webhook", "https://hooks.example/unrarall", "--webhook-secret-env", "HOOK_KEY", root})
+	if err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if opts.Webhook != "https://hooks.example/unrarall" || opts.WebhookSecretEnv != "HOOK_KEY" || opts.WebhookTimeout != 10*time.Second || opts.WebhookRetries != 3 {
+		t.Fatalf("options=%+v, want the webhook with default timeout and retries", opts)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "scheme", args: []string{"--webhook", "ftp://hooks.example"}},
+		{name: "host", args: []string{"--webhook", "http:///path"}},
+		{name: "timeout", args: []string{"--webhook", "http://hooks.example", "--webhook-timeout", "0s"}},
+		{name: "retries", args: []string{"--webhook", "http://hooks.example", "--webhook-retries", "-1"}},
+		{name: "secret without webhook", args: []string{"--webhook-secret-env", "HOOK_KEY"}},
+	}
+	for _, tt := range tests {
+		args := append(append([]string{"unrarall"}, tt.args...), root)
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("%s: expected error for %q", tt.name, tt.args)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// PasswordsCommand is the subcommand managing encrypted password vaults.
+const PasswordsCommand = "passwords"
+
+// Vault actions accepted by the passwords subcommand.
+const (
+	VaultAdd    = "add"
+	VaultList   = "list"
+	VaultRemove = "remove"
+)
+
+// PasswordsOptions contains parsed passwords subcommand options.
+type PasswordsOptions struct {
+	Action       string
+	Vault        string
+	PassphraseFD int
+
+	ShowHelp bool
+}
+
+// IsPasswordsCommand reports whether args invoke the passwords subcommand.
+func IsPasswordsCommand(args []string) bool {
+	return len(args) > 1 && args[1] == PasswordsCommand
+}
+
+// ParsePasswordsArgs parses `<program> passwords ACTION [options]`.
+func ParsePasswordsArgs(args []string) (PasswordsOptions, error) {
+	opts := PasswordsOptions{
+		Vault:        defaultPasswordFile(),
+		PassphraseFD: -1,
+	}
+	if len(args) < 3 {
+		return PasswordsOptions{}, fmt.Errorf("expected an action: add, list, or remove")
+	}
+
+	action := args[2]
+	switch action {
+	case "-h", "--help", "help":
+		opts.ShowHelp = true
+		return opts, nil
+	case VaultAdd, VaultList, VaultRemove:
+		opts.Action = action
+	default:
+		return PasswordsOptions{}, fmt.Errorf("unknown passwords action %q", action)
+	}
+
+	fs := flag.NewFlagSet("unrarall passwords", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.Vault, "vault", opts.Vault, "")
+	fs.IntVar(&opts.PassphraseFD, "passphrase-fd", -1, "")
+	fs.BoolVar(&opts.ShowHelp, "help", false, "")
+	fs.BoolVar(&opts.ShowHelp, "h", false, "")
+
+	if err := fs.Parse(args[3:]); err != nil {
+		return PasswordsOptions{}, err
+	}
+	if opts.ShowHelp {
+		return opts, nil
+	}
+
+	if strings.TrimSpace(opts.Vault) == "" {
+		return PasswordsOptions{}, fmt.Errorf("--vault requires FILE")
+	}
+	var err error
+	opts.Vault, err = filepath.Abs(opts.Vault)
+	if err != nil {
+		return PasswordsOptions{}, fmt.Errorf("failed to resolve vault path: %w", err)
+	}
+
+	// Passwords on the command line would end up in shell history and the
+	// process list.
+	if fs.NArg() > 0 {
+		return PasswordsOptions{}, fmt.Errorf("unexpected argument %q: passwords are read from stdin or a prompt, not the command line", fs.Arg(0))
+	}
+	return opts, nil
+}
+
+// PasswordsUsage renders passwords subcommand usage text.
+func PasswordsUsage(program string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Usage: %s passwords add [options]\n", program)
+	fmt.Fprintf(&b, "       %s passwords list [options]\n", program)
+	fmt.Fprintf(&b, "       %s passwords remove [options]\n\n", program)
+
+	b.WriteString("Manage an encrypted password vault. A vault can be passed anywhere a\n")
+	b.WriteString("password file is accepted (--password-file). add and remove read one\n")
+	b.WriteString("password per line from stdin, or prompt once without echo when stdin is\n")
+	b.WriteString("a terminal.\n\n")
+
+	b.WriteString("Options:\n")
+	b.WriteString("  -h, --help               Show this help message and exit.\n")
+	b.WriteString("      --vault FILE         Vault path (default: ~/.unrar_passwords).\n")
+	b.WriteString("      --passphrase-fd N    Read the vault passphrase from file descriptor N.\n\n")
+
+	b.WriteString("Passphrase Sources (first available wins):\n")
+	b.WriteString("  1. UNRARALL_VAULT_PASSPHRASE environment variable.\n")
+	b.WriteString("  2. --passphrase-fd.\n")
+	b.WriteString("  3. A no-echo prompt when stdin is a terminal.\n")
+
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePasswordsArgs(t *testing.T) {
+	t.Parallel()
+
+	vault := filepath.Join(t.TempDir(), "vault")
+	opts, err := ParsePasswordsArgs([]string{"unrarall", "passwords", "add", "--vault", vault, "--passphrase-fd", "3"})
+	if err != nil {
+		t.Fatalf("ParsePasswordsArgs returned error: %v", err)
+	}
+	if opts.Action != VaultAdd || opts.Vault != vault || opts.PassphraseFD != 3 {
+		t.Fatalf("options=%+v", opts)
+	}
+}
+
+func TestParsePasswordsArgsRejectsInvalidInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "missing action", args: []string{"unrarall", "passwords"}},
+		{name: "unknown action", args: []string{"unrarall", "passwords", "rotate"}},
+		{name: "list with passwords", args: []string{"unrarall", "passwords", "list", "secret"}},
+		{name: "add with passwords", args: []string{"unrarall", "passwords", "add", "secret"}},
+		{name: "remove with passwords", args: []string{"unrarall", "passwords", "remove", "--vault", "/tmp/vault", "secret"}},
+		{name: "empty vault", args: []string{"unrarall", "passwords", "list", "--vault", " "}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParsePasswordsArgs(tc.args); err == nil {
+				t.Fatalf("expected error for %v", tc.args)
+			}
+		})
+	}
+}
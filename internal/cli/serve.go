@@ -0,0 +1,254 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ServeCommand is the subcommand that runs the HTTP control API.
+const ServeCommand = "serve"
+
+// ServeOptions contains parsed serve subcommand options.
+type ServeOptions struct {
+	// Listen is the API address.
+	Listen string
+	// TokenEnv names the environment variable holding the bearer token
+	// clients must send, or is empty to generate one at startup.
+	TokenEnv string
+	// MetricsAddr is the address Prometheus metrics are served on, or
+	// empty for none.
+	MetricsAddr string
+	// History is how many finished jobs are kept for listing.
+	History int
+	Quiet   bool
+	Verbose bool
+
+	ShowHelp bool
+}
+
+// IsServeCommand reports whether args invoke the serve subcommand.
+func IsServeCommand(args []string) bool {
+	return len(args) > 1 && args[1] == ServeCommand
+}
+
+// ParseServeArgs parses `<program> serve [options]`.
+func ParseServeArgs(args []string) (ServeOptions, error) {
+	opts := ServeOptions{}
+	fs := flag.NewFlagSet("unrarall serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.Listen, "listen", "127.0.0.1:7878", "")
+	fs.StringVar(&opts.TokenEnv, "token-env", "", "")
+	fs.StringVar(&opts.MetricsAddr, "metrics-addr", "", "")
+	fs.IntVar(&opts.History, "history", 100, "")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "")
+	fs.BoolVar(&opts.Verbose, "v", false, "")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "")
+	fs.BoolVar(&opts.Quiet, "q", false, "")
+	fs.BoolVar(&opts.ShowHelp, "help", false, "")
+	fs.BoolVar(&opts.ShowHelp, "h", false, "")
+
+	if err := fs.Parse(args[2:]); err != nil {
+		return ServeOptions{}, err
+	}
+	if opts.ShowHelp {
+		return opts, nil
+	}
+	if fs.NArg() != 0 {
+		return ServeOptions{}, errors.New("serve takes no arguments; submit directories as jobs")
+	}
+	if opts.Quiet {
+		opts.Verbose = false
+	}
+	if opts.History < 0 {
+		return ServeOptions{}, errors.New("--history must be >= 0")
+	}
+	host, _, err := net.SplitHostPort(opts.Listen)
+	if err != nil {
+		return ServeOptions{}, fmt.Errorf("invalid --listen %q (want host:port)", opts.Listen)
+	}
+	if opts.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(opts.MetricsAddr); err != nil {
+			return ServeOptions{}, fmt.Errorf("invalid --metrics-addr %q (want host:port or :port)", opts.MetricsAddr)
+		}
+	}
+	if strings.Contains(opts.TokenEnv, "=") {
+		return ServeOptions{}, errors.New("--token-env must name an environment variable")
+	}
+	if opts.TokenEnv == "" && !isLoopback(host) {
+		return ServeOptions{}, fmt.Errorf("--listen %q is reachable from other hosts; set --token-env or listen on a loopback address", opts.Listen)
+	}
+	return opts, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// JobOptions are the options of a job submitted to the serve API. They
+// mirror the one-shot options; those that run commands (--on-*,
+// --password-command) or read the terminal are not accepted over the API.
+type JobOptions struct {
+	Output           string   `json:"output,omitempty"`
+	Depth            *int     `json:"depth,omitempty"`
+	Skip             []string `json:"skip,omitempty"`
+	SkipIfExists     bool     `json:"skip_if_exists,omitempty"`
+	FullPath         bool     `json:"full_path,omitempty"`
+	AllowSymlinks    bool     `json:"allow_symlinks,omitempty"`
+	Force            bool     `json:"force,omitempty"`
+	DryRun           bool     `json:"dry_run,omitempty"`
+	Verbose          bool     `json:"verbose,omitempty"`
+	AllowFailures    bool     `json:"allow_failures,omitempty"`
+	DisableCKSFV     bool     `json:"disable_cksfv,omitempty"`
+	Rehash           bool     `json:"rehash,omitempty"`
+	SinglePass       bool     `json:"single_pass,omitempty"`
+	WriteManifest    string   `json:"write_manifest,omitempty"`
+	Clean            []string `json:"clean,omitempty"`
+	Journal          string   `json:"journal,omitempty"`
+	NoJournal        bool     `json:"no_journal,omitempty"`
+	Report           string   `json:"report,omitempty"`
+	PasswordFile     string   `json:"password_file,omitempty"`
+	PasswordMap      string   `json:"password_map,omitempty"`
+	PasswordStore    string   `json:"password_store,omitempty"`
+	NoPasswordStore  bool     `json:"no_password_store,omitempty"`
+	MaxDict          int64    `json:"max_dict,omitempty"`
+	Jobs             int      `json:"jobs,omitempty"`
+	JobsPerDevice    int      `json:"jobs_per_device,omitempty"`
+	Webhook          string   `json:"webhook,omitempty"`
+	WebhookSecretEnv string   `json:"webhook_secret_env,omitempty"`
+	NoLock           bool     `json:"no_lock,omitempty"`
+	// ArchiveTimeout, MaxRuntime, and IORetryDelay are Go durations such
+	// as "30m".
+	ArchiveTimeout   string `json:"archive_timeout,omitempty"`
+	MaxRuntime       string `json:"max_runtime,omitempty"`
+	MaxRuntimePolicy string `json:"max_runtime_policy,omitempty"`
+	IORetries        int    `json:"io_retries,omitempty"`
+	IORetryDelay     string `json:"io_retry_delay,omitempty"`
+}
+
+// args renders o as command-line arguments.
+func (o JobOptions) args() []string {
+	var args []string
+	str := func(name, value string) {
+		if value != "" {
+			args = append(args, "--"+name+"="+value)
+		}
+	}
+	boolean := func(name string, set bool) {
+		if set {
+			args = append(args, "--"+name)
+		}
+	}
+	str("output", o.Output)
+	if o.Depth != nil {
+		str("depth", strconv.Itoa(*o.Depth))
+	}
+	str("skip", strings.Join(o.Skip, ","))
+	boolean("skip-if-exists", o.SkipIfExists)
+	boolean("full-path", o.FullPath)
+	boolean("allow-symlinks", o.AllowSymlinks)
+	boolean("force", o.Force)
+	boolean("dry", o.DryRun)
+	boolean("verbose", o.Verbose)
+	boolean("allow-failures", o.AllowFailures)
+	boolean("disable-cksfv", o.DisableCKSFV)
+	boolean("rehash", o.Rehash)
+	boolean("single-pass", o.SinglePass)
+	str("write-manifest", o.WriteManifest)
+	str("clean", strings.Join(o.Clean, ","))
+	str("journal", o.Journal)
+	boolean("no-journal", o.NoJournal)
+	str("report", o.Report)
+	str("password-file", o.PasswordFile)
+	str("password-map", o.PasswordMap)
+	str("password-store", o.PasswordStore)
+	boolean("no-password-store", o.NoPasswordStore)
+	if o.MaxDict != 0 {
+		str("max-dict", strconv.FormatInt(o.MaxDict, 10))
+	}
+	if o.Jobs != 0 {
+		str("jobs", strconv.Itoa(o.Jobs))
+	}
+	if o.JobsPerDevice != 0 {
+		str("jobs-per-device", strconv.Itoa(o.JobsPerDevice))
+	}
+	str("webhook", o.Webhook)
+	str("webhook-secret-env", o.WebhookSecretEnv)
+	boolean("no-lock", o.NoLock)
+	str("archive-timeout", o.ArchiveTimeout)
+	str("max-runtime", o.MaxRuntime)
+	str("max-runtime-policy", o.MaxRuntimePolicy)
+	if o.IORetries != 0 {
+		str("io-retries", strconv.Itoa(o.IORetries))
+	}
+	str("io-retry-delay", o.IORetryDelay)
+	return args
+}
+
+// ParseJob validates a job for path, a directory or the first volume of an
+// archive set, the way the command line would. Relative paths are rejected:
+// the server's working directory means nothing to the client.
+func ParseJob(path string, o JobOptions) (Options, error) {
+	if !filepath.IsAbs(path) {
+		return Options{}, fmt.Errorf("path %q must be absolute", path)
+	}
+	for _, p := range []string{o.Output, o.Journal, o.Report, o.PasswordFile, o.PasswordMap, o.PasswordStore} {
+		if p != "" && !filepath.IsAbs(p) {
+			return Options{}, fmt.Errorf("path %q must be absolute", p)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Options{}, err
+	}
+	dir, archive := path, ""
+	if !info.IsDir() {
+		dir, archive = filepath.Dir(path), filepath.Clean(path)
+	}
+
+	opts, err := parseOptions("job", append(o.args(), "--", dir), nil)
+	if err != nil {
+		return Options{}, err
+	}
+	opts.Archive = archive
+	// Nobody is at the server's terminal to answer a prompt.
+	opts.NoPasswordPrompt = true
+	return opts, nil
+}
+
+// ServeUsage renders serve subcommand usage text.
+func ServeUsage(program string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Usage: %s serve [options]\n\n", program)
+
+	b.WriteString("Run a local HTTP API that queues extraction jobs and runs them one at a time.\n")
+	b.WriteString("Endpoints:\n")
+	b.WriteString("  GET  /healthz               Liveness check.\n")
+	b.WriteString("  POST /jobs                  Submit {\"path\": DIR_OR_ARCHIVE, \"options\": {...}}.\n")
+	b.WriteString("  GET  /jobs                  List queued, running, and finished jobs.\n")
+	b.WriteString("  GET  /jobs/{id}             Show a job with its per-set results.\n")
+	b.WriteString("  POST /jobs/{id}/cancel      Cancel a queued or running job.\n")
+	b.WriteString("  GET  /jobs/{id}/events      Stream job progress as Server-Sent Events.\n\n")
+
+	b.WriteString("Options:\n")
+	b.WriteString("  -h, --help               Show this help message and exit.\n")
+	b.WriteString("      --listen ADDR        API address (default: 127.0.0.1:7878).\n")
+	b.WriteString("      --token-env VAR      Bearer token clients must send, from VAR (required off loopback;\n")
+	b.WriteString("                           default: generated and written to the serve.token state file).\n")
+	b.WriteString("      --metrics-addr ADDR  Serve Prometheus metrics on http://ADDR/metrics.\n")
+	b.WriteString("      --history N          Finished jobs kept for listing (default: 100).\n")
+	b.WriteString("  -v, --verbose            Log job output, including verbose lines, to the console.\n")
+	b.WriteString("  -q, --quiet              Suppress console output.\n")
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseServeArgs(t *testing.T) {
+	t.Parallel()
+
+	opts, err := ParseServeArgs([]string{"unrarall", "serve"})
+	if err != nil {
+		t.Fatalf("ParseServeArgs returned error: %v", err)
+	}
+	if opts.Listen != "127.0.0.1:7878" || opts.History != 100 || opts.TokenEnv != "" {
+		t.Fatalf("options=%+v, want the loopback default and 100 jobs of history", opts)
+	}
+	if _, err := ParseServeArgs([]string{"unrarall", "serve", "--listen", ":7878", "--token-env", "UNRARALL_TOKEN"}); err != nil {
+		t.Fatalf("ParseServeArgs with a token returned error: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"--listen", "7878"},
+		{"--listen", ":7878"},
+		{"--listen", "[::]:7878"},
+		{"--history", "-1"},
+		{"--metrics-addr", "9101"},
+		{"--token-env", "A=B"},
+		{"/data"},
+	} {
+		if _, err := ParseServeArgs(append([]string{"unrarall", "serve"}, args...)); err == nil {
+			t.Fatalf("ParseServeArgs(%v) expected error", args)
+		}
+	}
+}
+
+func TestParseJob(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	output := t.TempDir()
+	archive := filepath.Join(root, "movie.part1.rar")
+	if err := os.WriteFile(archive, []byte("rar"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	depth := 0
+
+	opts, err := ParseJob(archive, JobOptions{Output: output, Depth: &depth, Skip: []string{"exists"}, Clean: []string{"rar", "nfo"}, Jobs: 2})
+	if err != nil {
+		t.Fatalf("ParseJob returned error: %v", err)
+	}
+	if opts.Dir != root || opts.Archive != archive || opts.OutputDir != output || opts.Depth != 0 || !opts.SkipIfExists || opts.Jobs != 2 {
+		t.Fatalf("options=%+v, want the archive in its directory with the job's options", opts)
+	}
+	if len(opts.CleanHooks) != 2 || !opts.NoPasswordPrompt || !opts.CKSFV {
+		t.Fatalf("options=%+v, want two hooks, no prompt, and checksums on", opts)
+	}
+
+	opts, err = ParseJob(root, JobOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ParseJob returned error: %v", err)
+	}
+	if opts.Dir != root || opts.Archive != "" || !opts.DryRun || opts.Plan != "text" {
+		t.Fatalf("options=%+v, want a dry run over the directory", opts)
+	}
+
+	for _, tt := range []struct {
+		path string
+		opts JobOptions
+	}{
+		{path: "relative"},
+		{path: root, opts: JobOptions{Output: "out"}},
+		{path: filepath.Join(root, "missing")},
+		{path: root, opts: JobOptions{Output: filepath.Join(root, "missing")}},
+		{path: root, opts: JobOptions{WriteManifest: "crc64"}},
+		{path: root, opts: JobOptions{Jobs: -1}},
+	} {
+		if _, err := ParseJob(tt.path, tt.opts); err == nil {
+			t.Fatalf("ParseJob(%q, %+v) expected error", tt.path, tt.opts)
+		}
+	}
+}
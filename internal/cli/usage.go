@@ -13,17 +13,26 @@ func Usage(program string) string {
 
 	fmt.Fprintf(&b, "Usage: %s [options] <DIRECTORY>\n", program)
 	fmt.Fprintf(&b, "       %s --help\n", program)
-	fmt.Fprintf(&b, "       %s --version\n\n", program)
+	fmt.Fprintf(&b, "       %s --version\n", program)
+	fmt.Fprintf(&b, "       %s passwords add|list|remove [options]\n", program)
+	fmt.Fprintf(&b, "       %s verify [options] <DIRECTORY>\n", program)
+	fmt.Fprintf(&b, "       %s watch [watch options] [options] <DIRECTORY>\n", program)
+	fmt.Fprintf(&b, "       %s serve [--listen ADDR] [serve options]\n\n", program)
 
 	b.WriteString("Options:\n")
 	b.WriteString("  -h, --help               Show this help message and exit.\n")
 	b.WriteString("      --version            Show version information and exit.\n")
 	b.WriteString("  -v, --verbose            Enable verbose logging (ignored when --quiet is set).\n")
 	b.WriteString("  -q, --quiet              Suppress command output.\n")
-	b.WriteString("  -d, --dry                Dry-run mode (log actions, no file writes).\n")
-	b.WriteString("  -f, --force              Continue when SFV/extraction checks fail; run clean hooks after failures.\n")
+	b.WriteString("  -d, --dry                Dry-run mode: print the extraction plan, no file writes.\n")
+	b.WriteString("      --plan=FORMAT        Plan format for --dry: text or json (JSON goes to stdout; implies --dry).\n")
+	b.WriteString("  -f, --force              Continue when checksum/extraction checks fail; run clean hooks after failures.\n")
 	b.WriteString("      --allow-failures     Return success when some extractions succeed.\n")
-	b.WriteString("  -s, --disable-cksfv      Disable SFV verification for <stem>.sfv.\n")
+	b.WriteString("  -s, --disable-cksfv      Disable checksum verification (.sha256/.sha1/.md5/.sfv manifests).\n")
+	b.WriteString("      --rehash             Hash every volume again instead of reusing cached checksums.\n")
+	b.WriteString("      --single-pass        Verify checksums while extracting (reads volumes once); failed sets are discarded.\n")
+	b.WriteString("      --write-manifest=FORMAT\n")
+	b.WriteString("                           Write <stem>.extracted.sfv|.sha256 for extracted files (sfv or sha256).\n")
 	b.WriteString("      --clean=SPEC         none|all|hook1,hook2 (default: none).\n")
 	b.WriteString("      --full-path          Preserve full archive paths while extracting.\n")
 	b.WriteString("      --allow-symlinks     Allow symlink entries with in-tree target validation.\n")
@@ -31,8 +40,43 @@ func Usage(program string) string {
 	b.WriteString("      --log-file FILE      Append command output to FILE while still writing to console.\n")
 	b.WriteString("      --depth N            Nested recursion depth budget (default: 4; top-level scan is unbounded).\n")
 	b.WriteString("      --skip-if-exists     Skip extraction when files already exist.\n")
+	b.WriteString("      --skip=MODES         journal,exists: skip sets the journal records as extracted\n")
+	b.WriteString("                           from unchanged volumes, or whose files already exist.\n")
+	b.WriteString("      --journal FILE       Record processed sets (default with --skip=journal:\n")
+	b.WriteString("                           state dir journal.jsonl).\n")
+	b.WriteString("      --no-journal         Do not read or write the journal.\n")
+	b.WriteString("      --report FILE        Write a JSON report with one record per archive set.\n")
 	b.WriteString("      --password-file FILE Password file path (default: ~/.unrar_passwords).\n")
+	b.WriteString("      --password-map FILE  Map path:, stem:, or group: glob patterns to passwords.\n")
+	b.WriteString("      --password-store FILE\n")
+	b.WriteString("                           Remember successful password fingerprints (default: state dir).\n")
+	b.WriteString("      --no-password-store  Do not read or update the password store.\n")
+	b.WriteString("      --passphrase-fd N    Read the password vault passphrase from file descriptor N.\n")
+	b.WriteString("      --password-env VAR   Read passwords (one per line) from environment variable VAR.\n")
+	b.WriteString("      --password-command CMD\n")
+	b.WriteString("                           Run CMD via the shell; each stdout line is a password.\n")
+	b.WriteString("      --no-password-prompt Never prompt for a password on a terminal.\n")
 	b.WriteString("      --max-dict BYTES     Max allowed RAR dictionary bytes (default: 1073741824).\n")
+	b.WriteString("  -j, --jobs N             Process up to N archive sets at once (default: 1).\n")
+	b.WriteString("      --jobs-per-device N  Limit concurrent sets per source device (default: --jobs on\n")
+	b.WriteString("                           solid-state devices, 1 otherwise).\n")
+	b.WriteString("      --on-extracted CMD   Run CMD via the shell after a set is extracted.\n")
+	b.WriteString("      --on-failed CMD      Run CMD via the shell after a set fails.\n")
+	b.WriteString("      --on-finished CMD    Run CMD via the shell after every set, whatever the outcome.\n")
+	b.WriteString("      --command-timeout D  Time limit for each --on-* command (default: 10m; 0 disables).\n")
+	b.WriteString("      --webhook URL        POST JSON events for extracted and failed sets and the run summary.\n")
+	b.WriteString("      --webhook-secret-env VAR\n")
+	b.WriteString("                           Sign webhook bodies with HMAC-SHA256 using the key in VAR.\n")
+	b.WriteString("      --webhook-timeout D  Time limit for each webhook attempt (default: 10s).\n")
+	b.WriteString("      --webhook-retries N  Retries after a failed webhook attempt (default: 3).\n")
+	b.WriteString("      --metrics-file FILE  Write Prometheus metrics in node_exporter textfile format after each run.\n")
+	b.WriteString("      --no-lock            Do not lock the tree or archive sets against other runs.\n")
+	b.WriteString("      --archive-timeout D  Roll back a set that takes longer than D (default: 0, no limit).\n")
+	b.WriteString("      --max-runtime D      Start no further sets D after the run starts (default: 0, no limit).\n")
+	b.WriteString("      --max-runtime-policy P\n")
+	b.WriteString("                           finish or abort sets still running at --max-runtime (default: finish).\n")
+	b.WriteString("      --io-retries N       Start a set over up to N times after EIO, ESTALE, or EAGAIN (default: 0).\n")
+	b.WriteString("      --io-retry-delay D   Wait before the first I/O retry, doubling after each (default: 1s).\n")
 	b.WriteString("\n")
 
 	b.WriteString("Clean Hooks:\n")
@@ -41,6 +85,24 @@ func Usage(program string) string {
 	}
 	b.WriteString("  all: Run all hooks in default order.\n")
 	b.WriteString("  none: Disable cleanup hooks.\n")
+	b.WriteString("\n")
+
+	b.WriteString("Event Commands (--on-extracted, --on-failed, --on-finished):\n")
+	b.WriteString("  Commands see UNRARALL_EVENT, UNRARALL_ARCHIVE, UNRARALL_ARCHIVE_DIR, UNRARALL_STEM,\n")
+	b.WriteString("  UNRARALL_DESTINATION, UNRARALL_FILES (one path per line), UNRARALL_STATUS,\n")
+	b.WriteString("  UNRARALL_ERROR_CLASS, and UNRARALL_ERROR. A failing command fails the set.\n")
+	b.WriteString("\n")
+
+	b.WriteString("Password Sources (tried in order for encrypted archives):\n")
+	b.WriteString("  1. Passwords embedded in the archive name: name{{password}}.rar\n")
+	b.WriteString("  2. password.txt next to the archive.\n")
+	b.WriteString("  3. --password-map rules matching the archive path, stem, or release group.\n")
+	b.WriteString("  4. --password-env variable.\n")
+	b.WriteString("  5. --password-command output. The command sees UNRARALL_ARCHIVE,\n")
+	b.WriteString("     UNRARALL_ARCHIVE_DIR, UNRARALL_STEM, and UNRARALL_GROUP.\n")
+	b.WriteString("  6. .unrar_passwords files in the archive directory and its parents.\n")
+	b.WriteString("  7. --password-file (plaintext or a vault managed with `passwords add`).\n")
+	b.WriteString("  8. An interactive no-echo prompt when stdin is a terminal.\n")
 
 	return b.String()
 }
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyCommand is the subcommand that rechecks extracted-output manifests.
+const VerifyCommand = "verify"
+
+// VerifyOptions contains parsed verify subcommand options.
+type VerifyOptions struct {
+	Dir     string
+	Verbose bool
+
+	ShowHelp bool
+}
+
+// IsVerifyCommand reports whether args invoke the verify subcommand.
+func IsVerifyCommand(args []string) bool {
+	return len(args) > 1 && args[1] == VerifyCommand
+}
+
+// ParseVerifyArgs parses `<program> verify [options] DIRECTORY`.
+func ParseVerifyArgs(args []string) (VerifyOptions, error) {
+	var opts VerifyOptions
+
+	fs := flag.NewFlagSet("unrarall verify", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.Verbose, "verbose", false, "")
+	fs.BoolVar(&opts.Verbose, "v", false, "")
+	fs.BoolVar(&opts.ShowHelp, "help", false, "")
+	fs.BoolVar(&opts.ShowHelp, "h", false, "")
+
+	if err := fs.Parse(args[2:]); err != nil {
+		return VerifyOptions{}, err
+	}
+	if opts.ShowHelp {
+		return opts, nil
+	}
+	if fs.NArg() != 1 {
+		return VerifyOptions{}, fmt.Errorf("expected exactly one DIRECTORY argument")
+	}
+
+	var err error
+	opts.Dir, err = filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return VerifyOptions{}, fmt.Errorf("failed to resolve directory path: %w", err)
+	}
+	return opts, nil
+}
+
+// VerifyUsage renders verify subcommand usage text.
+func VerifyUsage(program string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Usage: %s verify [options] <DIRECTORY>\n\n", program)
+
+	b.WriteString("Recheck every manifest written by --write-manifest (*.extracted.sfv,\n")
+	b.WriteString("*.extracted.sha256, ...) under DIRECTORY, recursively. Each file is read\n")
+	b.WriteString("again; cached checksums are not used. Exits non-zero when a file is\n")
+	b.WriteString("missing or changed, or when no manifest is found.\n\n")
+
+	b.WriteString("Options:\n")
+	b.WriteString("  -h, --help               Show this help message and exit.\n")
+	b.WriteString("  -v, --verbose            Also report manifests that verified cleanly.\n")
+
+	return b.String()
+}
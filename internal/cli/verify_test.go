@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVerifyArgs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	opts, err := ParseVerifyArgs([]string{"unrarall", "verify", "-v", dir})
+	if err != nil {
+		t.Fatalf("ParseVerifyArgs returned error: %v", err)
+	}
+	if opts.Dir != filepath.Clean(dir) || !opts.Verbose {
+		t.Fatalf("options=%+v", opts)
+	}
+
+	if _, err := ParseVerifyArgs([]string{"unrarall", "verify"}); err == nil {
+		t.Fatal("expected error without DIRECTORY")
+	}
+}
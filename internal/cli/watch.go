@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WatchCommand is the subcommand that keeps extracting sets as they arrive.
+const WatchCommand = "watch"
+
+// WatchOptions contains parsed watch subcommand options.
+type WatchOptions struct {
+	// Options are the one-shot run options applied to every pass.
+	Options Options
+	// Settle is how long a set's volumes must stay unchanged before it is
+	// processed.
+	Settle time.Duration
+	// Rescan is the interval between full rescans. Without change
+	// notifications it is the only way new sets are found.
+	Rescan time.Duration
+	// Config is an options file read before the command line, or empty.
+	Config string
+	// MetricsAddr is the address Prometheus metrics are served on, or
+	// empty for none.
+	MetricsAddr string
+
+	// Args are the original arguments, re-parsed by ReloadWatchOptions.
+	Args []string
+}
+
+// IsWatchCommand reports whether args invoke the watch subcommand.
+func IsWatchCommand(args []string) bool {
+	return len(args) > 1 && args[1] == WatchCommand
+}
+
+// ParseWatchArgs parses `<program> watch [options] DIRECTORY`. Every
+// one-shot option is accepted. Options from --config come first, so the
+// command line overrides them.
+func ParseWatchArgs(args []string) (WatchOptions, error) {
+	opts, err := parseWatchOptions(args[2:], "")
+	if err != nil || opts.Config == "" || opts.Options.ShowHelp {
+		opts.Args = args
+		return opts, err
+	}
+
+	configArgs, err := readOptionsFile(opts.Config)
+	if err != nil {
+		return WatchOptions{}, err
+	}
+	config := opts.Config
+	opts, err = parseWatchOptions(append(configArgs, args[2:]...), config)
+	if err != nil {
+		return WatchOptions{}, fmt.Errorf("%s: %w", config, err)
+	}
+	opts.Args = args
+	return opts, nil
+}
+
+// ReloadWatchOptions parses the arguments of opts again, re-reading the
+// --config file.
+func ReloadWatchOptions(opts WatchOptions) (WatchOptions, error) {
+	return ParseWatchArgs(opts.Args)
+}
+
+func parseWatchOptions(args []string, config string) (WatchOptions, error) {
+	watch := WatchOptions{Config: config}
+	var configFlag string
+	options, err := parseOptions("unrarall watch", args, func(fs *flag.FlagSet) {
+		fs.DurationVar(&watch.Settle, "settle", 30*time.Second, "")
+		fs.DurationVar(&watch.Rescan, "rescan", time.Minute, "")
+		fs.StringVar(&configFlag, "config", "", "")
+		fs.StringVar(&watch.MetricsAddr, "metrics-addr", "", "")
+	})
+	if err != nil {
+		return WatchOptions{}, err
+	}
+	if configFlag != "" {
+		if config != "" && !sameFile(configFlag, config) {
+			return WatchOptions{}, errors.New("--config cannot name another options file")
+		}
+		watch.Config, err = filepath.Abs(configFlag)
+		if err != nil {
+			return WatchOptions{}, fmt.Errorf("failed to resolve config path: %w", err)
+		}
+	}
+	if watch.Settle < 0 {
+		return WatchOptions{}, errors.New("--settle must be >= 0")
+	}
+	if watch.Rescan <= 0 {
+		return WatchOptions{}, errors.New("--rescan must be > 0")
+	}
+	if watch.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(watch.MetricsAddr); err != nil {
+			return WatchOptions{}, fmt.Errorf("invalid --metrics-addr %q (want host:port or :port)", watch.MetricsAddr)
+		}
+	}
+	if options.DryRun {
+		return WatchOptions{}, errors.New("--dry and --plan cannot be used with watch")
+	}
+	// Nobody is at the terminal to answer a prompt in a long-running watch.
+	options.NoPasswordPrompt = true
+	watch.Options = options
+	return watch, nil
+}
+
+// readOptionsFile reads one option per line. Blank lines and lines starting
+// with # are ignored. A line is either a single argument ("--force",
+// "--depth=2") or an option and its value separated by whitespace
+// ("--output /data/out").
+func readOptionsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	defer file.Close()
+
+	var args []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			return nil, fmt.Errorf("config %q: %q is not an option", path, line)
+		}
+		if i := strings.IndexAny(line, " \t"); i >= 0 && !strings.Contains(line[:i], "=") {
+			args = append(args, line[:i], strings.TrimSpace(line[i:]))
+			continue
+		}
+		args = append(args, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read config %q: %w", path, err)
+	}
+	return args, nil
+}
+
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// WatchUsage renders watch subcommand usage text.
+func WatchUsage(program string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Usage: %s watch [watch options] [options] <DIRECTORY>\n\n", program)
+
+	b.WriteString("Keep running and extract archive sets under DIRECTORY as they arrive.\n")
+	b.WriteString("Changes are noticed with inotify on Linux and by periodic rescans\n")
+	b.WriteString("everywhere. A set is processed once its volumes have stopped changing for\n")
+	b.WriteString("--settle and none of them is missing; it is processed again only if its\n")
+	b.WriteString("volumes change. All options of a one-shot run apply except --dry, and the\n")
+	b.WriteString("password prompt is disabled. SIGHUP re-reads --config and the options.\n")
+	b.WriteString("SIGINT or SIGTERM stops watching: the current pass starts no further sets\n")
+	b.WriteString("and rolls back the sets it has not yet placed.\n\n")
+
+	b.WriteString("Watch options:\n")
+	b.WriteString("      --settle DURATION    Wait until a set is unchanged this long (default: 30s).\n")
+	b.WriteString("      --rescan DURATION    Rescan the whole tree this often (default: 1m).\n")
+	b.WriteString("      --config FILE        Read options, one per line, before the command line.\n")
+	b.WriteString("      --metrics-addr ADDR  Serve Prometheus metrics on http://ADDR/metrics (e.g. :9101).\n\n")
+
+	fmt.Fprintf(&b, "See `%s --help` for the other options.\n", program)
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseWatchArgsReadsConfigBeforeCommandLine(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	output := t.TempDir()
+	config := filepath.Join(t.TempDir(), "watch.conf")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(config, []byte(content), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+	}
+	writeConfig("# nightly settings\n--output " + output + "\n--settle=1m\n\n--force\n")
+
+	args := []string{"unrarall", "watch", "--config", config, "--settle", "10s", "--metrics-addr", ":9101", dir}
+	opts, err := ParseWatchArgs(args)
+	if err != nil {
+		t.Fatalf("ParseWatchArgs returned error: %v", err)
+	}
+	if opts.Options.OutputDir != output || !opts.Options.Force {
+		t.Fatalf("options=%+v, want output and --force from the config", opts.Options)
+	}
+	if opts.Settle != 10*time.Second || opts.Rescan != time.Minute {
+		t.Fatalf("Settle=%v Rescan=%v, want the command line's 10s and the 1m default", opts.Settle, opts.Rescan)
+	}
+	if !opts.Options.NoPasswordPrompt {
+		t.Fatal("expected the password prompt to be disabled")
+	}
+	if opts.MetricsAddr != ":9101" {
+		t.Fatalf("MetricsAddr=%q, want :9101", opts.MetricsAddr)
+	}
+
+	writeConfig("--depth 1\n")
+	reloaded, err := ReloadWatchOptions(opts)
+	if err != nil {
+		t.Fatalf("ReloadWatchOptions returned error: %v", err)
+	}
+	if reloaded.Options.Depth != 1 || reloaded.Options.Force || reloaded.Options.OutputDir != "" {
+		t.Fatalf("reloaded options=%+v, want only --depth 1 from the new config", reloaded.Options)
+	}
+
+	writeConfig("output\n")
+	if _, err := ReloadWatchOptions(opts); err == nil {
+		t.Fatal("expected error for a config line that is not an option")
+	}
+}
+
+func TestParseWatchArgsValidation(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"--dry"},
+		{"--settle", "-1s"},
+		{"--rescan", "0s"},
+		{"--metrics-addr", "9101"},
+		{"--config", filepath.Join(dir, "missing.conf")},
+	} {
+		full := append(append([]string{"unrarall", "watch"}, args...), dir)
+		if _, err := ParseWatchArgs(full); err == nil {
+			t.Fatalf("ParseWatchArgs(%v) expected error", args)
+		}
+	}
+}
@@ -122,3 +122,30 @@ func candidateNames(candidates []Candidate) []string {
 	sort.Strings(out)
 	return out
 }
+
+func TestIsSetVolume(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		first string
+		name  string
+		want  bool
+	}{
+		{first: "title.part01.rar", name: "title.part01.rar", want: true},
+		{first: "title.part01.rar", name: "TITLE.PART02.RAR", want: true},
+		{first: "title.part01.rar", name: "title.r00", want: false},
+		{first: "title.part01.rar", name: "other.part02.rar", want: false},
+		{first: "title.rar", name: "title.r00", want: true},
+		{first: "title.rar", name: "title.s01", want: true},
+		{first: "title.rar", name: "title.nfo", want: false},
+		{first: "title.rar", name: "title.part02.rar", want: false},
+		{first: "title.001", name: "title.002", want: true},
+		{first: "title.001", name: "title.rar", want: false},
+		{first: "title.r00", name: "title.r01", want: false},
+	}
+	for _, tc := range tests {
+		if got := IsSetVolume(tc.first, tc.name); got != tc.want {
+			t.Fatalf("IsSetVolume(%q, %q)=%v, want %v", tc.first, tc.name, got, tc.want)
+		}
+	}
+}
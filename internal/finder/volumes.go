@@ -0,0 +1,34 @@
+package finder
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	partSetVolumeRe    = regexp.MustCompile(`(?i)^\.part[0-9]+\.rar$`)
+	numericSetVolumeRe = regexp.MustCompile(`^\.[0-9]{3,}$`)
+	oldStyleVolumeRe   = regexp.MustCompile(`(?i)^\.(rar|[r-z][0-9]{2})$`)
+)
+
+// IsSetVolume reports whether filename is a volume of the archive set whose
+// first volume is firstVolume. Both are base names; the comparison is
+// case-insensitive. The naming scheme is taken from the first volume:
+// name.partN.rar, name.NNN, or name.rar with name.r00, name.r01, ....
+func IsSetVolume(firstVolume, filename string) bool {
+	isFirst, stem := IsFirstVolume(firstVolume)
+	if !isFirst || len(filename) <= len(stem) || !strings.EqualFold(filename[:len(stem)], stem) {
+		return false
+	}
+	suffix := filename[len(stem):]
+
+	lowerFirst := strings.ToLower(firstVolume)
+	switch {
+	case strings.HasSuffix(lowerFirst, ".001"):
+		return numericSetVolumeRe.MatchString(suffix)
+	case partVolumeRe.MatchString(firstVolume):
+		return partSetVolumeRe.MatchString(suffix)
+	default:
+		return oldStyleVolumeRe.MatchString(suffix)
+	}
+}
@@ -0,0 +1,28 @@
+package fsutil
+
+import (
+	"context"
+	"io"
+)
+
+// ContextReader returns a reader that fails with ctx.Err() once ctx is done,
+// so long copies stop between reads. A nil ctx, or one that is never done,
+// returns r itself, keeping io.Copy fast paths such as copy_file_range.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	if ctx == nil || ctx.Done() == nil {
+		return r
+	}
+	return &contextReader{ctx: ctx, r: r}
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,15 @@
+package fsutil
+
+// Device identifies the storage device holding a path.
+type Device struct {
+	// ID is equal for paths on the same device.
+	ID string
+	// SolidState reports that the device is known not to be a spinning
+	// disk. It is false when the kind of device cannot be determined.
+	SolidState bool
+}
+
+// DeviceOf reports the device holding path.
+func DeviceOf(path string) (Device, error) {
+	return deviceOf(path)
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deviceOf identifies devices by volume name (drive letter or UNC share)
+// where there are no device numbers. The kind of device is not detected.
+func deviceOf(path string) (Device, error) {
+	if _, err := os.Stat(path); err != nil {
+		return Device{}, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Device{}, err
+	}
+	return Device{ID: strings.ToUpper(filepath.VolumeName(abs))}, nil
+}
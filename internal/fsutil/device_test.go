@@ -0,0 +1,32 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeviceOfMatchesWithinDirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.rar")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	dirDevice, err := DeviceOf(dir)
+	if err != nil {
+		t.Fatalf("DeviceOf(dir) returned error: %v", err)
+	}
+	fileDevice, err := DeviceOf(file)
+	if err != nil {
+		t.Fatalf("DeviceOf(file) returned error: %v", err)
+	}
+	if dirDevice != fileDevice {
+		t.Fatalf("DeviceOf(file)=%+v, want %+v", fileDevice, dirDevice)
+	}
+	if _, err := DeviceOf(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected error for missing path")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func deviceOf(path string) (Device, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Device{}, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return Device{}, fmt.Errorf("no device information for %q", path)
+	}
+	dev := uint64(stat.Dev)
+	return Device{ID: fmt.Sprintf("%d", dev), SolidState: solidState(dev)}, nil
+}
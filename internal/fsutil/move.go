@@ -1,12 +1,14 @@
 package fsutil
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"syscall"
 	"time"
 )
@@ -15,47 +17,129 @@ var renamePath = os.Rename
 
 const copyBufferSize = 256 * 1024
 
+// CopyVerificationError reports a cross-device copy that failed
+// verification. The copy has already been removed and the source is intact.
+type CopyVerificationError struct {
+	Src string
+	Dst string
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CopyVerificationError) Error() string {
+	return fmt.Sprintf("copy of %q to %q failed verification: %v", e.Src, e.Dst, e.Err)
+}
+
+func (e *CopyVerificationError) Unwrap() error {
+	return e.Err
+}
+
 // SafeMove moves src to dst. If dst already exists, a .N suffix is appended
 // until a free path is found. Cross-device moves fall back to copy+remove.
+//
+// Concurrent moves to the same dst never replace each other: each claims
+// its name by exclusively creating a placeholder there and then renames src
+// over the placeholder.
 func SafeMove(src, dst string) (string, error) {
+	return SafeMoveVerified(src, dst, nil)
+}
+
+// SafeMoveVerified is SafeMove with a check for the cross-device fallback:
+// verify is called with the copied path before src is removed. If it fails,
+// the copy is removed, src is left in place, and a *CopyVerificationError
+// is returned. verify is not called when a rename succeeds.
+func SafeMoveVerified(src, dst string, verify func(copied string) error) (string, error) {
+	return SafeMoveContext(context.Background(), src, dst, verify)
+}
+
+// SafeMoveContext is SafeMoveVerified that stops a cross-device copy once
+// ctx is done. The partial copy is removed, src is left in place, and
+// ctx.Err() is returned. A rename is atomic and is never interrupted.
+func SafeMoveContext(ctx context.Context, src, dst string, verify func(copied string) error) (string, error) {
 	if _, err := os.Stat(src); err != nil {
 		return "", err
 	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		return "", err
+	}
 
 	for attempt := 0; ; attempt++ {
-		candidate := dst
-		if attempt > 0 {
-			candidate = fmt.Sprintf("%s.%d", dst, attempt)
-		}
+		candidate := SuffixedPath(dst, attempt)
 
-		_, err := os.Stat(candidate)
+		err := reservePath(candidate, info.IsDir())
 		switch {
-		case err == nil:
+		case os.IsExist(err):
 			continue
-		case err != nil && !os.IsNotExist(err):
+		case err != nil:
 			return "", err
 		}
 
-		err = renamePath(src, candidate)
+		err = renameOverPlaceholder(src, candidate, info.IsDir())
 		switch {
 		case err == nil:
 			return candidate, nil
 		case isCrossDeviceError(err):
-			if err := copyPath(src, candidate); err != nil {
+			if err := copyPath(ctx, src, candidate); err != nil {
+				_ = os.RemoveAll(candidate)
 				return "", err
 			}
+			if verify != nil {
+				if err := verify(candidate); err != nil {
+					if ctxErr := ctx.Err(); ctxErr != nil {
+						return "", errors.Join(ctxErr, os.RemoveAll(candidate))
+					}
+					if removeErr := os.RemoveAll(candidate); removeErr != nil {
+						return "", errors.Join(&CopyVerificationError{Src: src, Dst: candidate, Err: err}, removeErr)
+					}
+					return "", &CopyVerificationError{Src: src, Dst: candidate, Err: err}
+				}
+			}
 			if err := os.RemoveAll(src); err != nil {
 				return "", err
 			}
 			return candidate, nil
-		case os.IsExist(err):
-			continue
 		default:
+			_ = os.Remove(candidate)
 			return "", err
 		}
 	}
 }
 
+// SuffixedPath returns the name SafeMove tries for dst on the given
+// attempt: dst itself first, then dst.1, dst.2, and so on.
+func SuffixedPath(dst string, attempt int) string {
+	if attempt == 0 {
+		return dst
+	}
+	return fmt.Sprintf("%s.%d", dst, attempt)
+}
+
+// reservePath claims path by creating an empty placeholder of the right
+// kind. It fails with an os.ErrExist error when path is taken.
+func reservePath(path string, dir bool) error {
+	if dir {
+		return os.Mkdir(path, 0o700)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// renameOverPlaceholder renames src onto its reserved placeholder. Windows
+// cannot rename a directory over an existing one, so the placeholder is
+// dropped first there.
+func renameOverPlaceholder(src, placeholder string, dir bool) error {
+	if dir && runtime.GOOS == "windows" {
+		if err := os.Remove(placeholder); err != nil {
+			return err
+		}
+	}
+	return renamePath(src, placeholder)
+}
+
 func isCrossDeviceError(err error) bool {
 	if errors.Is(err, syscall.EXDEV) {
 		return true
@@ -67,7 +151,9 @@ func isCrossDeviceError(err error) bool {
 	return false
 }
 
-func copyPath(src, dst string) error {
+// copyPath copies src onto dst, which is a placeholder reserved by
+// reservePath.
+func copyPath(ctx context.Context, src, dst string) error {
 	info, err := os.Lstat(src)
 	if err != nil {
 		return err
@@ -77,13 +163,17 @@ func copyPath(src, dst string) error {
 	}
 
 	if info.IsDir() {
-		return copyDir(src, dst, info)
+		return copyDir(ctx, src, dst, info)
 	}
-	return copyFile(src, dst, info)
+	return copyFile(ctx, src, dst, info)
 }
 
-func copyDir(src, dst string, rootInfo fs.FileInfo) error {
-	if err := os.Mkdir(dst, dirPerm(rootInfo.Mode())); err != nil {
+func copyDir(ctx context.Context, src, dst string, rootInfo fs.FileInfo) error {
+	if err := os.MkdirAll(dst, dirPerm(rootInfo.Mode())); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, dirPerm(rootInfo.Mode())); err != nil {
+		_ = os.RemoveAll(dst)
 		return err
 	}
 	if !rootInfo.ModTime().IsZero() {
@@ -125,7 +215,7 @@ func copyDir(src, dst string, rootInfo fs.FileInfo) error {
 		if !entryInfo.Mode().IsRegular() {
 			return fmt.Errorf("unsupported file type %q", path)
 		}
-		return copyFile(path, target, entryInfo)
+		return copyFile(ctx, path, target, entryInfo)
 	})
 	if err != nil {
 		_ = os.RemoveAll(dst)
@@ -134,7 +224,7 @@ func copyDir(src, dst string, rootInfo fs.FileInfo) error {
 	return nil
 }
 
-func copyFile(src, dst string, info fs.FileInfo) error {
+func copyFile(ctx context.Context, src, dst string, info fs.FileInfo) error {
 	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
 		return err
 	}
@@ -145,13 +235,19 @@ func copyFile(src, dst string, info fs.FileInfo) error {
 	}
 	defer in.Close()
 
-	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, filePerm(info.Mode()))
+	// dst is either new inside a copied directory or our own placeholder.
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, filePerm(info.Mode()))
 	if err != nil {
 		return err
 	}
+	if err := out.Chmod(filePerm(info.Mode())); err != nil {
+		out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
 
 	buf := make([]byte, copyBufferSize)
-	_, copyErr := io.CopyBuffer(out, in, buf)
+	_, copyErr := io.CopyBuffer(out, ContextReader(ctx, in), buf)
 	syncErr := out.Sync()
 	closeErr := out.Close()
 	if copyErr != nil {
@@ -181,6 +277,13 @@ func copySymlink(src, dst string) error {
 	if err != nil {
 		return err
 	}
+	// Replace a placeholder left by reservePath; a symlink cannot be
+	// created over an existing file.
+	if info, err := os.Lstat(dst); err == nil && info.Mode().IsRegular() && info.Size() == 0 {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
 	return os.Symlink(target, dst)
 }
 
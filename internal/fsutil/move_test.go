@@ -1,9 +1,14 @@
 package fsutil
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
 )
@@ -103,6 +108,32 @@ func TestSafeMoveFallsBackToCopyOnEXDEV(t *testing.T) {
 	}
 }
 
+func TestSafeMoveRemovesPlaceholderWhenCopyFails(t *testing.T) {
+	originalRename := renamePath
+	// The source disappears before the cross-device copy opens it.
+	renamePath = func(oldPath, newPath string) error {
+		_ = os.Remove(oldPath)
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() {
+		renamePath = originalRename
+	})
+
+	root := t.TempDir()
+	src := filepath.Join(root, "source.txt")
+	dst := filepath.Join(root, "dest.txt")
+	if err := os.WriteFile(src, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("write src: %v", err)
+	}
+
+	if _, err := SafeMove(src, dst); !os.IsNotExist(err) {
+		t.Fatalf("SafeMove error=%v, want the missing source", err)
+	}
+	if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+		t.Fatalf("placeholder left at destination: %v", err)
+	}
+}
+
 func TestSafeMoveDirectoryFallbackOnEXDEV(t *testing.T) {
 	originalRename := renamePath
 	renamePath = func(oldPath, newPath string) error {
@@ -254,3 +285,113 @@ func mustSymlinkOrSkip(t *testing.T, target, linkPath string) {
 		t.Skipf("skipping symlink test: %v", err)
 	}
 }
+
+func TestSafeMoveVerifiedRollsBackMismatchedCopy(t *testing.T) {
+	originalRename := renamePath
+	renamePath = func(oldPath, newPath string) error {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() {
+		renamePath = originalRename
+	})
+
+	root := t.TempDir()
+	src := filepath.Join(root, "source.txt")
+	dst := filepath.Join(root, "dest.txt")
+	if err := os.WriteFile(src, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("write src: %v", err)
+	}
+
+	var verified string
+	_, err := SafeMoveVerified(src, dst, func(copied string) error {
+		verified = copied
+		return errors.New("crc32 mismatch")
+	})
+	var copyErr *CopyVerificationError
+	if !errors.As(err, &copyErr) {
+		t.Fatalf("error=%v, want *CopyVerificationError", err)
+	}
+	if verified != dst || copyErr.Dst != dst {
+		t.Fatalf("verified %q (error dst %q), want %q", verified, copyErr.Dst, dst)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("mismatched copy was not removed: %v", err)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Fatalf("source must survive a failed verification: %v", err)
+	}
+}
+
+func TestSafeMoveContextStopsCanceledCopy(t *testing.T) {
+	originalRename := renamePath
+	renamePath = func(oldPath, newPath string) error {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() {
+		renamePath = originalRename
+	})
+
+	root := t.TempDir()
+	src := filepath.Join(root, "season")
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatalf("mkdir src: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "episode.mkv"), []byte("payload"), 0o644); err != nil {
+		t.Fatalf("write src: %v", err)
+	}
+	dst := filepath.Join(root, "out")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SafeMoveContext(ctx, src, dst, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error=%v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("partial copy was not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "episode.mkv")); err != nil {
+		t.Fatalf("source must survive a canceled copy: %v", err)
+	}
+}
+
+func TestSafeMoveConcurrentMovesKeepEveryFile(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	dst := filepath.Join(root, "dest.txt")
+	const movers = 16
+
+	var wg sync.WaitGroup
+	finals := make([]string, movers)
+	errs := make([]error, movers)
+	for i := range movers {
+		src := filepath.Join(root, fmt.Sprintf("source-%d.txt", i))
+		if err := os.WriteFile(src, []byte(strconv.Itoa(i)), 0o644); err != nil {
+			t.Fatalf("write src: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			finals[i], errs[i] = SafeMove(src, dst)
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, movers)
+	for i := range movers {
+		if errs[i] != nil {
+			t.Fatalf("SafeMove %d returned error: %v", i, errs[i])
+		}
+		if seen[finals[i]] {
+			t.Fatalf("two moves landed on %q", finals[i])
+		}
+		seen[finals[i]] = true
+		content, err := os.ReadFile(finals[i])
+		if err != nil {
+			t.Fatalf("read %q: %v", finals[i], err)
+		}
+		if string(content) != strconv.Itoa(i) {
+			t.Fatalf("%q content=%q, want %q", finals[i], content, strconv.Itoa(i))
+		}
+	}
+}
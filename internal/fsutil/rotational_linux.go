@@ -0,0 +1,29 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var sysBlockDir = "/sys/dev/block"
+
+// solidState reads the block queue's rotational flag from sysfs. Partitions
+// have no queue of their own, so the parent disk's flag is used for them.
+// Devices without a sysfs entry (tmpfs, overlay, NFS) count as rotational.
+func solidState(dev uint64) bool {
+	major := ((dev >> 8) & 0xfff) | ((dev >> 32) &^ 0xfff)
+	minor := (dev & 0xff) | ((dev >> 12) &^ 0xff)
+	block, err := filepath.EvalSymlinks(filepath.Join(sysBlockDir, fmt.Sprintf("%d:%d", major, minor)))
+	if err != nil {
+		return false
+	}
+	for _, dir := range []string{block, filepath.Dir(block)} {
+		data, err := os.ReadFile(filepath.Join(dir, "queue", "rotational"))
+		if err == nil {
+			return strings.TrimSpace(string(data)) == "0"
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSolidStateReadsSysfsQueue(t *testing.T) {
+	root := t.TempDir()
+	disks := filepath.Join(root, "devices")
+	writeRotational := func(disk, value string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(disks, disk, "queue"), 0o755); err != nil {
+			t.Fatalf("mkdir queue: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(disks, disk, "queue", "rotational"), []byte(value+"\n"), 0o644); err != nil {
+			t.Fatalf("write rotational: %v", err)
+		}
+	}
+	writeRotational("nvme0n1", "0")
+	writeRotational("sda", "1")
+	if err := os.MkdirAll(filepath.Join(disks, "nvme0n1", "nvme0n1p1"), 0o755); err != nil {
+		t.Fatalf("mkdir partition: %v", err)
+	}
+
+	block := filepath.Join(root, "block")
+	if err := os.Mkdir(block, 0o755); err != nil {
+		t.Fatalf("mkdir block: %v", err)
+	}
+	links := map[string]string{
+		"259:1": "../devices/nvme0n1/nvme0n1p1",
+		"8:0":   "../devices/sda",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(block, name)); err != nil {
+			t.Fatalf("symlink %s: %v", name, err)
+		}
+	}
+
+	old := sysBlockDir
+	sysBlockDir = block
+	t.Cleanup(func() { sysBlockDir = old })
+
+	tests := []struct {
+		name string
+		dev  uint64
+		want bool
+	}{
+		{name: "partition of solid-state disk", dev: 259<<8 | 1, want: true},
+		{name: "rotational disk", dev: 8 << 8, want: false},
+		{name: "no sysfs entry", dev: 0<<8 | 42, want: false},
+	}
+	for _, tt := range tests {
+		if got := solidState(tt.dev); got != tt.want {
+			t.Fatalf("%s: solidState=%v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build unix && !linux
+
+package fsutil
+
+// solidState is not detected outside Linux; every device counts as
+// rotational.
+func solidState(uint64) bool {
+	return false
+}
@@ -0,0 +1,74 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const stateDirName = "unrarall"
+
+// StateDir returns the per-user directory for persistent unrarall state:
+// $XDG_STATE_HOME/unrarall, falling back to ~/.local/state/unrarall. An empty
+// string is returned when neither location can be determined.
+func StateDir() string {
+	if base := strings.TrimSpace(os.Getenv("XDG_STATE_HOME")); base != "" && filepath.IsAbs(base) {
+		return filepath.Join(base, stateDirName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", stateDirName)
+}
+
+// StatePath returns name inside StateDir, or an empty string when there is
+// no usable state directory.
+func StatePath(name string) string {
+	dir := StateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// WriteFileAtomic writes data to a temp file next to path and renames it into
+// place, so readers never observe a partially written file. Missing parent
+// directories are created with 0700 permissions.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, writeErr := tmp.Write(data)
+	syncErr := tmp.Sync()
+	closeErr := tmp.Close()
+	if writeErr != nil || syncErr != nil || closeErr != nil {
+		_ = os.Remove(tmpPath)
+		if writeErr != nil {
+			return writeErr
+		}
+		if syncErr != nil {
+			return syncErr
+		}
+		return closeErr
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("replace %q: %w", path, err)
+	}
+	return nil
+}
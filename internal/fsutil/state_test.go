@@ -0,0 +1,65 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateDirPrefersXDGStateHome(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", base)
+
+	if got, want := StateDir(), filepath.Join(base, "unrarall"); got != want {
+		t.Fatalf("StateDir()=%q, want %q", got, want)
+	}
+	if got, want := StatePath("cache.json"), filepath.Join(base, "unrarall", "cache.json"); got != want {
+		t.Fatalf("StatePath()=%q, want %q", got, want)
+	}
+}
+
+func TestStateDirIgnoresRelativeXDGStateHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", "relative/state")
+	t.Setenv("HOME", home)
+
+	if got, want := StateDir(), filepath.Join(home, ".local", "state", "unrarall"); got != want {
+		t.Fatalf("StateDir()=%q, want %q", got, want)
+	}
+}
+
+func TestWriteFileAtomicReplacesContent(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	if err := WriteFileAtomic(path, []byte("first"), 0o600); err != nil {
+		t.Fatalf("WriteFileAtomic returned error: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("second"), 0o600); err != nil {
+		t.Fatalf("WriteFileAtomic overwrite returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if string(data) != "second" {
+		t.Fatalf("content=%q, want %q", string(data), "second")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("permissions=%o, want 600", perm)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the target file to remain, got %d entries", len(entries))
+	}
+}
@@ -3,13 +3,42 @@ package fsutil
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
+// TempDirPrefix starts the name of every extraction temp directory.
+const TempDirPrefix = ".unrarall-"
+
 // CreateTempDir creates an extraction temp directory under parent.
 func CreateTempDir(parent string) (string, error) {
 	if strings.TrimSpace(parent) == "" {
 		return "", fmt.Errorf("temp parent directory is required")
 	}
-	return os.MkdirTemp(parent, ".unrarall-")
+	return os.MkdirTemp(parent, TempDirPrefix)
+}
+
+// IsTempDir reports whether name is an extraction temp directory name. Such
+// directories belong to an extraction in progress.
+func IsTempDir(name string) bool {
+	return strings.HasPrefix(name, TempDirPrefix)
+}
+
+// ResetDir removes everything inside dir while keeping dir itself, so a
+// failed extraction attempt cannot leak partial files into the next one.
+func ResetDir(dir string) error {
+	if strings.TrimSpace(dir) == "" {
+		return fmt.Errorf("directory to reset is required")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -31,6 +31,9 @@ func TestCreateTempDir(t *testing.T) {
 	if strings.HasPrefix(rel, "..") {
 		t.Fatalf("temp dir %q not created under parent %q", dir, parent)
 	}
+	if !IsTempDir(filepath.Base(dir)) {
+		t.Fatalf("IsTempDir(%q)=false, want true", filepath.Base(dir))
+	}
 }
 
 func TestCreateTempDirRequiresParent(t *testing.T) {
@@ -40,3 +43,30 @@ func TestCreateTempDirRequiresParent(t *testing.T) {
 		t.Fatal("expected error when parent is empty")
 	}
 }
+
+func TestResetDirRemovesContentsOnly(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "nested", "deeper"), 0o755); err != nil {
+		t.Fatalf("mkdir nested: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nested", "deeper", "partial.bin"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write nested file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "root.bin"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write root file: %v", err)
+	}
+
+	if err := ResetDir(dir); err != nil {
+		t.Fatalf("ResetDir returned error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read reset dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected empty dir after reset, got %d entries", len(entries))
+	}
+}
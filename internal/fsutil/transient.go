@@ -0,0 +1,13 @@
+package fsutil
+
+import (
+	"errors"
+	"syscall"
+)
+
+// IsTransient reports whether err is a filesystem error that may not recur
+// on a second try: EIO, ESTALE, or EAGAIN, as NFS and SMB mounts return
+// while a server fails over or a handle is revalidated.
+func IsTransient(err error) bool {
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EAGAIN)
+}
@@ -0,0 +1,31 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "eio", err: &fs.PathError{Op: "read", Path: "/mnt/a.rar", Err: syscall.EIO}, want: true},
+		{name: "estale", err: fmt.Errorf("verify %q: %w", "a.rar", &fs.PathError{Op: "open", Path: "/mnt/a.rar", Err: syscall.ESTALE}), want: true},
+		{name: "eagain", err: &fs.PathError{Op: "write", Path: "/tmp/x", Err: syscall.EAGAIN}, want: true},
+		{name: "missing", err: &fs.PathError{Op: "open", Path: "/mnt/a.rar", Err: syscall.ENOENT}},
+		{name: "other", err: errors.New("corrupt header")},
+		{name: "nil"},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Fatalf("%s: IsTransient=%v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
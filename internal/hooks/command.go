@@ -0,0 +1,137 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Environment variables describing an event to --on-* commands.
+const (
+	EnvEvent       = "UNRARALL_EVENT"
+	EnvArchive     = "UNRARALL_ARCHIVE"
+	EnvArchiveDir  = "UNRARALL_ARCHIVE_DIR"
+	EnvStem        = "UNRARALL_STEM"
+	EnvDestination = "UNRARALL_DESTINATION"
+	EnvFiles       = "UNRARALL_FILES"
+	EnvFilesFile   = "UNRARALL_FILES_FILE"
+	EnvStatus      = "UNRARALL_STATUS"
+	EnvErrorClass  = "UNRARALL_ERROR_CLASS"
+	EnvError       = "UNRARALL_ERROR"
+)
+
+// maxEnvFiles is the longest file list passed in UNRARALL_FILES. Linux
+// refuses to start a command with a single variable over 128 KiB, so
+// longer lists are written to a file named by UNRARALL_FILES_FILE instead.
+const maxEnvFiles = 64 << 10
+
+// Event describes a candidate outcome to an EventCommand.
+type Event struct {
+	// Name is "extracted", "failed", or "finished".
+	Name        string
+	Archive     string
+	Stem        string
+	Destination string
+	// Files are the final paths of the files placed in Destination.
+	Files []string
+	// Status is the candidate outcome: "extracted", "skipped", or "failed".
+	Status     string
+	ErrorClass string
+	Error      string
+}
+
+// EventCommand runs Command through the platform shell with the event in
+// the UNRARALL_* environment variables.
+type EventCommand struct {
+	Command string
+	// Timeout bounds the command; zero means no limit.
+	Timeout time.Duration
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+// Run runs the command for event. A non-zero exit status or a timeout is
+// returned as an error; once ctx is done, the command is killed and
+// ctx.Err() is returned.
+func (c EventCommand) Run(ctx context.Context, event Event) error {
+	parent := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	env, cleanup, err := event.environ()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd := shellCommand(ctx, c.Command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = nil
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	// Background children may hold the output open after a timeout.
+	cmd.WaitDelay = 5 * time.Second
+
+	if err := cmd.Run(); err != nil {
+		if parent.Err() != nil {
+			return parent.Err()
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s", c.Timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("exited with status %d", exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+// environ returns the UNRARALL_* variables for e. The cleanup function
+// removes the file list written for long lists.
+func (e Event) environ() ([]string, func(), error) {
+	env := []string{
+		EnvEvent + "=" + e.Name,
+		EnvArchive + "=" + e.Archive,
+		EnvArchiveDir + "=" + filepath.Dir(e.Archive),
+		EnvStem + "=" + e.Stem,
+		EnvDestination + "=" + e.Destination,
+		EnvStatus + "=" + e.Status,
+		EnvErrorClass + "=" + e.ErrorClass,
+		EnvError + "=" + e.Error,
+	}
+	files := strings.Join(e.Files, "\n")
+	if len(files) <= maxEnvFiles {
+		return append(env, EnvFiles+"="+files), func() {}, nil
+	}
+
+	list, err := os.CreateTemp("", "unrarall-files-*.txt")
+	if err != nil {
+		return nil, nil, fmt.Errorf("write file list: %w", err)
+	}
+	cleanup := func() { _ = os.Remove(list.Name()) }
+	_, writeErr := list.WriteString(files + "\n")
+	if err := errors.Join(writeErr, list.Close()); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("write file list: %w", err)
+	}
+	return append(env, EnvFilesFile+"="+list.Name()), cleanup, nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
@@ -0,0 +1,94 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventCommandDescribesEventInEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	var stdout bytes.Buffer
+	cmd := EventCommand{
+		Command: `printf '%s|%s|%s|%s|%s|%s\n' "$UNRARALL_EVENT" "$UNRARALL_ARCHIVE_DIR" "$UNRARALL_STEM" "$UNRARALL_DESTINATION" "$UNRARALL_STATUS" "$UNRARALL_FILES"`,
+		Stdout:  &stdout,
+	}
+	err := cmd.Run(context.Background(), Event{
+		Name:        "extracted",
+		Archive:     "/downloads/show/show.rar",
+		Stem:        "show",
+		Destination: "/media",
+		Files:       []string{"/media/a.mkv", "/media/a.nfo"},
+		Status:      "extracted",
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := "extracted|/downloads/show|show|/media|extracted|/media/a.mkv\n/media/a.nfo\n"
+	if stdout.String() != want {
+		t.Fatalf("output=%q, want %q", stdout.String(), want)
+	}
+}
+
+func TestEventCommandWritesLongFileListsToFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	files := make([]string, 0, 4096)
+	for range cap(files) {
+		files = append(files, "/media/"+strings.Repeat("x", 32))
+	}
+	var stdout bytes.Buffer
+	cmd := EventCommand{Command: `echo "${UNRARALL_FILES:-unset} $UNRARALL_FILES_FILE"; wc -l < "$UNRARALL_FILES_FILE"`, Stdout: &stdout}
+	if err := cmd.Run(context.Background(), Event{Name: "finished", Archive: "/d/a.rar", Files: files}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	fields := strings.Fields(stdout.String())
+	if len(fields) != 3 || fields[0] != "unset" || fields[2] != "4096" {
+		t.Fatalf("output=%q, want unset UNRARALL_FILES and a 4096-line list", stdout.String())
+	}
+	if _, err := os.Stat(fields[1]); !os.IsNotExist(err) {
+		t.Fatalf("file list %q was not removed: %v", fields[1], err)
+	}
+}
+
+func TestEventCommandReportsExitStatusAndTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	err := EventCommand{Command: "exit 4"}.Run(context.Background(), Event{Archive: "/d/a.rar"})
+	if err == nil || !strings.Contains(err.Error(), "status 4") {
+		t.Fatalf("error=%v, want exit status 4", err)
+	}
+	err = EventCommand{Command: "sleep 5", Timeout: 50 * time.Millisecond}.Run(context.Background(), Event{Archive: "/d/a.rar"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("error=%v, want a timeout", err)
+	}
+}
+
+func TestEventCommandStopsWhenCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err := EventCommand{Command: "exec sleep 30", Timeout: time.Hour}.Run(ctx, Event{Archive: "/d/a.rar"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error=%v, want context.Canceled", err)
+	}
+	if waited := time.Since(start); waited > 10*time.Second {
+		t.Fatalf("Run waited %s after cancellation", waited)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/arodd/go-unrarall/internal/fsutil"
 	"github.com/arodd/go-unrarall/internal/log"
 )
 
@@ -21,6 +22,29 @@ type Context struct {
 	Stem        string
 	DryRun      bool
 	Log         *log.Logger
+	// OnAction, when set, is called for every removal a hook performs, or
+	// would perform in dry-run mode.
+	OnAction func(Action)
+	// Planned lists files a dry run would extract. Hooks treat them as
+	// present, so their actions match a real run.
+	Planned []string
+
+	hook string
+}
+
+// Action describes one removal made by a cleanup hook.
+type Action struct {
+	Hook string `json:"hook"`
+	// Kind is "remove_file", "remove_tree", or "remove_empty_dir".
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+func (ctx Context) record(kind, path string) {
+	if ctx.OnAction != nil {
+		ctx.OnAction(Action{Hook: ctx.hook, Kind: kind, Path: path, DryRun: ctx.DryRun})
+	}
 }
 
 // Run executes cleanup hooks in deterministic order based on selection.
@@ -40,6 +64,7 @@ func Run(selection []string, ctx Context) error {
 		if ctx.Log != nil {
 			ctx.Log.Verbosef("Running clean hook %q", name)
 		}
+		ctx.hook = name
 		if err := def.Run(ctx); err != nil {
 			errs = append(errs, fmt.Errorf("%s: %w", name, err))
 		}
@@ -53,7 +78,7 @@ func runNFO(ctx Context) error {
 }
 
 func runRAR(ctx Context) error {
-	entries, err := os.ReadDir(ctx.RarDir)
+	entries, err := ctx.readDir(ctx.RarDir)
 	if err != nil {
 		return err
 	}
@@ -97,7 +122,7 @@ func runSampleFolders(ctx Context) error {
 }
 
 func runSampleVideos(ctx Context) error {
-	entries, err := os.ReadDir(ctx.ExtractRoot)
+	entries, err := ctx.readDir(ctx.ExtractRoot)
 	if err != nil {
 		return err
 	}
@@ -126,23 +151,28 @@ func runEmptyFolders(ctx Context) error {
 func removeNamedDirectories(root, targetName string, ctx Context) error {
 	matches := make([]string, 0, 8)
 
-	err := filepath.WalkDir(root, func(path string, d os.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-		if !d.IsDir() {
-			return nil
-		}
-		if path == root {
-			return nil
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := ctx.readDir(dir)
+		if err != nil {
+			return err
 		}
-		if !strings.EqualFold(d.Name(), targetName) {
-			return nil
+		for _, entry := range entries {
+			// Another extraction may be writing to a temp directory.
+			if !entry.IsDir() || fsutil.IsTempDir(entry.Name()) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if strings.EqualFold(entry.Name(), targetName) {
+				matches = append(matches, path)
+			}
+			if err := walk(path); err != nil {
+				return err
+			}
 		}
-		matches = append(matches, path)
 		return nil
-	})
-	if err != nil {
+	}
+	if err := walk(root); err != nil {
 		return err
 	}
 
@@ -158,17 +188,12 @@ func removeNamedDirectories(root, targetName string, ctx Context) error {
 }
 
 func removeFile(path string, ctx Context) error {
-	info, err := os.Lstat(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
+	isFile, err := ctx.isFile(path)
+	if err != nil || !isFile {
 		return err
 	}
-	if info.IsDir() {
-		return nil
-	}
 
+	ctx.record("remove_file", path)
 	if ctx.DryRun {
 		if ctx.Log != nil {
 			ctx.Log.Verbosef("Dry-run: remove file %q", path)
@@ -179,6 +204,7 @@ func removeFile(path string, ctx Context) error {
 }
 
 func removeDirectoryTree(path string, ctx Context) error {
+	ctx.record("remove_tree", path)
 	if ctx.DryRun {
 		if ctx.Log != nil {
 			ctx.Log.Verbosef("Dry-run: remove directory tree %q", path)
@@ -189,7 +215,7 @@ func removeDirectoryTree(path string, ctx Context) error {
 }
 
 func pruneEmptyDirectories(path string, isRoot bool, ctx Context) (bool, error) {
-	entries, err := os.ReadDir(path)
+	entries, err := ctx.readDir(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return true, nil
@@ -203,6 +229,11 @@ func pruneEmptyDirectories(path string, isRoot bool, ctx Context) (bool, error)
 			empty = false
 			continue
 		}
+		if fsutil.IsTempDir(entry.Name()) {
+			// Another extraction may still be filling it.
+			empty = false
+			continue
+		}
 
 		childPath := filepath.Join(path, entry.Name())
 		childEmpty, err := pruneEmptyDirectories(childPath, false, ctx)
@@ -219,6 +250,7 @@ func pruneEmptyDirectories(path string, isRoot bool, ctx Context) (bool, error)
 	}
 
 	if ctx.DryRun {
+		ctx.record("remove_empty_dir", path)
 		if ctx.Log != nil {
 			ctx.Log.Verbosef("Dry-run: remove empty directory %q", path)
 		}
@@ -231,5 +263,6 @@ func pruneEmptyDirectories(path string, isRoot bool, ctx Context) (bool, error)
 		}
 		return false, err
 	}
+	ctx.record("remove_empty_dir", path)
 	return true, nil
 }
@@ -3,6 +3,7 @@ package hooks
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -30,14 +31,24 @@ func TestRunRARRemovesMatchingVolumes(t *testing.T) {
 	create("release.txt")
 	create("other.rar")
 
+	var actions []Action
 	err := Run([]string{"rar"}, Context{
 		ExtractRoot: root,
 		RarDir:      rarDir,
 		Stem:        "release",
+		OnAction:    func(action Action) { actions = append(actions, action) },
 	})
 	if err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
+	if len(actions) != 5 {
+		t.Fatalf("recorded %d action(s), want 5: %+v", len(actions), actions)
+	}
+	for _, action := range actions {
+		if action.Hook != "rar" || action.Kind != "remove_file" || filepath.Dir(action.Path) != rarDir {
+			t.Fatalf("action=%+v, want a rar remove_file in %q", action, rarDir)
+		}
+	}
 
 	for _, name := range []string{
 		"release.sfv",
@@ -148,6 +159,10 @@ func TestRunEmptyFoldersRemovesNestedEmptyDirs(t *testing.T) {
 	if err := os.WriteFile(filepath.Join(rarDir, "has-files", "keep.txt"), []byte("x"), 0o644); err != nil {
 		t.Fatalf("write keep file: %v", err)
 	}
+	// A concurrent extraction's temp dir may still be empty.
+	if err := os.MkdirAll(filepath.Join(rarDir, ".unrarall-123", "sub"), 0o755); err != nil {
+		t.Fatalf("mkdir temp dir: %v", err)
+	}
 
 	err := Run([]string{"empty_folders"}, Context{
 		ExtractRoot: root,
@@ -164,4 +179,42 @@ func TestRunEmptyFoldersRemovesNestedEmptyDirs(t *testing.T) {
 	if _, err := os.Stat(filepath.Join(rarDir, "has-files")); err != nil {
 		t.Fatalf("expected non-empty dir to remain, stat err=%v", err)
 	}
+	if _, err := os.Stat(filepath.Join(rarDir, ".unrarall-123", "sub")); err != nil {
+		t.Fatalf("expected in-progress temp dir to remain, stat err=%v", err)
+	}
+}
+
+func TestRunDryRunActsOnPlannedFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	planned := []string{
+		filepath.Join(root, "release.nfo"),
+		filepath.Join(root, "release.mkv"),
+		filepath.Join(root, "Sample", "sample-release.mkv"),
+		filepath.Join(root, "empty", "keep.txt"),
+	}
+
+	var actions []Action
+	err := Run([]string{"nfo", "sample_folders", "empty_folders"}, Context{
+		ExtractRoot: root,
+		RarDir:      root,
+		Stem:        "release",
+		DryRun:      true,
+		Planned:     planned,
+		OnAction:    func(action Action) { actions = append(actions, action) },
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := []Action{
+		{Hook: "nfo", Kind: "remove_file", Path: planned[0], DryRun: true},
+		{Hook: "sample_folders", Kind: "remove_tree", Path: filepath.Join(root, "Sample"), DryRun: true},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("actions=%+v, want %+v", actions, want)
+	}
 }
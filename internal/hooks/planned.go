@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The helpers below read the tree the way hooks see it: the disk plus
+// ctx.Planned, so a dry-run plan can show what hooks would delete once
+// extraction has placed its files.
+
+// isFile reports whether path is a file on disk or a planned file.
+func (ctx Context) isFile(path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if err == nil {
+		return !info.IsDir(), nil
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+	for _, planned := range ctx.Planned {
+		if planned == path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readDir lists dir on disk, adding planned files and the directories
+// that would hold them.
+func (ctx Context) readDir(dir string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if len(ctx.Planned) == 0 || (err != nil && !errors.Is(err, fs.ErrNotExist)) {
+		return entries, err
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		seen[entry.Name()] = true
+	}
+	added := false
+	for _, planned := range ctx.Planned {
+		rel, relErr := filepath.Rel(dir, planned)
+		if relErr != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		name, _, isDir := strings.Cut(rel, string(filepath.Separator))
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		entries = append(entries, plannedEntry{name: name, dir: isDir})
+		added = true
+	}
+	if err != nil && !added {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// plannedEntry is a directory entry that exists only in the plan.
+type plannedEntry struct {
+	name string
+	dir  bool
+}
+
+func (e plannedEntry) Name() string { return e.name }
+func (e plannedEntry) IsDir() bool  { return e.dir }
+
+func (e plannedEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e plannedEntry) Info() (fs.FileInfo, error) {
+	return nil, &fs.PathError{Op: "stat", Path: e.name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,214 @@
+// Package journal records processed archive sets in an append-only JSON
+// lines file so later runs can skip sets that were already extracted,
+// wherever their output went.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+// FileName is the journal file name inside the unrarall state directory.
+const FileName = "journal.jsonl"
+
+// DefaultPath returns the default location of the journal, or an empty
+// string when no state directory is available.
+func DefaultPath() string {
+	return fsutil.StatePath(FileName)
+}
+
+// Outcome is the result recorded for a processed set.
+type Outcome string
+
+const (
+	Extracted Outcome = "extracted"
+	Failed    Outcome = "failed"
+)
+
+// Volume identifies one volume file of a set as it was when processed.
+type Volume struct {
+	// Name is the volume's base name in the set directory.
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime_ns"`
+	// Sum is "algorithm:hex" when the volume was hashed during extraction.
+	Sum string `json:"sum,omitempty"`
+}
+
+// Entry is one journal line.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Set is the absolute path of the set's first volume.
+	Set         string   `json:"set"`
+	Volumes     []Volume `json:"volumes"`
+	Destination string   `json:"destination"`
+	// Files are the absolute paths written to the destination.
+	Files   []string `json:"files,omitempty"`
+	Outcome Outcome  `json:"outcome"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Journal holds the latest entry of every set in a journal file. A nil
+// *Journal is valid, finds nothing, and records nothing.
+type Journal struct {
+	path string
+
+	mu     sync.Mutex
+	latest map[string]Entry
+}
+
+// Open loads the journal at path. A missing file yields an empty journal
+// that is created by the first Append. Lines that do not parse, such as a
+// line cut short by a crash, are ignored. When more than half the lines are
+// superseded or unreadable, the file is compacted to the latest entry of
+// each set; a failed compaction leaves it as it was.
+func Open(path string) (*Journal, error) {
+	j := &Journal{path: path, latest: make(map[string]Entry)}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Set == "" {
+			continue
+		}
+		j.latest[entry.Set] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read journal %q: %w", path, err)
+	}
+	if lines > 2*len(j.latest) {
+		_ = j.compact()
+	}
+	return j, nil
+}
+
+// compact rewrites the journal with only the latest entry of each set,
+// oldest first.
+func (j *Journal) compact() error {
+	entries := make([]Entry, 0, len(j.latest))
+	for _, entry := range j.latest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if !entries[a].Time.Equal(entries[b].Time) {
+			return entries[a].Time.Before(entries[b].Time)
+		}
+		return entries[a].Set < entries[b].Set
+	})
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return fsutil.WriteFileAtomic(j.path, data, 0o600)
+}
+
+// Lookup returns the latest entry recorded for the set whose first volume
+// is at path.
+func (j *Journal) Lookup(path string) (Entry, bool) {
+	if j == nil {
+		return Entry{}, false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.latest[SetKey(path)]
+	return entry, ok
+}
+
+// Append writes entry as one line at the end of the journal. Entries from
+// concurrent candidates are serialized.
+func (j *Journal) Append(entry Entry) error {
+	if j == nil {
+		return nil
+	}
+	entry.Set = SetKey(entry.Set)
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	j.latest[entry.Set] = entry
+	return nil
+}
+
+// SetKey returns the key a set is journaled under: the cleaned absolute
+// path of its first volume.
+func SetKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return filepath.Clean(abs)
+	}
+	return filepath.Clean(path)
+}
+
+// StatVolume records the identity of volume name in dir.
+func StatVolume(dir, name string) (Volume, error) {
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		return Volume{}, err
+	}
+	return Volume{Name: name, Size: info.Size(), ModTime: info.ModTime().UnixNano()}, nil
+}
+
+// CheckVolumes reports whether every volume recorded in e is still present
+// with the same size and modification time. Recorded sums are not
+// recomputed.
+func (e Entry) CheckVolumes() error {
+	if len(e.Volumes) == 0 {
+		return errors.New("no volumes recorded")
+	}
+	dir := filepath.Dir(e.Set)
+	for _, recorded := range e.Volumes {
+		current, err := StatVolume(dir, recorded.Name)
+		if err != nil {
+			return fmt.Errorf("volume %q: %w", recorded.Name, err)
+		}
+		if current.Size != recorded.Size || current.ModTime != recorded.ModTime {
+			return fmt.Errorf("volume %q changed since it was processed", recorded.Name)
+		}
+	}
+	return nil
+}
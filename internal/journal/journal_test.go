@@ -0,0 +1,128 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalAppendAndReopen(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	set := filepath.Join(dir, "set.part1.rar")
+	if err := os.WriteFile(set, []byte("volume"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	path := filepath.Join(dir, "state", FileName)
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if _, ok := j.Lookup(set); ok {
+		t.Fatal("empty journal found an entry")
+	}
+	volume, err := StatVolume(dir, "set.part1.rar")
+	if err != nil {
+		t.Fatalf("StatVolume returned error: %v", err)
+	}
+	for _, outcome := range []Outcome{Failed, Extracted} {
+		entry := Entry{Set: set, Volumes: []Volume{volume}, Destination: dir, Outcome: outcome}
+		if err := j.Append(entry); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	// A line cut short by a crash must not hide earlier entries.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	if _, err := file.WriteString(`{"set":"` + set + `","outcome":"fai`); err != nil {
+		t.Fatalf("write torn line: %v", err)
+	}
+	file.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	entry, ok := reopened.Lookup(set)
+	if !ok {
+		t.Fatal("reopened journal lost the entry")
+	}
+	if entry.Outcome != Extracted || entry.Time.IsZero() {
+		t.Fatalf("entry=%+v, want the latest extracted entry with a time", entry)
+	}
+	if err := entry.CheckVolumes(); err != nil {
+		t.Fatalf("CheckVolumes returned error: %v", err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(set, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := entry.CheckVolumes(); err == nil {
+		t.Fatal("CheckVolumes accepted a volume with a new mtime")
+	}
+	if err := os.Remove(set); err != nil {
+		t.Fatalf("remove volume: %v", err)
+	}
+	if err := entry.CheckVolumes(); err == nil {
+		t.Fatal("CheckVolumes accepted a missing volume")
+	}
+}
+
+func TestOpenCompactsSupersededEntries(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	sets := []string{filepath.Join(dir, "a.rar"), filepath.Join(dir, "a.rar"), filepath.Join(dir, "a.rar"), filepath.Join(dir, "b.rar"), filepath.Join(dir, "a.rar")}
+	for i, set := range sets {
+		entry := Entry{Set: set, Destination: dir, Outcome: Failed, Time: time.Unix(int64(i), 0).UTC()}
+		if i == len(sets)-1 {
+			entry.Outcome = Extracted
+		}
+		if err := j.Append(entry); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Fatalf("journal holds %d lines, want one per set:\n%s", lines, data)
+	}
+	if entry, ok := reopened.Lookup(sets[0]); !ok || entry.Outcome != Extracted {
+		t.Fatalf("entry=%+v, want the latest entry of a.rar kept", entry)
+	}
+	if _, ok := reopened.Lookup(sets[3]); !ok {
+		t.Fatal("compaction lost b.rar")
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	t.Parallel()
+
+	var j *Journal
+	if _, ok := j.Lookup("set.rar"); ok {
+		t.Fatal("nil journal found an entry")
+	}
+	if err := j.Append(Entry{Set: "set.rar"}); err != nil {
+		t.Fatalf("Append on nil journal returned error: %v", err)
+	}
+}
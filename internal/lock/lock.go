@@ -0,0 +1,263 @@
+// Package lock keeps overlapping runs apart. A run lock on the scanned tree
+// stops a second run over the same root, and a lock file per archive set
+// stops two runs, on one host or several sharing the tree over NFS, from
+// extracting the same set. Set locks name their owner and are refreshed
+// while held, so locks left by dead processes are recognised as stale and
+// broken.
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+// RunFileName is the run lock file name at the root of a scanned tree.
+const RunFileName = fsutil.TempDirPrefix + "run.lock"
+
+// StaleAfter is how long a set lock may go unrefreshed before it is
+// considered abandoned. Holders refresh their locks well within it.
+const StaleAfter = 10 * time.Minute
+
+// staleAfter is StaleAfter, shortened by tests.
+var staleAfter = StaleAfter
+
+// SetPath returns the lock file path of the set whose first volume is at
+// firstVolume. The file sits beside the volumes so every host sharing the
+// tree sees it; its name starts like a temp directory's, so change
+// notifications and cleanup hooks ignore it.
+func SetPath(firstVolume string) string {
+	return filepath.Join(filepath.Dir(firstVolume), fsutil.TempDirPrefix+filepath.Base(firstVolume)+".lock")
+}
+
+// ErrBusy matches errors for locks held by another run.
+var ErrBusy = errors.New("locked by another run")
+
+// BusyError reports a lock held by another run.
+type BusyError struct {
+	Path string
+	// Owner is the holder as recorded in the lock file; it is zero when
+	// the file could not be read.
+	Owner Owner
+}
+
+func (e *BusyError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("%s is %v", e.Path, ErrBusy)
+	}
+	return fmt.Sprintf("%s is %v (%s)", e.Path, ErrBusy, e.Owner)
+}
+
+func (e *BusyError) Unwrap() error {
+	return ErrBusy
+}
+
+// Owner identifies the process holding a lock.
+type Owner struct {
+	Host    string    `json:"host"`
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// Token tells this acquisition apart from any other by the same
+	// process.
+	Token string `json:"token"`
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("pid %d on %s since %s", o.PID, o.Host, o.Started.Local().Format("2006-01-02 15:04:05"))
+}
+
+func newOwner() Owner {
+	host, _ := os.Hostname()
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	return Owner{Host: host, PID: os.Getpid(), Started: time.Now().UTC(), Token: hex.EncodeToString(token)}
+}
+
+// Lock is a held lock. A nil *Lock is valid and releases nothing.
+type Lock struct {
+	path  string
+	owner Owner
+	// file is the open run lock file on platforms with flock; nil for
+	// lock files held by existence.
+	file *os.File
+
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+	released sync.Once
+}
+
+// Path returns the lock file path.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// Release gives the lock up. Releasing a lock again does nothing.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	var err error
+	l.released.Do(func() {
+		if l.file != nil {
+			err = releaseRun(l)
+			return
+		}
+		close(l.stop)
+		l.stopped.Wait()
+		err = removeOwned(l.path, l.owner.Token)
+	})
+	return err
+}
+
+// AcquireSet locks the set whose first volume is at firstVolume. It
+// returns a *BusyError when another live run holds the set.
+func AcquireSet(firstVolume string) (*Lock, error) {
+	return acquireFile(SetPath(firstVolume))
+}
+
+// acquireFile takes the lock at path by creating it exclusively, breaking
+// a stale lock first. Exclusive creation is atomic on local file systems
+// and NFSv3 and later.
+func acquireFile(path string) (*Lock, error) {
+	owner := newOwner()
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return nil, err
+	}
+	// One retry covers a holder releasing, or a stale lock being broken,
+	// between the failed create and the look at the file.
+	for attempt := 0; attempt < 2; attempt++ {
+		err := createExclusive(path, data)
+		if err == nil {
+			l := &Lock{path: path, owner: owner, stop: make(chan struct{})}
+			l.stopped.Add(1)
+			go l.refresh()
+			return l, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		holder, modTime, err := readOwner(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil && modTime.IsZero() {
+			return nil, err
+		}
+		if !stale(holder, modTime, time.Now()) {
+			return nil, &BusyError{Path: path, Owner: holder}
+		}
+		if err := breakStale(path, holder); err != nil {
+			return nil, err
+		}
+	}
+	holder, _, _ := readOwner(path)
+	return nil, &BusyError{Path: path, Owner: holder}
+}
+
+func createExclusive(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// readOwner reads the holder of the lock file at path and when it was last
+// refreshed. A file still being written yields an error with its mtime.
+func readOwner(path string) (Owner, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Owner{}, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Owner{}, info.ModTime(), err
+	}
+	var owner Owner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return Owner{}, info.ModTime(), fmt.Errorf("lock file %s: %w", path, err)
+	}
+	return owner, info.ModTime(), nil
+}
+
+// stale reports whether a lock last refreshed at modTime was abandoned:
+// its holder ran on this host and has exited, or it has not been
+// refreshed for staleAfter.
+func stale(holder Owner, modTime, now time.Time) bool {
+	host, _ := os.Hostname()
+	if holder.PID > 0 && holder.Host == host && !processAlive(holder.PID) {
+		return true
+	}
+	return now.Sub(modTime) > staleAfter
+}
+
+// breakStale removes the stale lock at path held by holder. The lock is
+// moved aside first, so of several runs breaking it at once only one
+// removes it; a live lock moved aside by mistake is put back.
+func breakStale(path string, holder Owner) error {
+	aside := path + "." + newOwner().Token + ".stale"
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	moved, _, err := readOwner(aside)
+	if err == nil && moved.Token != holder.Token {
+		// Another run broke the lock and took the set in between.
+		_ = os.Link(aside, path)
+	}
+	return os.Remove(aside)
+}
+
+// refresh touches the lock file until the lock is released, so other runs
+// can tell it from an abandoned one.
+func (l *Lock) refresh() {
+	defer l.stopped.Done()
+	ticker := time.NewTicker(staleAfter / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			_ = os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// removeOwned removes the lock file at path if it still holds token; a
+// lock broken as stale and taken by another run is left alone.
+func removeOwned(path, token string) error {
+	holder, _, err := readOwner(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if holder.Token != token {
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,146 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeOwner(t *testing.T, path string, owner Owner, modTime time.Time) {
+	t.Helper()
+	data, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatalf("encode owner: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write lock file: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("set lock mtime: %v", err)
+	}
+}
+
+func TestAcquireSetIsExclusive(t *testing.T) {
+	t.Parallel()
+
+	volume := filepath.Join(t.TempDir(), "movie.part1.rar")
+	first, err := AcquireSet(volume)
+	if err != nil {
+		t.Fatalf("AcquireSet returned error: %v", err)
+	}
+	if first.Path() != filepath.Join(filepath.Dir(volume), ".unrarall-movie.part1.rar.lock") {
+		t.Fatalf("lock path=%q, want a hidden file beside the volume", first.Path())
+	}
+
+	_, err = AcquireSet(volume)
+	var busy *BusyError
+	if !errors.As(err, &busy) || !errors.Is(err, ErrBusy) || busy.Owner.PID != os.Getpid() {
+		t.Fatalf("second AcquireSet err=%v, want busy with this process as owner", err)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release returned error: %v", err)
+	}
+	if err := first.Release(); err != nil {
+		t.Fatalf("second Release returned error: %v", err)
+	}
+	if _, err := os.Stat(first.Path()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("lock file after release: %v, want it removed", err)
+	}
+	again, err := AcquireSet(volume)
+	if err != nil {
+		t.Fatalf("AcquireSet after release returned error: %v", err)
+	}
+	again.Release()
+}
+
+func TestAcquireSetBreaksStaleLocks(t *testing.T) {
+	t.Parallel()
+
+	host, _ := os.Hostname()
+	now := time.Now()
+	tests := []struct {
+		name    string
+		owner   Owner
+		modTime time.Time
+		busy    bool
+	}{
+		{name: "dead process on this host", owner: Owner{Host: host, PID: 1 << 30, Token: "a"}, modTime: now},
+		{name: "unrefreshed on another host", owner: Owner{Host: "elsewhere", PID: 1, Token: "b"}, modTime: now.Add(-2 * StaleAfter)},
+		{name: "unreadable and unrefreshed", modTime: now.Add(-2 * StaleAfter)},
+		{name: "refreshed on another host", owner: Owner{Host: "elsewhere", PID: 1, Token: "c"}, modTime: now, busy: true},
+		{name: "live process on this host", owner: Owner{Host: host, PID: os.Getpid(), Token: "d"}, modTime: now, busy: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			volume := filepath.Join(t.TempDir(), "movie.rar")
+			writeOwner(t, SetPath(volume), tc.owner, tc.modTime)
+
+			l, err := AcquireSet(volume)
+			if tc.busy {
+				if !errors.Is(err, ErrBusy) {
+					t.Fatalf("AcquireSet err=%v, want busy", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AcquireSet returned error: %v, want the stale lock broken", err)
+			}
+			defer l.Release()
+			entries, _ := os.ReadDir(filepath.Dir(volume))
+			if len(entries) != 1 {
+				t.Fatalf("directory holds %d entries, want only the new lock", len(entries))
+			}
+		})
+	}
+}
+
+func TestReleaseLeavesLocksTakenOver(t *testing.T) {
+	t.Parallel()
+
+	volume := filepath.Join(t.TempDir(), "movie.rar")
+	l, err := AcquireSet(volume)
+	if err != nil {
+		t.Fatalf("AcquireSet returned error: %v", err)
+	}
+	// Another run judged the lock stale and took the set.
+	writeOwner(t, l.Path(), Owner{Host: "elsewhere", PID: 1, Token: "other"}, time.Now())
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release returned error: %v", err)
+	}
+	if _, err := os.Stat(l.Path()); err != nil {
+		t.Fatalf("the other run's lock was removed: %v", err)
+	}
+}
+
+func TestAcquireRunIsExclusive(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first, err := AcquireRun(dir)
+	if err != nil {
+		t.Fatalf("AcquireRun returned error: %v", err)
+	}
+	_, err = AcquireRun(dir)
+	var busy *BusyError
+	if !errors.As(err, &busy) || busy.Owner.PID != os.Getpid() {
+		t.Fatalf("second AcquireRun err=%v, want busy with this process as owner", err)
+	}
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, RunFileName)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("run lock file after release: %v, want it removed", err)
+	}
+	again, err := AcquireRun(dir)
+	if err != nil {
+		t.Fatalf("AcquireRun after release returned error: %v", err)
+	}
+	again.Release()
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package lock
+
+import "os"
+
+// processAlive reports whether a process with pid exists on this host.
+// Where finding a process cannot fail, every holder looks alive and only
+// the refresh time marks a lock stale.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package lock
+
+import "path/filepath"
+
+// AcquireRun takes the run lock of the tree at dir. Without flock the lock
+// is an exclusively created RunFileName, kept fresh and broken when stale
+// like a set lock. It returns a *BusyError when another run holds it.
+func AcquireRun(dir string) (*Lock, error) {
+	return acquireFile(filepath.Join(dir, RunFileName))
+}
+
+// releaseRun is never reached: run locks here hold no open file.
+func releaseRun(*Lock) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build unix
+
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// AcquireRun takes the run lock of the tree at dir. The lock is an flock
+// on RunFileName, which the kernel (or the NFS lock manager) drops when its
+// holder exits, so it is never stale. It returns a *BusyError when another
+// run holds it.
+func AcquireRun(dir string) (*Lock, error) {
+	path := filepath.Join(dir, RunFileName)
+	owner := newOwner()
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			holder, _, _ := readOwner(path)
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, &BusyError{Path: path, Owner: holder}
+			}
+			return nil, &os.PathError{Op: "flock", Path: path, Err: err}
+		}
+
+		// A releasing holder removes the file before unlocking it; a lock
+		// taken on a removed file guards nothing, so open the new one.
+		opened, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		current, err := os.Stat(path)
+		if err != nil || !os.SameFile(opened, current) {
+			f.Close()
+			continue
+		}
+
+		data, err := json.Marshal(owner)
+		if err == nil {
+			err = f.Truncate(0)
+		}
+		if err == nil {
+			_, err = f.WriteAt(data, 0)
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &Lock{path: path, owner: owner, file: f}, nil
+	}
+}
+
+func releaseRun(l *Lock) error {
+	err := os.Remove(l.path)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -1,17 +1,36 @@
 package log
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
-// Logger provides simple leveled logging controls.
+// Level is the level a message was logged at.
+type Level string
+
+const (
+	LevelInfo    Level = "info"
+	LevelVerbose Level = "verbose"
+	LevelError   Level = "error"
+)
+
+// Logger provides simple leveled logging controls. It is safe for
+// concurrent use; each message is written with a single call.
 type Logger struct {
 	quiet       bool
 	verbose     bool
+	prefix      string
 	infoWriter  io.Writer
 	errorWriter io.Writer
+	// mu is shared with loggers derived through WithPrefix, so their lines
+	// never interleave on the common writers.
+	mu *sync.Mutex
+
+	// sink, when set, receives messages instead of the writers.
+	sink func(level Level, message string)
 }
 
 // New creates a new logger.
@@ -33,15 +52,36 @@ func NewWithWriters(quiet, verbose bool, infoWriter, errorWriter io.Writer) *Log
 		verbose:     verbose,
 		infoWriter:  infoWriter,
 		errorWriter: errorWriter,
+		mu:          &sync.Mutex{},
+	}
+}
+
+// NewWithSink creates a logger that hands each message, prefix included and
+// without a trailing newline, to sink instead of writing it. Calls to sink
+// are serialized.
+func NewWithSink(quiet, verbose bool, sink func(level Level, message string)) *Logger {
+	return &Logger{
+		quiet:   quiet,
+		verbose: verbose,
+		mu:      &sync.Mutex{},
+		sink:    sink,
 	}
 }
 
+// WithPrefix returns a logger writing to the same sinks with prefix added
+// in front of every message.
+func (l *Logger) WithPrefix(prefix string) *Logger {
+	derived := *l
+	derived.prefix = l.prefix + prefix
+	return &derived
+}
+
 // Infof logs a standard informational message.
 func (l *Logger) Infof(format string, args ...any) {
 	if l.quiet {
 		return
 	}
-	fmt.Fprintf(l.infoWriter, format+"\n", args...)
+	l.write(LevelInfo, l.infoWriter, format, args)
 }
 
 // Verbosef logs details that should only appear in verbose mode.
@@ -49,7 +89,7 @@ func (l *Logger) Verbosef(format string, args ...any) {
 	if l.quiet || !l.verbose {
 		return
 	}
-	fmt.Fprintf(l.infoWriter, format+"\n", args...)
+	l.write(LevelVerbose, l.infoWriter, format, args)
 }
 
 // Errorf logs errors to stderr.
@@ -57,5 +97,66 @@ func (l *Logger) Errorf(format string, args ...any) {
 	if l.quiet {
 		return
 	}
-	fmt.Fprintf(l.errorWriter, format+"\n", args...)
+	l.write(LevelError, l.errorWriter, format, args)
+}
+
+func (l *Logger) write(level Level, w io.Writer, format string, args []any) {
+	message := l.prefix + fmt.Sprintf(format, args...)
+	if l.mu != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+	if l.sink != nil {
+		l.sink(level, message)
+		return
+	}
+	io.WriteString(w, message+"\n")
+}
+
+// maxLineBytes bounds a line held back by a LineWriter; longer lines are
+// logged in pieces.
+const maxLineBytes = 64 << 10
+
+// LineWriter returns a writer that logs each line written to it at level,
+// so command output follows the logger's quiet mode, prefix, and sinks.
+// Close logs a final line left without a newline.
+func (l *Logger) LineWriter(level Level) io.WriteCloser {
+	logf := l.Infof
+	switch level {
+	case LevelVerbose:
+		logf = l.Verbosef
+	case LevelError:
+		logf = l.Errorf
+	}
+	return &lineWriter{logf: logf}
+}
+
+type lineWriter struct {
+	logf func(format string, args ...any)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.logf("%s", bytes.TrimSuffix(w.buf[:i], []byte("\r")))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxLineBytes {
+		w.logf("%s", w.buf)
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) Close() error {
+	if len(w.buf) > 0 {
+		w.logf("%s", w.buf)
+		w.buf = nil
+	}
+	return nil
 }
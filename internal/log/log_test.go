@@ -59,3 +59,78 @@ func TestLoggerQuietSuppressesAllOutput(t *testing.T) {
 		t.Fatalf("expected no error output, got %q", got)
 	}
 }
+
+func TestLoggerWithPrefixSharesSinks(t *testing.T) {
+	t.Parallel()
+
+	var infoBuf bytes.Buffer
+	var errBuf bytes.Buffer
+
+	logger := NewWithWriters(false, false, &infoBuf, &errBuf)
+	prefixed := logger.WithPrefix("[set] ")
+	logger.Infof("plain")
+	prefixed.Infof("info")
+	prefixed.Errorf("error")
+
+	if got, want := infoBuf.String(), "plain\n[set] info\n"; got != want {
+		t.Fatalf("info output=%q, want %q", got, want)
+	}
+	if got, want := errBuf.String(), "[set] error\n"; got != want {
+		t.Fatalf("error output=%q, want %q", got, want)
+	}
+}
+
+func TestLoggerWithSinkReceivesLevels(t *testing.T) {
+	t.Parallel()
+
+	type entry struct {
+		level   Level
+		message string
+	}
+	var got []entry
+	logger := NewWithSink(false, true, func(level Level, message string) {
+		got = append(got, entry{level, message})
+	})
+	logger.Infof("info %d", 1)
+	logger.WithPrefix("[set] ").Verbosef("verbose")
+	logger.Errorf("error")
+
+	want := []entry{{LevelInfo, "info 1"}, {LevelVerbose, "[set] verbose"}, {LevelError, "error"}}
+	if len(got) != len(want) {
+		t.Fatalf("entries=%v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("entries=%v, want %v", got, want)
+		}
+	}
+}
+
+func TestLineWriterLogsEachLine(t *testing.T) {
+	t.Parallel()
+
+	var infoBuf, errBuf bytes.Buffer
+	logger := NewWithWriters(false, false, &infoBuf, &errBuf).WithPrefix("[set] ")
+	out := logger.LineWriter(LevelInfo)
+	out.Write([]byte("one\r\ntw"))
+	out.Write([]byte("o\nthree"))
+	out.Close()
+	stderr := logger.LineWriter(LevelError)
+	stderr.Write([]byte("oops\n"))
+	stderr.Close()
+
+	if got, want := infoBuf.String(), "[set] one\n[set] two\n[set] three\n"; got != want {
+		t.Fatalf("info output=%q, want %q", got, want)
+	}
+	if got, want := errBuf.String(), "[set] oops\n"; got != want {
+		t.Fatalf("error output=%q, want %q", got, want)
+	}
+
+	var quietBuf bytes.Buffer
+	quiet := NewWithWriters(true, false, &quietBuf, &quietBuf).LineWriter(LevelError)
+	quiet.Write([]byte("hidden\n"))
+	quiet.Close()
+	if quietBuf.Len() != 0 {
+		t.Fatalf("quiet output=%q, want none", quietBuf.String())
+	}
+}
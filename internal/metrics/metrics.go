@@ -0,0 +1,246 @@
+// Package metrics keeps Prometheus counters and histograms fed from report
+// records, for --metrics-addr and --metrics-file. Output uses the Prometheus
+// text exposition format, which node_exporter's textfile collector also
+// reads.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// durationBuckets are histogram upper bounds in seconds, from quick small
+// sets to multi-gigabyte ones on slow disks.
+var durationBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+// Metrics accumulates totals across runs. A nil *Metrics ignores updates.
+type Metrics struct {
+	mu           sync.Mutex
+	found        uint64
+	extracted    uint64
+	skipped      map[string]uint64
+	failed       map[string]uint64
+	bytesRead    int64
+	bytesWritten int64
+	passwords    map[string]uint64
+	extract      histogram
+	checksum     histogram
+	runs         uint64
+	lastRun      time.Time
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// New returns empty metrics.
+func New() *Metrics {
+	return &Metrics{
+		skipped:   make(map[string]uint64),
+		failed:    make(map[string]uint64),
+		passwords: make(map[string]uint64),
+	}
+}
+
+// Observe adds a finished candidate record. Dry-run records are ignored.
+func (m *Metrics) Observe(c *report.Candidate) {
+	if m == nil || c.Outcome == report.DryRun {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.found++
+	switch c.Outcome {
+	case report.Extracted:
+		m.extracted++
+		m.extract.observe(seconds(c.Durations.Extract))
+	case report.Skipped:
+		m.skipped[c.SkipReason]++
+	case report.Failed:
+		class := string(report.ClassUnknown)
+		if c.Error != nil && c.Error.Class != "" {
+			class = string(c.Error.Class)
+		}
+		m.failed[class]++
+	}
+	if c.Checksum.Result == report.ChecksumPassed || c.Checksum.Result == report.ChecksumFailed {
+		m.checksum.observe(seconds(c.Durations.Checksum))
+	}
+	m.bytesRead += c.BytesRead
+	m.bytesWritten += c.BytesWritten
+
+	failures := c.Password.Attempts
+	if c.Password.Source != "" && failures > 0 {
+		m.passwords["success"]++
+		failures--
+	}
+	if failures > 0 {
+		m.passwords["failure"] += uint64(failures)
+	}
+}
+
+// RunFinished counts a finished run or watch pass.
+func (m *Metrics) RunFinished(at time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs++
+	m.lastRun = at
+}
+
+func seconds(millis int64) float64 {
+	return float64(millis) / 1000
+}
+
+// WriteTo writes every metric in the text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+	counter(&b, "unrarall_archives_found_total", "Archive sets found, nested sets included.", "", map[string]uint64{"": m.found})
+	counter(&b, "unrarall_archives_extracted_total", "Archive sets extracted.", "", map[string]uint64{"": m.extracted})
+	counter(&b, "unrarall_archives_skipped_total", "Archive sets skipped, by reason.", "reason", m.skipped)
+	counter(&b, "unrarall_archives_failed_total", "Archive sets that failed, by error class.", "class", m.failed)
+	counter(&b, "unrarall_read_bytes_total", "Bytes of archive volumes read by successful extractions.", "", map[string]uint64{"": uint64(m.bytesRead)})
+	counter(&b, "unrarall_written_bytes_total", "Bytes of extracted files placed in destinations.", "", map[string]uint64{"": uint64(m.bytesWritten)})
+	counter(&b, "unrarall_password_attempts_total", "Passwords tried on encrypted archives, by result.", "result", m.passwords)
+	m.extract.write(&b, "unrarall_extract_duration_seconds", "Time to extract a set, nested archives included.")
+	m.checksum.write(&b, "unrarall_checksum_duration_seconds", "Time to verify a set's volumes against an SFV or hash manifest.")
+	counter(&b, "unrarall_runs_total", "Runs and watch passes finished.", "", map[string]uint64{"": m.runs})
+	if !m.lastRun.IsZero() {
+		header(&b, "unrarall_last_run_timestamp_seconds", "Unix time the latest run or watch pass finished.", "gauge")
+		fmt.Fprintf(&b, "unrarall_last_run_timestamp_seconds %s\n", formatFloat(float64(m.lastRun.UnixMilli())/1000))
+	}
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+func header(b *bytes.Buffer, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// counter writes a counter family. With an empty label, values holds a
+// single "" key.
+func counter(b *bytes.Buffer, name, help, label string, values map[string]uint64) {
+	header(b, name, help, "counter")
+	if label == "" {
+		fmt.Fprintf(b, "%s %d\n", name, values[""])
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(b, "%s{%s=%s} %d\n", name, label, quote(key), values[key])
+	}
+}
+
+func (h *histogram) write(b *bytes.Buffer, name, help string) {
+	header(b, name, help, "histogram")
+	for i, bound := range durationBuckets {
+		var count uint64
+		if h.counts != nil {
+			count = h.counts[i]
+		}
+		fmt.Fprintf(b, "%s_bucket{le=%q} %d\n", name, formatFloat(bound), count)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(b, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count %d\n", name, h.count)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// quote escapes a label value as the exposition format requires.
+func quote(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// ServeHTTP writes the metrics for a scrape.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = m.WriteTo(w)
+}
+
+// WriteFile replaces the file at path with the metrics, so the textfile
+// collector never reads a partial file.
+func WriteFile(path string, m *Metrics) error {
+	var b bytes.Buffer
+	if _, err := m.WriteTo(&b); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, b.Bytes(), 0o644)
+}
+
+// Server exposes metrics on /metrics.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// Serve starts serving m on /metrics at addr.
+func Serve(addr string, m *Metrics) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	s := &Server{
+		srv:      &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		listener: listener,
+	}
+	go func() { _ = s.srv.Serve(listener) }()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server. A nil *Server is a no-op.
+func (s *Server) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.srv.Close()
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+func render(t *testing.T, m *Metrics) string {
+	t.Helper()
+	var b bytes.Buffer
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo returned error: %v", err)
+	}
+	return b.String()
+}
+
+func TestObserveRecords(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+	m.Observe(&report.Candidate{
+		Outcome:      report.Extracted,
+		Checksum:     report.Checksum{Result: report.ChecksumPassed},
+		Password:     report.Password{Required: true, Source: "password file", Attempts: 3},
+		Durations:    report.Durations{Extract: 2500, Checksum: 400},
+		BytesRead:    1000,
+		BytesWritten: 900,
+	})
+	m.Observe(&report.Candidate{Outcome: report.Skipped, SkipReason: "journal"})
+	m.Observe(&report.Candidate{Outcome: report.Failed, Error: &report.Error{Class: report.ClassCorrupt}})
+	m.Observe(&report.Candidate{Outcome: report.Failed, Password: report.Password{Required: true, Attempts: 2}, Error: &report.Error{Class: report.ClassPassword}})
+	m.Observe(&report.Candidate{Outcome: report.DryRun})
+	m.RunFinished(time.Unix(1700000000, 500e6))
+
+	got := render(t, m)
+	for _, want := range []string{
+		"# TYPE unrarall_archives_found_total counter\nunrarall_archives_found_total 4\n",
+		"unrarall_archives_extracted_total 1\n",
+		"unrarall_archives_skipped_total{reason=\"journal\"} 1\n",
+		"unrarall_archives_failed_total{class=\"corrupt\"} 1\nunrarall_archives_failed_total{class=\"password\"} 1\n",
+		"unrarall_read_bytes_total 1000\n",
+		"unrarall_written_bytes_total 900\n",
+		"unrarall_password_attempts_total{result=\"failure\"} 4\nunrarall_password_attempts_total{result=\"success\"} 1\n",
+		"# TYPE unrarall_extract_duration_seconds histogram\n",
+		"unrarall_extract_duration_seconds_bucket{le=\"1\"} 0\nunrarall_extract_duration_seconds_bucket{le=\"5\"} 1\n",
+		"unrarall_extract_duration_seconds_bucket{le=\"+Inf\"} 1\nunrarall_extract_duration_seconds_sum 2.5\nunrarall_extract_duration_seconds_count 1\n",
+		"unrarall_checksum_duration_seconds_bucket{le=\"0.5\"} 1\n",
+		"unrarall_runs_total 1\n",
+		"unrarall_last_run_timestamp_seconds 1.7000000005e+09\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestEmptyMetricsOmitLastRun(t *testing.T) {
+	t.Parallel()
+
+	got := render(t, New())
+	if !strings.Contains(got, "unrarall_archives_found_total 0\n") || strings.Contains(got, "last_run") {
+		t.Fatalf("metrics=%s\nwant zero counters and no last-run gauge", got)
+	}
+	var m *Metrics
+	m.Observe(&report.Candidate{Outcome: report.Extracted})
+	m.RunFinished(time.Now())
+}
+
+func TestQuoteEscapesLabelValues(t *testing.T) {
+	t.Parallel()
+
+	if got, want := quote("a\\b\"c\nd"), `"a\\b\"c\nd"`; got != want {
+		t.Fatalf("quote=%s, want %s", got, want)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+	m.RunFinished(time.Now())
+	path := filepath.Join(t.TempDir(), "unrarall.prom")
+	if err := WriteFile(path, m); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read metrics file: %v", err)
+	}
+	if !strings.Contains(string(data), "unrarall_runs_total 1\n") {
+		t.Fatalf("metrics file=%s, want one run", data)
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+	m.Observe(&report.Candidate{Outcome: report.Extracted})
+	srv, err := Serve("127.0.0.1:0", m)
+	if err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + srv.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != ContentType || !strings.Contains(string(body), "unrarall_archives_extracted_total 1\n") {
+		t.Fatalf("scrape returned %q with %s, want the extracted count", resp.Header.Get("Content-Type"), body)
+	}
+}
@@ -0,0 +1,119 @@
+package passwords
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CommandTimeout bounds how long a password command may run.
+const CommandTimeout = 2 * time.Minute
+
+// Environment variables describing the archive to password commands.
+const (
+	EnvArchive    = "UNRARALL_ARCHIVE"
+	EnvArchiveDir = "UNRARALL_ARCHIVE_DIR"
+	EnvStem       = "UNRARALL_STEM"
+	EnvGroup      = "UNRARALL_GROUP"
+)
+
+// CommandProvider runs Command through the platform shell and reads one
+// candidate password per stdout line. The archive is described to the
+// command through the UNRARALL_* environment variables rather than
+// arguments, so the command line can stay fixed.
+type CommandProvider struct {
+	Command string
+	// Context, when set, kills the command once done.
+	Context context.Context
+}
+
+// Name implements Provider.
+func (p CommandProvider) Name() string {
+	return "password command"
+}
+
+// Passwords implements Provider.
+func (p CommandProvider) Passwords(target Target) ([]string, error) {
+	parent := target.Context
+	if parent == nil {
+		parent = p.Context
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, CommandTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, p.Command)
+	cmd.Env = append(os.Environ(),
+		EnvArchive+"="+target.ArchivePath,
+		EnvArchiveDir+"="+target.Dir(),
+		EnvStem+"="+target.Stem,
+		EnvGroup+"="+target.Group(),
+	)
+	cmd.Stdin = nil
+	cmd.Stderr = os.Stderr
+	// Background children may hold stdout open after the command is killed.
+	cmd.WaitDelay = 5 * time.Second
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		if parent.Err() != nil {
+			return nil, parent.Err()
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s", CommandTimeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("exited with status %d", exitErr.ExitCode())
+		}
+		return nil, err
+	}
+	return splitLines(stdout.String()), nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// EnvProvider reads candidate passwords from an environment variable, one per
+// line. An unset or empty variable yields no candidates.
+type EnvProvider struct {
+	Var string
+}
+
+// Name implements Provider.
+func (p EnvProvider) Name() string {
+	return fmt.Sprintf("environment variable %s", p.Var)
+}
+
+// Passwords implements Provider.
+func (p EnvProvider) Passwords(Target) ([]string, error) {
+	return splitLines(os.Getenv(p.Var)), nil
+}
+
+func splitLines(value string) []string {
+	var out []string
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package passwords
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandProviderReadsPasswordsFromStdout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	target := TargetFor("/downloads/Show.S01-GRP/show-grp.part1.rar")
+	provider := CommandProvider{Command: `printf '%s\n\n%s\n' "$UNRARALL_STEM" "$UNRARALL_GROUP:$UNRARALL_ARCHIVE_DIR"`}
+
+	got, err := provider.Passwords(target)
+	if err != nil {
+		t.Fatalf("Passwords returned error: %v", err)
+	}
+	want := []string{"show-grp", "grp:/downloads/Show.S01-GRP"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Passwords=%v, want %v", got, want)
+	}
+}
+
+func TestCommandProviderReportsExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	_, err := CommandProvider{Command: "echo leaked; exit 3"}.Passwords(TargetFor("/downloads/a.rar"))
+	if err == nil {
+		t.Fatal("expected error for failing command")
+	}
+	if !strings.Contains(err.Error(), "status 3") || strings.Contains(err.Error(), "leaked") {
+		t.Fatalf("error=%q, want exit status without command output", err)
+	}
+}
+
+func TestCommandProviderStopsWhenCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test command uses POSIX shell syntax")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := CommandProvider{Command: "exec sleep 30", Context: ctx}.Passwords(TargetFor("/downloads/a.rar"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error=%v, want context.Canceled", err)
+	}
+	if waited := time.Since(start); waited > 10*time.Second {
+		t.Fatalf("Passwords waited %s after cancellation", waited)
+	}
+}
+
+func TestEnvProviderSplitsLines(t *testing.T) {
+	t.Setenv("UNRARALL_TEST_PASSWORDS", "first\r\n\nsecond\n")
+
+	got, err := EnvProvider{Var: "UNRARALL_TEST_PASSWORDS"}.Passwords(Target{})
+	if err != nil {
+		t.Fatalf("Passwords returned error: %v", err)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Passwords=%v, want %v", got, want)
+	}
+}
+
+func TestReadLineStopsAtNewline(t *testing.T) {
+	t.Parallel()
+
+	reader := strings.NewReader("secret\r\nrest")
+	got, err := readLine(reader)
+	if err != nil {
+		t.Fatalf("readLine returned error: %v", err)
+	}
+	if got != "secret" {
+		t.Fatalf("readLine=%q, want %q", got, "secret")
+	}
+	if reader.Len() != len("rest") {
+		t.Fatalf("readLine consumed %d bytes past the newline", len("rest")-reader.Len())
+	}
+}
+
+func TestReadLineContextStopsWhenCanceled(t *testing.T) {
+	t.Parallel()
+
+	// Nothing is ever written, so the read blocks like an idle terminal.
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("create pipe: %v", err)
+	}
+	defer writer.Close()
+	defer reader.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := readLineContext(ctx, reader); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("readLineContext error=%v, want %v", err, context.DeadlineExceeded)
+	}
+}
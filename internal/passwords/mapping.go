@@ -0,0 +1,196 @@
+package passwords
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// MatchKind selects which archive attribute a mapping rule matches against.
+type MatchKind string
+
+const (
+	// MatchPath matches the archive path or any of its parent directories.
+	MatchPath MatchKind = "path"
+	// MatchStem matches the archive set stem, case-insensitively.
+	MatchStem MatchKind = "stem"
+	// MatchGroup matches the release group suffix of the stem, case-insensitively.
+	MatchGroup MatchKind = "group"
+)
+
+// Rule maps a glob pattern to a password.
+type Rule struct {
+	Kind     MatchKind
+	Pattern  string
+	Password string
+}
+
+// Matches reports whether the rule applies to target.
+func (r Rule) Matches(target Target) bool {
+	switch r.Kind {
+	case MatchPath:
+		pattern := filepath.Clean(r.Pattern)
+		current := filepath.Clean(target.ArchivePath)
+		for {
+			if ok, _ := filepath.Match(pattern, current); ok {
+				return true
+			}
+			parent := filepath.Dir(current)
+			if parent == current {
+				return false
+			}
+			current = parent
+		}
+	case MatchStem:
+		return matchFold(r.Pattern, target.Stem)
+	case MatchGroup:
+		group := target.Group()
+		return group != "" && matchFold(r.Pattern, group)
+	default:
+		return false
+	}
+}
+
+func matchFold(pattern, value string) bool {
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return ok
+}
+
+// ParseMapping parses mapping rules. Each non-blank, non-comment line has the
+// form "kind:pattern password" where kind is path, stem, or group, and the
+// password is everything after the whitespace that follows the pattern.
+func ParseMapping(r io.Reader) ([]Rule, error) {
+	scanner := bufio.NewScanner(r)
+	rules := make([]Rule, 0, 16)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		rule, err := parseRule(strings.TrimLeftFunc(line, unicode.IsSpace))
+		if err != nil {
+			return nil, fmt.Errorf("mapping line %d: %w", lineNo, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseRule(line string) (Rule, error) {
+	sep := strings.IndexFunc(line, unicode.IsSpace)
+	if sep < 0 {
+		return Rule{}, fmt.Errorf("missing password")
+	}
+	selector := line[:sep]
+	password := strings.TrimLeftFunc(line[sep:], unicode.IsSpace)
+	if password == "" {
+		return Rule{}, fmt.Errorf("missing password")
+	}
+
+	kind, pattern, ok := strings.Cut(selector, ":")
+	if !ok {
+		return Rule{}, fmt.Errorf("selector %q must be kind:pattern", selector)
+	}
+	if pattern == "" {
+		return Rule{}, fmt.Errorf("selector %q has an empty pattern", selector)
+	}
+
+	rule := Rule{
+		Kind:     MatchKind(strings.ToLower(kind)),
+		Pattern:  pattern,
+		Password: password,
+	}
+	switch rule.Kind {
+	case MatchPath:
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	case MatchStem, MatchGroup:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	default:
+		return Rule{}, fmt.Errorf("unknown match kind %q", kind)
+	}
+	return rule, nil
+}
+
+// MappingProvider returns passwords from mapping rules matching the archive.
+// The chain from DefaultChain parses Path once and keeps its rules for the
+// run.
+type MappingProvider struct {
+	Path  string
+	rules *mappingCache
+}
+
+// Name implements Provider.
+func (p MappingProvider) Name() string {
+	return fmt.Sprintf("password map %q", p.Path)
+}
+
+// Passwords implements Provider.
+func (p MappingProvider) Passwords(target Target) ([]string, error) {
+	var (
+		rules []Rule
+		err   error
+	)
+	if p.rules != nil {
+		rules, err = p.rules.load(p.Path)
+	} else {
+		rules, err = readMapping(p.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, rule := range rules {
+		if rule.Matches(target) {
+			out = append(out, rule.Password)
+		}
+	}
+	return out, nil
+}
+
+// mappingCache keeps the rules of a mapping file after the first
+// successful parse, so archives of a run do not read the file again.
+type mappingCache struct {
+	mu    sync.Mutex
+	rules []Rule
+}
+
+func (c *mappingCache) load(path string) ([]Rule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rules == nil {
+		rules, err := readMapping(path)
+		if err != nil {
+			return nil, err
+		}
+		c.rules = rules
+	}
+	return c.rules, nil
+}
+
+func readMapping(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseMapping(file)
+}
@@ -0,0 +1,128 @@
+package passwords
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMapping(t *testing.T) {
+	t.Parallel()
+
+	content := "\n# comment\n" +
+		"path:/data/private   first secret\n" +
+		"STEM:Show.S01*\ttabbed\n" +
+		"  group:GRP trailing space \n"
+
+	rules, err := ParseMapping(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseMapping returned error: %v", err)
+	}
+
+	want := []Rule{
+		{Kind: MatchPath, Pattern: "/data/private", Password: "first secret"},
+		{Kind: MatchStem, Pattern: "Show.S01*", Password: "tabbed"},
+		{Kind: MatchGroup, Pattern: "GRP", Password: "trailing space "},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules=%+v, want %+v", rules, want)
+	}
+}
+
+func TestParseMappingRejectsInvalidLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		"stem:release\n",
+		"release secret\n",
+		"name:release secret\n",
+		"stem: secret\n",
+		"stem:[ secret\n",
+	}
+	for _, content := range tests {
+		if _, err := ParseMapping(strings.NewReader(content)); err == nil {
+			t.Fatalf("expected parse error for %q", content)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	t.Parallel()
+
+	target := TargetFor(filepath.Join("/data", "private", "Show.S01E01-GRP", "Show.S01E01-GRP.part01.rar"))
+
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{name: "parent directory", rule: Rule{Kind: MatchPath, Pattern: "/data/private"}, want: true},
+		{name: "path glob", rule: Rule{Kind: MatchPath, Pattern: "/data/*/Show.*"}, want: true},
+		{name: "other path", rule: Rule{Kind: MatchPath, Pattern: "/data/public"}, want: false},
+		{name: "stem glob case-insensitive", rule: Rule{Kind: MatchStem, Pattern: "show.s01*"}, want: true},
+		{name: "stem mismatch", rule: Rule{Kind: MatchStem, Pattern: "Movie.*"}, want: false},
+		{name: "group", rule: Rule{Kind: MatchGroup, Pattern: "grp"}, want: true},
+		{name: "group mismatch", rule: Rule{Kind: MatchGroup, Pattern: "OTHER"}, want: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.rule.Matches(target); got != tc.want {
+				t.Fatalf("Matches()=%v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMappingProviderReturnsMatchingPasswordsInFileOrder(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	mapFile := filepath.Join(root, "map.txt")
+	content := "group:GRP by-group\nstem:Other* unrelated\npath:" + root + " by-path\n"
+	if err := os.WriteFile(mapFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("write map file: %v", err)
+	}
+
+	provider := MappingProvider{Path: mapFile}
+	got, err := provider.Passwords(TargetFor(filepath.Join(root, "Title-GRP.rar")))
+	if err != nil {
+		t.Fatalf("Passwords returned error: %v", err)
+	}
+	want := []string{"by-group", "by-path"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("passwords=%v, want %v", got, want)
+	}
+}
+
+func TestDefaultChainParsesMappingOncePerRun(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	mapFile := filepath.Join(root, "map.txt")
+	if err := os.WriteFile(mapFile, []byte("group:GRP first\n"), 0o644); err != nil {
+		t.Fatalf("write map file: %v", err)
+	}
+	var provider Provider
+	for _, p := range DefaultChain(Config{MappingFile: mapFile}) {
+		if _, ok := p.(MappingProvider); ok {
+			provider = p
+		}
+	}
+	target := TargetFor(filepath.Join(root, "Title-GRP.rar"))
+	if got, err := provider.Passwords(target); err != nil || !reflect.DeepEqual(got, []string{"first"}) {
+		t.Fatalf("Passwords=%v, %v, want [first]", got, err)
+	}
+
+	// Later archives of the run reuse the parsed rules.
+	if err := os.Remove(mapFile); err != nil {
+		t.Fatalf("remove map file: %v", err)
+	}
+	if got, err := provider.Passwords(target); err != nil || !reflect.DeepEqual(got, []string{"first"}) {
+		t.Fatalf("Passwords=%v, %v after the file was removed, want the cached rules", got, err)
+	}
+}
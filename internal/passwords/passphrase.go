@@ -0,0 +1,74 @@
+package passwords
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// VaultPassphraseEnv names the environment variable holding the vault
+// passphrase.
+const VaultPassphraseEnv = "UNRARALL_VAULT_PASSPHRASE"
+
+// PassphraseOptions selects where a vault passphrase comes from. Sources are
+// tried in order: the VaultPassphraseEnv variable, file descriptor FD (when
+// >= 0), then a no-echo prompt when In is a terminal.
+type PassphraseOptions struct {
+	FD  int
+	In  *os.File
+	Out io.Writer
+	// Confirm asks for the prompted passphrase twice, for new vaults.
+	Confirm bool
+	// Context, when set, ends the prompt once done.
+	Context context.Context
+}
+
+// ResolvePassphrase returns the vault passphrase from the first available
+// source in opts.
+func ResolvePassphrase(opts PassphraseOptions) (string, error) {
+	if value := os.Getenv(VaultPassphraseEnv); value != "" {
+		return value, nil
+	}
+
+	if opts.FD >= 0 {
+		file := os.NewFile(uintptr(opts.FD), fmt.Sprintf("fd %d", opts.FD))
+		if file == nil {
+			return "", fmt.Errorf("invalid passphrase file descriptor %d", opts.FD)
+		}
+		value, err := readLine(file)
+		if err != nil {
+			return "", fmt.Errorf("read passphrase from fd %d: %w", opts.FD, err)
+		}
+		if value == "" {
+			return "", fmt.Errorf("passphrase from fd %d is empty", opts.FD)
+		}
+		return value, nil
+	}
+
+	if !IsTerminal(opts.In) {
+		return "", fmt.Errorf("no passphrase available; set %s, pass --passphrase-fd, or run on a terminal", VaultPassphraseEnv)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	value, err := promptNoEcho(ctx, opts.In, opts.Out, "Vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", errors.New("vault passphrase is empty")
+	}
+	if opts.Confirm {
+		again, err := promptNoEcho(ctx, opts.In, opts.Out, "Repeat vault passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != value {
+			return "", errors.New("vault passphrases do not match")
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,144 @@
+package passwords
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// PromptSource is the source name reported for interactively entered passwords.
+const PromptSource = "interactive prompt"
+
+// MaxPromptAttempts bounds how many times a user is asked for one archive.
+const MaxPromptAttempts = 3
+
+var errNoEchoUnsupported = errors.New("disabling terminal echo is not supported on this platform")
+
+// Prompter asks a user for a password after every configured source failed.
+type Prompter interface {
+	// Prompt returns the password entered for target. An empty password
+	// means the user gave up. Once ctx is done, Prompt stops waiting and
+	// returns ctx.Err().
+	Prompt(ctx context.Context, target Target, attempt int) (string, error)
+}
+
+// TerminalPrompter reads passwords from a terminal with echo disabled.
+type TerminalPrompter struct {
+	In  *os.File
+	Out io.Writer
+
+	mu sync.Mutex
+}
+
+// NewTerminalPrompter returns a prompter reading from stdin and writing
+// prompts to stderr, or nil when stdin is not a terminal.
+func NewTerminalPrompter() *TerminalPrompter {
+	if !IsTerminal(os.Stdin) {
+		return nil
+	}
+	return &TerminalPrompter{In: os.Stdin, Out: os.Stderr}
+}
+
+// IsTerminal reports whether f is attached to a character device.
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Prompt implements Prompter. Prompts are serialized so concurrent callers
+// never interleave on the terminal.
+func (p *TerminalPrompter) Prompt(ctx context.Context, target Target, attempt int) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if attempt > 1 {
+		fmt.Fprintf(p.Out, "Password rejected for %q.\n", target.ArchivePath)
+	}
+	return promptNoEcho(ctx, p.In, p.Out, fmt.Sprintf("Password for %q (empty to skip): ", target.ArchivePath))
+}
+
+// PromptSecret reads one line from in with echo disabled. in must be a
+// terminal.
+func PromptSecret(in *os.File, out io.Writer, prompt string) (string, error) {
+	if !IsTerminal(in) {
+		return "", errors.New("stdin is not a terminal")
+	}
+	return promptNoEcho(context.Background(), in, out, prompt)
+}
+
+// promptNoEcho reads one line from in with echo disabled until ctx is done.
+// Echo is restored before it returns either way.
+func promptNoEcho(ctx context.Context, in *os.File, out io.Writer, prompt string) (string, error) {
+	fmt.Fprint(out, prompt)
+
+	restore, err := disableEcho(in)
+	if err != nil {
+		fmt.Fprintln(out)
+		return "", err
+	}
+	line, readErr := readLineContext(ctx, in)
+	restoreErr := restore()
+	fmt.Fprintln(out)
+
+	if readErr != nil {
+		return "", readErr
+	}
+	if restoreErr != nil {
+		return "", restoreErr
+	}
+	return line, nil
+}
+
+// readLineContext is readLine returning ctx.Err() once ctx is done. A read
+// blocked on a terminal cannot be interrupted, so it is left to finish in
+// the background and the line it reads is discarded.
+func readLineContext(ctx context.Context, in io.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	read := make(chan result, 1)
+	go func() {
+		line, err := readLine(in)
+		read <- result{line, err}
+	}()
+	select {
+	case got := <-read:
+		return got.line, got.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// readLine reads up to a newline one byte at a time so no input beyond the
+// entered line is consumed from the terminal.
+func readLine(in io.Reader) (string, error) {
+	var b strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				break
+			}
+			b.WriteByte(buf[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(b.String(), "\r"), nil
+}
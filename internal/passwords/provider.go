@@ -0,0 +1,257 @@
+package passwords
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/arodd/go-unrarall/internal/finder"
+)
+
+// Target identifies the archive set passwords are resolved for.
+type Target struct {
+	ArchivePath string
+	Stem        string
+	// Context, when set, stops providers that run commands for this archive
+	// once done. It takes precedence over a provider's own Context.
+	Context context.Context
+}
+
+// TargetFor builds a Target for archivePath, deriving the set stem from the
+// first-volume naming rules used by candidate discovery.
+func TargetFor(archivePath string) Target {
+	base := filepath.Base(archivePath)
+	_, stem := finder.IsFirstVolume(base)
+	if stem == "" {
+		stem = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return Target{
+		ArchivePath: archivePath,
+		Stem:        stem,
+	}
+}
+
+// Dir returns the directory containing the archive.
+func (t Target) Dir() string {
+	return filepath.Dir(t.ArchivePath)
+}
+
+// Group returns the scene release group encoded as the last "-" separated
+// token of the stem (for example "GRP" in "Title.2020.1080p-GRP"), or an
+// empty string when the stem carries no group.
+func (t Target) Group() string {
+	stem := filenamePasswordRe.ReplaceAllString(t.Stem, "")
+	idx := strings.LastIndexByte(stem, '-')
+	if idx < 0 || idx == len(stem)-1 {
+		return ""
+	}
+	group := stem[idx+1:]
+	if strings.ContainsAny(group, ". ") {
+		return ""
+	}
+	return group
+}
+
+// Provider resolves candidate passwords for an archive set.
+type Provider interface {
+	// Name describes the provider in logs and errors. It never contains
+	// password material.
+	Name() string
+	// Passwords returns candidate passwords for target in priority order.
+	Passwords(target Target) ([]string, error)
+}
+
+// Chain is an ordered list of providers. Earlier providers take priority.
+type Chain []Provider
+
+// Candidate is a password together with the provider that supplied it.
+type Candidate struct {
+	Password string
+	Source   string
+}
+
+// Candidates collects de-duplicated passwords from every provider in chain
+// order. Provider failures do not stop collection; they are joined into the
+// returned error so callers can report them when no password works.
+func (c Chain) Candidates(target Target) ([]Candidate, error) {
+	out := make([]Candidate, 0, 8)
+	err := c.Resolve(target, func(batch []Candidate) bool {
+		out = append(out, batch...)
+		return true
+	})
+	return out, err
+}
+
+// Prefer returns the chain with the provider named name moved to the front,
+// or c itself when no provider has that name.
+func (c Chain) Prefer(name string) Chain {
+	for i, provider := range c {
+		if i > 0 && provider.Name() == name {
+			out := make(Chain, 0, len(c))
+			out = append(out, provider)
+			out = append(out, c[:i]...)
+			return append(out, c[i+1:]...)
+		}
+	}
+	return c
+}
+
+// Resolve runs the providers in chain order and hands yield the passwords
+// each one adds, skipping those an earlier provider supplied. A provider
+// only runs when yield returned true for the one before it, so costly
+// sources such as a password command or a vault are not consulted once a
+// cheaper source has a working password. Provider failures are joined into
+// the returned error.
+func (c Chain) Resolve(target Target, yield func(batch []Candidate) bool) error {
+	seen := make(map[string]struct{})
+
+	var errs []error
+	for _, provider := range c {
+		values, err := provider.Passwords(target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		}
+		var batch []Candidate
+		for _, value := range values {
+			if _, ok := seen[value]; ok {
+				continue
+			}
+			seen[value] = struct{}{}
+			batch = append(batch, Candidate{Password: value, Source: provider.Name()})
+		}
+		if len(batch) > 0 && !yield(batch) {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Config selects the providers built by DefaultChain.
+type Config struct {
+	// PasswordFile is the global one-password-per-line file.
+	PasswordFile string
+	// MappingFile optionally maps path, stem, and group patterns to passwords.
+	MappingFile string
+	// EnvVar optionally names an environment variable holding passwords.
+	EnvVar string
+	// Command optionally names a shell command printing passwords.
+	Command string
+	// Passphrase unlocks PasswordFile when it is an encrypted vault.
+	Passphrase func() (string, error)
+	// Context, when set, kills the password command once done.
+	Context context.Context
+}
+
+// DefaultChain returns the standard provider order:
+//
+//  1. passwords embedded in the archive name (name{{password}}.rar);
+//  2. a password.txt sidecar next to the archive;
+//  3. mapping file matches;
+//  4. the password environment variable;
+//  5. password command output;
+//  6. .unrar_passwords files found walking up from the archive directory;
+//  7. the global password file or vault.
+func DefaultChain(cfg Config) Chain {
+	// Sidecar and walk-up files may be vaults too, often the password file
+	// itself, so every file source shares one passphrase and vault cache.
+	vault := &vaultCache{}
+	chain := Chain{
+		FilenameProvider{},
+		SidecarProvider{FileName: SidecarFileName, Passphrase: cfg.Passphrase, vault: vault},
+	}
+	if strings.TrimSpace(cfg.MappingFile) != "" {
+		chain = append(chain, MappingProvider{Path: cfg.MappingFile, rules: &mappingCache{}})
+	}
+	if strings.TrimSpace(cfg.EnvVar) != "" {
+		chain = append(chain, EnvProvider{Var: cfg.EnvVar})
+	}
+	if strings.TrimSpace(cfg.Command) != "" {
+		chain = append(chain, CommandProvider{Command: cfg.Command, Context: cfg.Context})
+	}
+	chain = append(chain, WalkUpProvider{FileName: WalkUpFileName, Passphrase: cfg.Passphrase, vault: vault})
+	if strings.TrimSpace(cfg.PasswordFile) != "" {
+		chain = append(chain, FileProvider{Path: cfg.PasswordFile, Passphrase: cfg.Passphrase, vault: vault})
+	}
+	return chain
+}
+
+// FileProvider reads one password per line from Path, or decrypts Path with
+// Passphrase when it is an encrypted vault. The chain from DefaultChain
+// decrypts a vault once and keeps its passwords for the run.
+type FileProvider struct {
+	Path       string
+	Passphrase func() (string, error)
+	vault      *vaultCache
+}
+
+// Name implements Provider.
+func (p FileProvider) Name() string {
+	return fmt.Sprintf("password file %q", p.Path)
+}
+
+// Passwords implements Provider.
+func (p FileProvider) Passwords(Target) ([]string, error) {
+	if strings.TrimSpace(p.Path) == "" {
+		return nil, fmt.Errorf("password file path is empty")
+	}
+	return readPasswordSource(p.Path, p.Passphrase, p.vault)
+}
+
+// ReadFile reads a one-password-per-line file. Blank lines are ignored and
+// surrounding spaces are preserved as part of the password.
+func ReadFile(path string) ([]string, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("password file path is empty")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(string(data)), nil
+}
+
+// ReadLines reads one password per line from r, ignoring blank lines.
+func ReadLines(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(string(data)), nil
+}
+
+// ExposedPlaintextFile reports whether path is a plaintext password file that
+// group or other users can read. Vaults, missing files, and platforms without
+// POSIX permissions are never reported.
+func ExposedPlaintextFile(path string) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o044 == 0 {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return !IsVault(data)
+}
+
+// readOptionalSource is readPasswordSource that treats a missing file as
+// empty.
+func readOptionalSource(path string, passphrase func() (string, error), cache *vaultCache) ([]string, error) {
+	passwords, err := readPasswordSource(path, passphrase, cache)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return passwords, nil
+}
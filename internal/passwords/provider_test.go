@@ -0,0 +1,187 @@
+package passwords
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type staticProvider struct {
+	name   string
+	values []string
+	err    error
+}
+
+func (p staticProvider) Name() string { return p.name }
+
+func (p staticProvider) Passwords(Target) ([]string, error) { return p.values, p.err }
+
+func TestReadFile(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	path := filepath.Join(root, "passwords.txt")
+	if err := os.WriteFile(path, []byte("alpha\r\n\n beta \n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	passwords, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	want := []string{"alpha", " beta "}
+	if !reflect.DeepEqual(passwords, want) {
+		t.Fatalf("passwords=%v, want %v", passwords, want)
+	}
+}
+
+func TestTargetForDerivesStemAndGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		path  string
+		stem  string
+		group string
+	}{
+		{name: "part set", path: "/dl/Show.S01E01.720p-GRP.part01.rar", stem: "Show.S01E01.720p-GRP", group: "GRP"},
+		{name: "numeric set", path: "/dl/pack-TEAM.001", stem: "pack-TEAM", group: "TEAM"},
+		{name: "no group", path: "/dl/holiday.photos.rar", stem: "holiday.photos", group: ""},
+		{name: "dash inside words", path: "/dl/my-holiday.photos.rar", stem: "my-holiday.photos", group: ""},
+		{name: "embedded password", path: "/dl/Title-GRP{{s3cret}}.rar", stem: "Title-GRP{{s3cret}}", group: "GRP"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			target := TargetFor(tc.path)
+			if target.Stem != tc.stem {
+				t.Fatalf("Stem=%q, want %q", target.Stem, tc.stem)
+			}
+			if got := target.Group(); got != tc.group {
+				t.Fatalf("Group()=%q, want %q", got, tc.group)
+			}
+		})
+	}
+}
+
+func TestChainCandidatesDeduplicatesInPriorityOrder(t *testing.T) {
+	t.Parallel()
+
+	chain := Chain{
+		staticProvider{name: "first", values: []string{"a", "b"}},
+		staticProvider{name: "broken", values: []string{"c"}, err: errors.New("unreadable")},
+		staticProvider{name: "last", values: []string{"b", "d"}},
+	}
+
+	candidates, err := chain.Candidates(Target{ArchivePath: "/dl/release.rar", Stem: "release"})
+	want := []Candidate{
+		{Password: "a", Source: "first"},
+		{Password: "b", Source: "first"},
+		{Password: "c", Source: "broken"},
+		{Password: "d", Source: "last"},
+	}
+	if !reflect.DeepEqual(candidates, want) {
+		t.Fatalf("candidates=%v, want %v", candidates, want)
+	}
+	if err == nil || !strings.Contains(err.Error(), "broken: unreadable") {
+		t.Fatalf("expected provider error to be reported, got %v", err)
+	}
+}
+
+type failingProvider struct{ t *testing.T }
+
+func (p failingProvider) Name() string { return "costly" }
+
+func (p failingProvider) Passwords(Target) ([]string, error) {
+	p.t.Fatal("provider ran after an earlier batch was accepted")
+	return nil, nil
+}
+
+func TestChainResolveStopsOnceYieldIsSatisfied(t *testing.T) {
+	t.Parallel()
+
+	chain := Chain{
+		staticProvider{name: "empty"},
+		staticProvider{name: "first", values: []string{"a"}},
+		staticProvider{name: "second", values: []string{"a", "b"}},
+		failingProvider{t: t},
+	}
+
+	var batches [][]Candidate
+	err := chain.Resolve(Target{ArchivePath: "/dl/release.rar", Stem: "release"}, func(batch []Candidate) bool {
+		batches = append(batches, batch)
+		return len(batches) < 2
+	})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	want := [][]Candidate{
+		{{Password: "a", Source: "first"}},
+		{{Password: "b", Source: "second"}},
+	}
+	if !reflect.DeepEqual(batches, want) {
+		t.Fatalf("batches=%v, want %v", batches, want)
+	}
+}
+
+func TestDefaultChainPriority(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	archiveDir := filepath.Join(root, "downloads", "release")
+	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+		t.Fatalf("mkdir archive dir: %v", err)
+	}
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %q: %v", path, err)
+		}
+	}
+
+	globalFile := filepath.Join(root, "global.txt")
+	mapFile := filepath.Join(root, "map.txt")
+	write(globalFile, "global\nshared\n")
+	write(mapFile, "group:grp mapped\nstem:other.* unrelated\n")
+	write(filepath.Join(archiveDir, SidecarFileName), "sidecar\n")
+	write(filepath.Join(archiveDir, WalkUpFileName), "near\n")
+	write(filepath.Join(root, "downloads", WalkUpFileName), "far\nshared\n")
+
+	chain := DefaultChain(Config{PasswordFile: globalFile, MappingFile: mapFile})
+	target := TargetFor(filepath.Join(archiveDir, "Title-GRP{{inline}}.part01.rar"))
+
+	candidates, err := chain.Candidates(target)
+	if err != nil {
+		t.Fatalf("Candidates returned error: %v", err)
+	}
+
+	got := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		got = append(got, candidate.Password)
+	}
+	want := []string{"inline", "sidecar", "mapped", "near", "far", "shared", "global"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("passwords=%v, want %v", got, want)
+	}
+}
+
+func TestDefaultChainSkipsMissingOptionalFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	chain := DefaultChain(Config{})
+	candidates, err := chain.Candidates(TargetFor(filepath.Join(root, "release.rar")))
+	if err != nil {
+		t.Fatalf("Candidates returned error: %v", err)
+	}
+	if len(candidates) != 0 {
+		t.Fatalf("candidates=%v, want none", candidates)
+	}
+}
@@ -0,0 +1,97 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	// SidecarFileName is the per-directory password file read next to archives.
+	SidecarFileName = "password.txt"
+	// WalkUpFileName is the password file name searched for in the archive
+	// directory and each of its parents.
+	WalkUpFileName = ".unrar_passwords"
+)
+
+var filenamePasswordRe = regexp.MustCompile(`\{\{(.+?)\}\}`)
+
+// FilenameProvider extracts passwords embedded in archive names using the
+// name{{password}}.rar convention.
+type FilenameProvider struct{}
+
+// Name implements Provider.
+func (FilenameProvider) Name() string {
+	return "archive name"
+}
+
+// Passwords implements Provider.
+func (FilenameProvider) Passwords(target Target) ([]string, error) {
+	matches := filenamePasswordRe.FindAllStringSubmatch(filepath.Base(target.ArchivePath), -1)
+	out := make([]string, 0, len(matches))
+	for _, match := range matches {
+		out = append(out, match[1])
+	}
+	return out, nil
+}
+
+// SidecarProvider reads FileName from the archive directory when present,
+// decrypting it with Passphrase when it is an encrypted vault.
+type SidecarProvider struct {
+	FileName   string
+	Passphrase func() (string, error)
+	vault      *vaultCache
+}
+
+// Name implements Provider.
+func (p SidecarProvider) Name() string {
+	return fmt.Sprintf("sidecar %s", p.FileName)
+}
+
+// Passwords implements Provider.
+func (p SidecarProvider) Passwords(target Target) ([]string, error) {
+	return readOptionalSource(filepath.Join(target.Dir(), p.FileName), p.Passphrase, p.vault)
+}
+
+// WalkUpProvider reads FileName from the archive directory and every parent
+// directory up to the filesystem root, nearest directory first. Files that
+// are encrypted vaults, such as a default password file turned into one,
+// are decrypted with Passphrase.
+type WalkUpProvider struct {
+	FileName   string
+	Passphrase func() (string, error)
+	vault      *vaultCache
+}
+
+// Name implements Provider.
+func (p WalkUpProvider) Name() string {
+	return fmt.Sprintf("parent %s files", p.FileName)
+}
+
+// Passwords implements Provider.
+func (p WalkUpProvider) Passwords(target Target) ([]string, error) {
+	dir, err := filepath.Abs(target.Dir())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out  []string
+		errs []error
+	)
+	for {
+		path := filepath.Join(dir, p.FileName)
+		values, err := readOptionalSource(path, p.Passphrase, p.vault)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+		out = append(out, values...)
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return out, errors.Join(errs...)
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,188 @@
+package passwords
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+const (
+	storeVersion  = 1
+	storeSaltSize = 32
+	// StoreFileName is the store file name inside the unrarall state directory.
+	StoreFileName = "passwords.json"
+)
+
+// DefaultStorePath returns the default location of the password store, or an
+// empty string when no state directory is available.
+func DefaultStorePath() string {
+	return fsutil.StatePath(StoreFileName)
+}
+
+// Store remembers which password opened which archive set and how often each
+// password succeeds. Passwords are never written to disk: they are recorded as
+// HMAC-SHA256 fingerprints keyed with a random per-store salt.
+type Store struct {
+	path string
+
+	mu   sync.Mutex
+	data storeData
+}
+
+type storeData struct {
+	Version int `json:"version"`
+	// Salt is the hex-encoded HMAC key used for fingerprints.
+	Salt string `json:"salt"`
+	// Sets maps archive set keys to the fingerprint of the password that
+	// last opened them.
+	Sets map[string]string `json:"sets"`
+	// Successes counts successful extractions per fingerprint.
+	Successes map[string]int `json:"successes"`
+	// Sources maps archive set keys to the name of the provider whose
+	// password last opened them.
+	Sources map[string]string `json:"sources,omitempty"`
+}
+
+// OpenStore loads the store at path. A missing file yields an empty store that
+// is created on the first Remember call.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, fmt.Errorf("parse password store %q: %w", path, err)
+		}
+		if s.data.Version != storeVersion {
+			return nil, fmt.Errorf("password store %q has unsupported version %d", path, s.data.Version)
+		}
+		if _, err := hex.DecodeString(s.data.Salt); err != nil || s.data.Salt == "" {
+			return nil, fmt.Errorf("password store %q has an invalid salt", path)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		salt := make([]byte, storeSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		s.data = storeData{Version: storeVersion, Salt: hex.EncodeToString(salt)}
+	default:
+		return nil, err
+	}
+
+	if s.data.Sets == nil {
+		s.data.Sets = make(map[string]string)
+	}
+	if s.data.Successes == nil {
+		s.data.Successes = make(map[string]int)
+	}
+	if s.data.Sources == nil {
+		s.data.Sources = make(map[string]string)
+	}
+	return s, nil
+}
+
+// Order returns candidates reordered so the password remembered for target
+// comes first, followed by the remaining candidates by descending success
+// count. Candidates with equal counts keep their original priority order.
+func (s *Store) Order(target Target, candidates []Candidate) []Candidate {
+	if s == nil || len(candidates) < 2 {
+		return candidates
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remembered := s.data.Sets[setKey(target)]
+	type ranked struct {
+		candidate  Candidate
+		remembered bool
+		successes  int
+	}
+	ranks := make([]ranked, 0, len(candidates))
+	for _, candidate := range candidates {
+		fingerprint := s.fingerprint(candidate.Password)
+		ranks = append(ranks, ranked{
+			candidate:  candidate,
+			remembered: remembered != "" && fingerprint == remembered,
+			successes:  s.data.Successes[fingerprint],
+		})
+	}
+
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].remembered != ranks[j].remembered {
+			return ranks[i].remembered
+		}
+		return ranks[i].successes > ranks[j].successes
+	})
+
+	out := make([]Candidate, 0, len(ranks))
+	for _, rank := range ranks {
+		out = append(out, rank.candidate)
+	}
+	return out
+}
+
+// Source returns the name of the provider whose password last opened
+// target, or an empty string when none is remembered. Resolving that
+// provider first lets the remembered password be tried before the rest of
+// the chain runs; see Chain.Prefer.
+func (s *Store) Source(target Target) string {
+	if s == nil {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Sources[setKey(target)]
+}
+
+// Remember records that the candidate password opened target and persists
+// the store.
+func (s *Store) Remember(target Target, candidate Candidate) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fingerprint := s.fingerprint(candidate.Password)
+	s.data.Sets[setKey(target)] = fingerprint
+	s.data.Successes[fingerprint]++
+	if candidate.Source != "" {
+		s.data.Sources[setKey(target)] = candidate.Source
+	} else {
+		delete(s.data.Sources, setKey(target))
+	}
+
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(s.path, append(raw, '\n'), 0o600)
+}
+
+func (s *Store) fingerprint(password string) string {
+	key, _ := hex.DecodeString(s.data.Salt)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func setKey(target Target) string {
+	if abs, err := filepath.Abs(target.ArchivePath); err == nil {
+		return filepath.Clean(abs)
+	}
+	return filepath.Clean(target.ArchivePath)
+}
@@ -0,0 +1,146 @@
+package passwords
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStoreOrderPrefersRememberedThenMostSuccessful(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", StoreFileName)
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+
+	seriesA := Target{ArchivePath: "/dl/a.rar", Stem: "a"}
+	seriesB := Target{ArchivePath: "/dl/b.rar", Stem: "b"}
+	seriesC := Target{ArchivePath: "/dl/c.rar", Stem: "c"}
+	for _, step := range []struct {
+		target   Target
+		password string
+	}{
+		{seriesA, "popular"},
+		{seriesB, "popular"},
+		{seriesC, "rare"},
+	} {
+		if err := store.Remember(step.target, Candidate{Password: step.password, Source: "file"}); err != nil {
+			t.Fatalf("Remember returned error: %v", err)
+		}
+	}
+
+	candidates := []Candidate{
+		{Password: "first", Source: "file"},
+		{Password: "rare", Source: "file"},
+		{Password: "popular", Source: "file"},
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+
+	got := passwordsOf(reopened.Order(seriesC, candidates))
+	if want := []string{"rare", "popular", "first"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order for remembered set=%v, want %v", got, want)
+	}
+
+	got = passwordsOf(reopened.Order(Target{ArchivePath: "/dl/new.rar", Stem: "new"}, candidates))
+	if want := []string{"popular", "rare", "first"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order for new set=%v, want %v", got, want)
+	}
+}
+
+func TestStoreRemembersSourceOfWinningPassword(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), StoreFileName)
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	target := Target{ArchivePath: "/dl/a.rar", Stem: "a"}
+	if got := store.Source(target); got != "" {
+		t.Fatalf("Source=%q before any success, want none", got)
+	}
+	if err := store.Remember(target, Candidate{Password: "secret", Source: "vault"}); err != nil {
+		t.Fatalf("Remember returned error: %v", err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	if got := reopened.Source(target); got != "vault" {
+		t.Fatalf("Source=%q, want vault", got)
+	}
+}
+
+func TestStoreNeverPersistsPasswords(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), StoreFileName)
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore returned error: %v", err)
+	}
+	if err := store.Remember(Target{ArchivePath: "/dl/a.rar", Stem: "a"}, Candidate{Password: "plaintext-secret", Source: "file"}); err != nil {
+		t.Fatalf("Remember returned error: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	if strings.Contains(string(raw), "plaintext-secret") {
+		t.Fatalf("store contains plaintext password: %s", raw)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat store: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("store permissions=%o, want 600", perm)
+	}
+}
+
+func TestNilStoreIsNoop(t *testing.T) {
+	t.Parallel()
+
+	var store *Store
+	candidates := []Candidate{{Password: "b"}, {Password: "a"}}
+	if got := store.Order(Target{}, candidates); !reflect.DeepEqual(got, candidates) {
+		t.Fatalf("nil store reordered candidates: %v", got)
+	}
+	if err := store.Remember(Target{}, Candidate{Password: "a"}); err != nil {
+		t.Fatalf("nil store Remember returned error: %v", err)
+	}
+	if got := store.Source(Target{}); got != "" {
+		t.Fatalf("nil store Source=%q, want none", got)
+	}
+}
+
+func TestOpenStoreRejectsCorruptFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), StoreFileName)
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("write store: %v", err)
+	}
+	if _, err := OpenStore(path); err == nil {
+		t.Fatal("expected corrupt store error")
+	}
+}
+
+func passwordsOf(candidates []Candidate) []string {
+	out := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		out = append(out, candidate.Password)
+	}
+	return out
+}
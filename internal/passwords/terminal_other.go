@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package passwords
+
+import "os"
+
+func disableEcho(*os.File) (func() error, error) {
+	return nil, errNoEchoUnsupported
+}
@@ -0,0 +1,36 @@
+//go:build linux || darwin
+
+package passwords
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// disableEcho turns off terminal echo on f and returns a func restoring the
+// previous settings.
+func disableEcho(f *os.File) (func() error, error) {
+	fd := f.Fd()
+	var saved syscall.Termios
+	if err := termiosIoctl(fd, ioctlGetTermios, &saved); err != nil {
+		return nil, err
+	}
+	silent := saved
+	silent.Lflag &^= syscall.ECHO
+	silent.Lflag |= syscall.ICANON | syscall.ISIG
+	if err := termiosIoctl(fd, ioctlSetTermios, &silent); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return termiosIoctl(fd, ioctlSetTermios, &saved)
+	}, nil
+}
+
+func termiosIoctl(fd, request uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
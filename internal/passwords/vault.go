@@ -0,0 +1,213 @@
+package passwords
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+const (
+	vaultFormat     = "unrarall-vault"
+	vaultVersion    = 1
+	vaultKDF        = "pbkdf2-sha256"
+	vaultIterations = 600_000
+	vaultSaltSize   = 16
+	vaultKeySize    = 32
+)
+
+// ErrVaultPassphrase is returned when a vault cannot be decrypted, which
+// almost always means the passphrase is wrong.
+var ErrVaultPassphrase = errors.New("wrong vault passphrase or corrupted vault")
+
+// vaultFile is the on-disk vault envelope. Everything except Ciphertext is
+// authenticated as additional data, so header tampering fails decryption.
+type vaultFile struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// IsVault reports whether data is an encrypted password vault rather than a
+// plaintext one-password-per-line file.
+func IsVault(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+	var header struct {
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal(trimmed, &header); err != nil {
+		return false
+	}
+	return header.Format == vaultFormat
+}
+
+// SealVault encrypts passwords with AES-256-GCM under a key derived from
+// passphrase with PBKDF2-SHA256 and a fresh random salt.
+func SealVault(passwords []string, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("vault passphrase is empty")
+	}
+	if passwords == nil {
+		passwords = []string{}
+	}
+
+	vault := vaultFile{
+		Format:     vaultFormat,
+		Version:    vaultVersion,
+		KDF:        vaultKDF,
+		Iterations: vaultIterations,
+		Salt:       make([]byte, vaultSaltSize),
+	}
+	if _, err := rand.Read(vault.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := vault.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	vault.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(vault.Nonce); err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(passwords)
+	if err != nil {
+		return nil, err
+	}
+	vault.Ciphertext = aead.Seal(nil, vault.Nonce, plaintext, vault.additionalData())
+
+	out, err := json.MarshalIndent(vault, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// OpenVault decrypts a vault produced by SealVault.
+func OpenVault(data []byte, passphrase string) ([]string, error) {
+	var vault vaultFile
+	if err := json.Unmarshal(data, &vault); err != nil {
+		return nil, fmt.Errorf("parse vault: %w", err)
+	}
+	if vault.Format != vaultFormat {
+		return nil, errors.New("not a password vault")
+	}
+	if vault.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported vault version %d", vault.Version)
+	}
+	if vault.KDF != vaultKDF || vault.Iterations <= 0 {
+		return nil, fmt.Errorf("unsupported vault key derivation %q", vault.KDF)
+	}
+
+	aead, err := vault.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(vault.Nonce) != aead.NonceSize() {
+		return nil, ErrVaultPassphrase
+	}
+	plaintext, err := aead.Open(nil, vault.Nonce, vault.Ciphertext, vault.additionalData())
+	if err != nil {
+		return nil, ErrVaultPassphrase
+	}
+
+	var passwords []string
+	if err := json.Unmarshal(plaintext, &passwords); err != nil {
+		return nil, fmt.Errorf("parse vault contents: %w", err)
+	}
+	return passwords, nil
+}
+
+// SaveVault seals passwords and atomically writes the vault to path with mode
+// 0600.
+func SaveVault(path string, passwords []string, passphrase string) error {
+	data, err := SealVault(passwords, passphrase)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0o600)
+}
+
+func (v vaultFile) aead(passphrase string) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, v.Salt, v.Iterations, vaultKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v vaultFile) additionalData() []byte {
+	header := fmt.Sprintf("%s/%d/%s/%d/", v.Format, v.Version, v.KDF, v.Iterations)
+	return append([]byte(header), v.Salt...)
+}
+
+// readPasswordSource reads path as either a vault or a plaintext password
+// file. passphrase is only called for vaults, which are decrypted through
+// cache when it is non-nil.
+func readPasswordSource(path string, passphrase func() (string, error), cache *vaultCache) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !IsVault(data) {
+		return splitLines(string(data)), nil
+	}
+	if cache != nil {
+		return cache.open(data, passphrase)
+	}
+	return decryptVault(data, passphrase)
+}
+
+// vaultCache keeps the passwords of the last vault decrypted, keyed by its
+// encrypted contents, so every archive of a run does not pay for the key
+// derivation again. A vault rewritten during the run is decrypted anew.
+type vaultCache struct {
+	mu        sync.Mutex
+	data      []byte
+	passwords []string
+}
+
+func (c *vaultCache) open(data []byte, passphrase func() (string, error)) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data == nil || !bytes.Equal(c.data, data) {
+		passwords, err := decryptVault(data, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		c.data, c.passwords = data, passwords
+	}
+	return slices.Clone(c.passwords), nil
+}
+
+func decryptVault(data []byte, passphrase func() (string, error)) ([]string, error) {
+	if passphrase == nil {
+		return nil, errors.New("file is an encrypted vault and no passphrase source is configured")
+	}
+	secret, err := passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("vault passphrase: %w", err)
+	}
+	return OpenVault(data, secret)
+}
@@ -0,0 +1,233 @@
+package passwords
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	want := []string{"first", " spaced ", "ünïcode"}
+	data, err := SealVault(want, "correct horse")
+	if err != nil {
+		t.Fatalf("SealVault returned error: %v", err)
+	}
+	if !IsVault(data) {
+		t.Fatal("sealed data not recognized as a vault")
+	}
+	for _, password := range want {
+		if strings.Contains(string(data), password) {
+			t.Fatalf("vault contains plaintext password %q", password)
+		}
+	}
+
+	got, err := OpenVault(data, "correct horse")
+	if err != nil {
+		t.Fatalf("OpenVault returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("OpenVault=%v, want %v", got, want)
+	}
+
+	if _, err := OpenVault(data, "wrong horse"); !errors.Is(err, ErrVaultPassphrase) {
+		t.Fatalf("OpenVault with wrong passphrase error=%v, want ErrVaultPassphrase", err)
+	}
+}
+
+func TestIsVaultRejectsPlaintextFiles(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{"", "secret\n", "{not json}\n", `{"format":"other"}`} {
+		if IsVault([]byte(data)) {
+			t.Fatalf("IsVault(%q)=true, want false", data)
+		}
+	}
+}
+
+func TestFileProviderDecryptsVault(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "passwords.vault")
+	if err := SaveVault(path, []string{"vaulted"}, "pass"); err != nil {
+		t.Fatalf("SaveVault returned error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat vault: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Fatalf("vault mode=%v, want 0600", info.Mode().Perm())
+	}
+
+	calls := 0
+	provider := FileProvider{Path: path, Passphrase: func() (string, error) {
+		calls++
+		return "pass", nil
+	}}
+	got, err := provider.Passwords(Target{})
+	if err != nil {
+		t.Fatalf("Passwords returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"vaulted"}) || calls != 1 {
+		t.Fatalf("Passwords=%v calls=%d, want [vaulted] after one passphrase call", got, calls)
+	}
+
+	if _, err := (FileProvider{Path: path}).Passwords(Target{}); err == nil {
+		t.Fatal("expected error for vault without passphrase source")
+	}
+}
+
+func TestDefaultChainDecryptsVaultOncePerContents(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "passwords.vault")
+	if err := SaveVault(path, []string{"first"}, "pass"); err != nil {
+		t.Fatalf("SaveVault returned error: %v", err)
+	}
+	calls := 0
+	chain := DefaultChain(Config{PasswordFile: path, Passphrase: func() (string, error) {
+		calls++
+		return "pass", nil
+	}})
+	provider := chain[len(chain)-1]
+
+	for range 3 {
+		got, err := provider.Passwords(Target{})
+		if err != nil || !reflect.DeepEqual(got, []string{"first"}) {
+			t.Fatalf("Passwords=%v, %v, want [first]", got, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("decrypted %d times, want once", calls)
+	}
+
+	if err := SaveVault(path, []string{"second"}, "pass"); err != nil {
+		t.Fatalf("SaveVault returned error: %v", err)
+	}
+	got, err := provider.Passwords(Target{})
+	if err != nil || !reflect.DeepEqual(got, []string{"second"}) || calls != 2 {
+		t.Fatalf("Passwords=%v, %v after %d decryptions, want the rewritten vault decrypted again", got, err, calls)
+	}
+}
+
+func TestDefaultChainDecryptsVaultsAtWalkUpLocations(t *testing.T) {
+	t.Parallel()
+
+	home := t.TempDir()
+	vaultPath := filepath.Join(home, WalkUpFileName)
+	if err := SaveVault(vaultPath, []string{"vaulted"}, "pass"); err != nil {
+		t.Fatalf("SaveVault returned error: %v", err)
+	}
+	dir := filepath.Join(home, "downloads", "set")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("create archive directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, SidecarFileName), []byte("sidecar\n"), 0o600); err != nil {
+		t.Fatalf("write sidecar: %v", err)
+	}
+	target := TargetFor(filepath.Join(dir, "release.rar"))
+
+	calls := 0
+	chain := DefaultChain(Config{PasswordFile: vaultPath, Passphrase: func() (string, error) {
+		calls++
+		return "pass", nil
+	}})
+	candidates, err := chain.Candidates(target)
+	if err != nil {
+		t.Fatalf("Candidates returned error: %v", err)
+	}
+	var got []string
+	for _, candidate := range candidates {
+		got = append(got, candidate.Password)
+	}
+	if want := []string{"sidecar", "vaulted"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("passwords=%q, want %q", got, want)
+	}
+	if calls != 1 {
+		t.Fatalf("decrypted %d times, want once for the walk-up file and the password file", calls)
+	}
+
+	got, err = WalkUpProvider{FileName: WalkUpFileName}.Passwords(target)
+	if err == nil || len(got) != 0 {
+		t.Fatalf("Passwords=%q, %v, want the vault reported rather than tried", got, err)
+	}
+}
+
+func TestExposedPlaintextFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions only")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plain, []byte("secret\n"), 0o644); err != nil {
+		t.Fatalf("write plaintext: %v", err)
+	}
+	if err := os.Chmod(plain, 0o644); err != nil {
+		t.Fatalf("chmod plaintext: %v", err)
+	}
+	if !ExposedPlaintextFile(plain) {
+		t.Fatal("expected 0644 plaintext file to be reported")
+	}
+	if err := os.Chmod(plain, 0o600); err != nil {
+		t.Fatalf("chmod plaintext: %v", err)
+	}
+	if ExposedPlaintextFile(plain) {
+		t.Fatal("did not expect 0600 plaintext file to be reported")
+	}
+
+	vault := filepath.Join(dir, "vault")
+	data, err := SealVault([]string{"secret"}, "pass")
+	if err != nil {
+		t.Fatalf("SealVault returned error: %v", err)
+	}
+	if err := os.WriteFile(vault, data, 0o644); err != nil {
+		t.Fatalf("write vault: %v", err)
+	}
+	if err := os.Chmod(vault, 0o644); err != nil {
+		t.Fatalf("chmod vault: %v", err)
+	}
+	if ExposedPlaintextFile(vault) {
+		t.Fatal("did not expect readable vault to be reported")
+	}
+}
+
+func TestResolvePassphraseSources(t *testing.T) {
+	t.Setenv(VaultPassphraseEnv, "")
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	if _, err := writer.WriteString("from-fd\nignored\n"); err != nil {
+		t.Fatalf("write pipe: %v", err)
+	}
+	writer.Close()
+	defer reader.Close()
+
+	got, err := ResolvePassphrase(PassphraseOptions{FD: int(reader.Fd())})
+	if err != nil {
+		t.Fatalf("ResolvePassphrase returned error: %v", err)
+	}
+	if got != "from-fd" {
+		t.Fatalf("passphrase=%q, want %q", got, "from-fd")
+	}
+
+	t.Setenv(VaultPassphraseEnv, "from-env")
+	got, err = ResolvePassphrase(PassphraseOptions{FD: -1})
+	if err != nil || got != "from-env" {
+		t.Fatalf("ResolvePassphrase=%q, %v, want from-env", got, err)
+	}
+
+	t.Setenv(VaultPassphraseEnv, "")
+	if _, err := ResolvePassphrase(PassphraseOptions{FD: -1}); err == nil {
+		t.Fatal("expected error without any passphrase source")
+	}
+}
@@ -0,0 +1,158 @@
+// Package plan describes what a dry run would do: the final path of every
+// archive entry, predicted collision suffixes, files cleanup hooks would
+// delete, and the nested archives extraction would recurse into.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/hooks"
+)
+
+// Plan output formats.
+const (
+	Text = "text"
+	JSON = "json"
+)
+
+// Plan is the document printed by --plan=json.
+type Plan struct {
+	Dir  string `json:"dir"`
+	Sets []*Set `json:"sets"`
+}
+
+// Set is the plan for one archive set.
+type Set struct {
+	// Path is the first volume; for nested sets, the entry name inside
+	// the parent archive.
+	Path        string `json:"path"`
+	Destination string `json:"destination"`
+	// Skip is "journal" for sets a run would skip.
+	Skip string `json:"skip,omitempty"`
+	// Error explains why the set could not be listed, or why extraction
+	// would fail.
+	Error  string         `json:"error,omitempty"`
+	Files  []File         `json:"files"`
+	Nested []*Set         `json:"nested,omitempty"`
+	Hooks  []hooks.Action `json:"hooks,omitempty"`
+}
+
+// File is one entry and the path it would be moved to.
+type File struct {
+	Entry  string `json:"entry"`
+	Target string `json:"target"`
+	Size   int64  `json:"size"`
+	// Renamed is set when Target carries a collision suffix.
+	Renamed bool `json:"renamed,omitempty"`
+	Symlink bool `json:"symlink,omitempty"`
+}
+
+// Recorder collects set plans and the destination names they claim, so
+// sets planned later in the run see earlier sets' files as taken.
+type Recorder struct {
+	mu      sync.Mutex
+	dir     string
+	sets    []*Set
+	claimed map[string]bool
+}
+
+// NewRecorder starts a plan for a run over dir.
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{dir: dir, claimed: make(map[string]bool)}
+}
+
+// Add appends a top-level set to the plan.
+func (r *Recorder) Add(set *Set) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sets = append(r.sets, set)
+}
+
+// Claim predicts the name fsutil.SafeMove would pick for dst: the first of
+// dst, dst.1, dst.2, ... that neither exists nor was claimed earlier in
+// the plan.
+func (r *Recorder) Claim(dst string) (final string, renamed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		candidate := fsutil.SuffixedPath(dst, attempt)
+		if r.claimed[candidate] {
+			continue
+		}
+		if _, err := os.Lstat(candidate); err == nil {
+			continue
+		}
+		r.claimed[candidate] = true
+		return candidate, attempt > 0
+	}
+}
+
+// Plan returns the collected plan.
+func (r *Recorder) Plan() Plan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sets := r.sets
+	if sets == nil {
+		sets = []*Set{}
+	}
+	return Plan{Dir: r.dir, Sets: sets}
+}
+
+// WriteJSON writes p as indented JSON.
+func WriteJSON(w io.Writer, p Plan) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Lines renders set for the text plan, one line per file, nested set, and
+// hook action. The first line names the set and its destination.
+func (s *Set) Lines() []string {
+	lines := []string{fmt.Sprintf("Dry-run: would extract %q to %q", s.Path, s.Destination)}
+	return s.appendDetails(lines, "  ")
+}
+
+func (s *Set) appendDetails(lines []string, indent string) []string {
+	if s.Error != "" {
+		lines = append(lines, fmt.Sprintf("%s! %s", indent, s.Error))
+	}
+	for _, file := range s.Files {
+		line := fmt.Sprintf("%s%s -> %q (%d bytes)", indent, file.Entry, file.Target, file.Size)
+		switch {
+		case file.Renamed:
+			line += " [renamed: name taken]"
+		case file.Symlink:
+			line += " [symlink]"
+		}
+		lines = append(lines, line)
+	}
+	for _, nested := range s.Nested {
+		lines = append(lines, fmt.Sprintf("%snested archive %q would be extracted to %q:", indent, nested.Path, nested.Destination))
+		lines = nested.appendDetails(lines, indent+"  ")
+	}
+	for _, action := range s.Hooks {
+		lines = append(lines, fmt.Sprintf("%shook %s would %s %q", indent, action.Hook, actionVerb(action.Kind), action.Path))
+	}
+	return lines
+}
+
+func actionVerb(kind string) string {
+	switch kind {
+	case "remove_file":
+		return "remove file"
+	case "remove_tree":
+		return "remove directory tree"
+	case "remove_empty_dir":
+		return "remove empty directory"
+	default:
+		return kind
+	}
+}
@@ -0,0 +1,58 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arodd/go-unrarall/internal/hooks"
+)
+
+func TestRecorderClaimSkipsExistingAndClaimedNames(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	dst := filepath.Join(root, "movie.mkv")
+	if err := os.WriteFile(dst, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	r := NewRecorder(root)
+	for _, want := range []string{dst + ".1", dst + ".2"} {
+		got, renamed := r.Claim(dst)
+		if got != want || !renamed {
+			t.Fatalf("Claim=%q, %v, want %q renamed", got, renamed, want)
+		}
+	}
+	other := filepath.Join(root, "movie.nfo")
+	if got, renamed := r.Claim(other); got != other || renamed {
+		t.Fatalf("Claim=%q, %v, want %q unchanged", got, renamed, other)
+	}
+}
+
+func TestSetLines(t *testing.T) {
+	t.Parallel()
+
+	set := &Set{
+		Path:        "/in/a.rar",
+		Destination: "/out",
+		Files:       []File{{Entry: "a.mkv", Target: "/out/a.mkv.1", Size: 5, Renamed: true}},
+		Nested: []*Set{{
+			Path:        "subs.rar",
+			Destination: "/out",
+			Error:       "not listed: the archive is encrypted or too large to read into memory",
+		}},
+		Hooks: []hooks.Action{{Hook: "rar", Kind: "remove_file", Path: "/in/a.rar", DryRun: true}},
+	}
+	want := []string{
+		`Dry-run: would extract "/in/a.rar" to "/out"`,
+		`  a.mkv -> "/out/a.mkv.1" (5 bytes) [renamed: name taken]`,
+		`  nested archive "subs.rar" would be extracted to "/out":`,
+		`    ! not listed: the archive is encrypted or too large to read into memory`,
+		`  hook rar would remove file "/in/a.rar"`,
+	}
+	if got := set.Lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Lines()=%q, want %q", got, want)
+	}
+}
@@ -1,7 +1,9 @@
 package rar
 
 import (
+	"context"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path"
@@ -10,6 +12,7 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
 	"github.com/arodd/go-unrarall/internal/fsutil"
 	"github.com/nwaples/rardecode/v2"
 )
@@ -32,21 +35,29 @@ func ExtractToDir(
 	allowSymlinks bool,
 	opts ...rardecode.Option,
 ) ([]string, error) {
-	return extractToDirWithOpener(openArchiveReader, archivePath, tmpDir, fullPath, allowSymlinks, opts...)
+	return extractToDirWithOpener(context.Background(), openArchiveReader, archivePath, tmpDir, fullPath, allowSymlinks, nil, opts...)
 }
 
 // ExtractToDirWithSettings is a convenience wrapper around ExtractToDir that
-// converts OpenSettings into decoder options.
+// converts OpenSettings into decoder options. Sums of extracted files are
+// recorded in settings.FileSums when it is set, and settings.Context stops
+// extraction between reads.
 func ExtractToDirWithSettings(archivePath, tmpDir string, fullPath bool, settings OpenSettings) ([]string, error) {
-	return ExtractToDir(archivePath, tmpDir, fullPath, settings.AllowSymlinks, settings.DecodeOptions()...)
+	ctx := settings.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return extractToDirWithOpener(ctx, openArchiveReader, archivePath, tmpDir, fullPath, settings.AllowSymlinks, settings.FileSums, settings.DecodeOptions()...)
 }
 
 func extractToDirWithOpener(
+	ctx context.Context,
 	opener openReaderFunc,
 	archivePath string,
 	tmpDir string,
 	fullPath bool,
 	allowSymlinks bool,
+	sums *checksum.FileSums,
 	opts ...rardecode.Option,
 ) ([]string, error) {
 	reader, err := opener(archivePath, opts...)
@@ -55,16 +66,24 @@ func extractToDirWithOpener(
 	}
 	defer reader.Close()
 
-	if err := extractFromArchiveReader(reader, tmpDir, fullPath, allowSymlinks); err != nil {
+	if err := extractFromArchiveReader(ctx, reader, tmpDir, fullPath, allowSymlinks, sums); err != nil {
 		return nil, err
 	}
 	return reader.Volumes(), nil
 }
 
-func extractFromArchiveReader(reader archiveReader, tmpDir string, fullPath bool, allowSymlinks bool) error {
+// extractFromArchiveReader writes every entry of reader under tmpDir. When
+// sums is set, regular files are hashed as they are written and recorded
+// under their tmpDir-relative path. Once ctx is done it stops between reads
+// and returns ctx.Err().
+func extractFromArchiveReader(ctx context.Context, reader archiveReader, tmpDir string, fullPath bool, allowSymlinks bool, sums *checksum.FileSums) error {
 	buf := make([]byte, extractCopyBufferSize)
+	data := fsutil.ContextReader(ctx, reader)
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		header, err := reader.Next()
 		if err == io.EOF {
 			break
@@ -73,7 +92,7 @@ func extractFromArchiveReader(reader archiveReader, tmpDir string, fullPath bool
 			return err
 		}
 
-		relPath, err := entryPath(header, fullPath)
+		relPath, err := EntryTarget(header.Name, header.IsDir, fullPath)
 		if err != nil {
 			return err
 		}
@@ -83,10 +102,10 @@ func extractFromArchiveReader(reader archiveReader, tmpDir string, fullPath bool
 
 		if header.Mode()&os.ModeSymlink != 0 {
 			if !allowSymlinks {
-				return fmt.Errorf(
+				return unsafeEntry(fmt.Errorf(
 					"archive entry %q is a symlink and symlink extraction is disabled (use --allow-symlinks to override)",
 					header.Name,
-				)
+				))
 			}
 			if err := extractSymlink(reader, tmpDir, relPath); err != nil {
 				return fmt.Errorf("extract symlink %q: %w", header.Name, err)
@@ -113,7 +132,15 @@ func extractFromArchiveReader(reader archiveReader, tmpDir string, fullPath bool
 			return err
 		}
 
-		_, copyErr := io.CopyBuffer(out, reader, buf)
+		var (
+			sink io.Writer = out
+			h    hash.Hash
+		)
+		if sums != nil {
+			h = sums.New()
+			sink = io.MultiWriter(out, h)
+		}
+		_, copyErr := io.CopyBuffer(sink, data, buf)
 		syncErr := out.Sync()
 		closeErr := out.Close()
 		if copyErr != nil {
@@ -130,6 +157,9 @@ func extractFromArchiveReader(reader archiveReader, tmpDir string, fullPath bool
 		}
 
 		applyModTime(target, header.ModificationTime)
+		if h != nil {
+			sums.Record(relPath, h.Sum(nil))
+		}
 	}
 	return nil
 }
@@ -142,7 +172,7 @@ func extractSymlink(reader io.Reader, tmpDir, relPath string) error {
 
 	linkTarget, err := sanitizeSymlinkTarget(relPath, targetValue)
 	if err != nil {
-		return err
+		return unsafeEntry(err)
 	}
 
 	linkPath := filepath.Join(tmpDir, relPath)
@@ -202,12 +232,16 @@ func hasDrivePrefix(pathValue string) bool {
 	return unicode.IsLetter(rune(pathValue[0]))
 }
 
-func entryPath(header *rardecode.FileHeader, fullPath bool) (string, error) {
-	normalized := strings.ReplaceAll(header.Name, "\\", "/")
+// EntryTarget returns the path, relative to the extraction root, that the
+// archive entry called name is written to, or "" when extraction skips it
+// (directories in flatten mode). Unsafe names fail with an error matching
+// ErrUnsafeEntry.
+func EntryTarget(name string, isDir, fullPath bool) (string, error) {
+	normalized := strings.ReplaceAll(name, "\\", "/")
 
 	if !fullPath {
 		// Flatten mode mirrors "unrar e": files land at the extraction root.
-		if header.IsDir {
+		if isDir {
 			return "", nil
 		}
 		normalized = path.Base(normalized)
@@ -215,7 +249,7 @@ func entryPath(header *rardecode.FileHeader, fullPath bool) (string, error) {
 
 	sanitized, ok := fsutil.SanitizeRelPath(normalized)
 	if !ok {
-		return "", fmt.Errorf("unsafe path in archive: %q", header.Name)
+		return "", unsafeEntry(fmt.Errorf("unsafe path in archive: %q", name))
 	}
 	return sanitized, nil
 }
@@ -2,6 +2,10 @@ package rar
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
 	"github.com/nwaples/rardecode/v2"
 )
 
@@ -66,7 +71,7 @@ func TestExtractFromArchiveReaderFullPath(t *testing.T) {
 		},
 	}
 
-	if err := extractFromArchiveReader(reader, root, true, false); err != nil {
+	if err := extractFromArchiveReader(context.Background(), reader, root, true, false, nil); err != nil {
 		t.Fatalf("extractFromArchiveReader returned error: %v", err)
 	}
 
@@ -87,6 +92,72 @@ func TestExtractFromArchiveReaderFullPath(t *testing.T) {
 	}
 }
 
+// cancelingReader cancels its context once the entry named at is reached.
+type cancelingReader struct {
+	*fakeArchiveReader
+	at     string
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Next() (*rardecode.FileHeader, error) {
+	header, err := r.fakeArchiveReader.Next()
+	if err == nil && header.Name == r.at {
+		r.cancel()
+	}
+	return header, err
+}
+
+func TestExtractFromArchiveReaderStopsWhenCanceled(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := &cancelingReader{
+		fakeArchiveReader: &fakeArchiveReader{
+			entries: []fakeArchiveEntry{
+				{header: rardecode.FileHeader{Name: "first.txt"}, data: []byte("one")},
+				{header: rardecode.FileHeader{Name: "second.txt"}, data: []byte("two")},
+				{header: rardecode.FileHeader{Name: "third.txt"}, data: []byte("three")},
+			},
+		},
+		at:     "second.txt",
+		cancel: cancel,
+	}
+
+	err := extractFromArchiveReader(ctx, reader, root, true, false, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("extractFromArchiveReader returned %v, want context.Canceled", err)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 || entries[0].Name() != "first.txt" {
+		t.Fatalf("extracted %v, want only the entry finished before cancellation", entries)
+	}
+}
+
+func TestExtractFromArchiveReaderRecordsFileSums(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	reader := &fakeArchiveReader{
+		entries: []fakeArchiveEntry{
+			{header: rardecode.FileHeader{Name: "nested", IsDir: true}},
+			{header: rardecode.FileHeader{Name: "nested/file.txt"}, data: []byte("hello")},
+		},
+	}
+
+	sums := checksum.NewFileSums(checksum.SHA256)
+	if err := extractFromArchiveReader(context.Background(), reader, root, true, false, sums); err != nil {
+		t.Fatalf("extractFromArchiveReader returned error: %v", err)
+	}
+
+	want := sha256.Sum256([]byte("hello"))
+	got := sums.Sums()
+	if len(got) != 1 || !bytes.Equal(got[filepath.Join("nested", "file.txt")], want[:]) {
+		t.Fatalf("sums=%x, want only %s=%x", got, filepath.Join("nested", "file.txt"), want)
+	}
+}
+
 func TestExtractFromArchiveReaderFlatten(t *testing.T) {
 	t.Parallel()
 
@@ -98,7 +169,7 @@ func TestExtractFromArchiveReaderFlatten(t *testing.T) {
 		},
 	}
 
-	if err := extractFromArchiveReader(reader, root, false, false); err != nil {
+	if err := extractFromArchiveReader(context.Background(), reader, root, false, false, nil); err != nil {
 		t.Fatalf("extractFromArchiveReader returned error: %v", err)
 	}
 
@@ -124,7 +195,7 @@ func TestExtractFromArchiveReaderRejectsUnsafePath(t *testing.T) {
 		},
 	}
 
-	err := extractFromArchiveReader(reader, root, true, false)
+	err := extractFromArchiveReader(context.Background(), reader, root, true, false, nil)
 	if err == nil {
 		t.Fatal("expected unsafe path error")
 	}
@@ -149,7 +220,7 @@ func TestExtractFromArchiveReaderRejectsSymlinkEntries(t *testing.T) {
 		},
 	}
 
-	err := extractFromArchiveReader(reader, root, true, false)
+	err := extractFromArchiveReader(context.Background(), reader, root, true, false, nil)
 	if err == nil {
 		t.Fatal("expected symlink rejection error")
 	}
@@ -176,7 +247,7 @@ func TestExtractFromArchiveReaderAllowsSafeSymlinkEntries(t *testing.T) {
 		},
 	}
 
-	if err := extractFromArchiveReader(reader, root, true, true); err != nil {
+	if err := extractFromArchiveReader(context.Background(), reader, root, true, true, nil); err != nil {
 		t.Fatalf("extractFromArchiveReader returned error: %v", err)
 	}
 
@@ -215,7 +286,7 @@ func TestExtractFromArchiveReaderRejectsEscapingSymlinkTarget(t *testing.T) {
 		},
 	}
 
-	err := extractFromArchiveReader(reader, root, true, true)
+	err := extractFromArchiveReader(context.Background(), reader, root, true, true, nil)
 	if err == nil {
 		t.Fatal("expected symlink target validation error")
 	}
@@ -275,7 +346,7 @@ func TestExtractToDirMultiVolumeSets(t *testing.T) {
 				return reader, nil
 			}
 
-			volumes, err := extractToDirWithOpener(opener, tc.archive, root, true, false)
+			volumes, err := extractToDirWithOpener(context.Background(), opener, tc.archive, root, true, false, nil)
 			if err != nil {
 				t.Fatalf("extractToDirWithOpener returned error: %v", err)
 			}
@@ -297,3 +368,26 @@ func TestExtractToDirMultiVolumeSets(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractErrorsMatchErrUnsafeEntry(t *testing.T) {
+	t.Parallel()
+
+	reader := &fakeArchiveReader{
+		entries: []fakeArchiveEntry{
+			{header: rardecode.FileHeader{Name: "../escape.txt"}, data: []byte("boom")},
+		},
+	}
+	err := extractFromArchiveReader(context.Background(), reader, t.TempDir(), true, false, nil)
+	if !errors.Is(err, ErrUnsafeEntry) {
+		t.Fatalf("error=%v, want it to match ErrUnsafeEntry", err)
+	}
+	if IsCorruptionError(err) || IsUnsupportedError(err) {
+		t.Fatalf("unsafe entry error %v classified as corruption or unsupported", err)
+	}
+	if !IsCorruptionError(fmt.Errorf("extract %q: %w", "a.mkv", rardecode.ErrBadFileChecksum)) {
+		t.Fatal("wrapped ErrBadFileChecksum not classified as corruption")
+	}
+	if !IsUnsupportedError(rardecode.ErrDictionaryTooLarge) {
+		t.Fatal("ErrDictionaryTooLarge not classified as unsupported")
+	}
+}
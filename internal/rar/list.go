@@ -1,11 +1,20 @@
 package rar
 
-import "github.com/nwaples/rardecode/v2"
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/nwaples/rardecode/v2"
+)
 
 // ListedFile is a listing entry from a RAR archive.
 type ListedFile struct {
 	Name            string
 	IsDir           bool
+	Symlink         bool
+	Size            int64
 	Encrypted       bool
 	HeaderEncrypted bool
 }
@@ -22,9 +31,96 @@ func ListFiles(path string, opts ...rardecode.Option) ([]ListedFile, error) {
 		out = append(out, ListedFile{
 			Name:            file.Name,
 			IsDir:           file.IsDir,
+			Symlink:         file.Mode()&fs.ModeSymlink != 0,
+			Size:            file.UnPackedSize,
 			Encrypted:       file.Encrypted,
 			HeaderEncrypted: file.HeaderEncrypted,
 		})
 	}
 	return out, nil
 }
+
+// ReadEntries lists the archive at path and returns the contents of the
+// regular files selected by want, keyed by entry name, while their total
+// stays within limit bytes. Volumes are opened from fsys, or from the OS
+// when fsys is nil, and only when they carry a RAR signature. A listing
+// that fails part way returns the entries read so far with the error.
+func ReadEntries(fsys fs.FS, path string, want func(ListedFile) bool, limit int64, opts ...rardecode.Option) ([]ListedFile, map[string][]byte, error) {
+	opts = append(opts, rardecode.FileSystem(signatureCheckedFS{fsys: fsys}))
+	return readEntriesWithOpener(openArchiveReader, path, want, limit, opts...)
+}
+
+func readEntriesWithOpener(opener openReaderFunc, path string, want func(ListedFile) bool, limit int64, opts ...rardecode.Option) ([]ListedFile, map[string][]byte, error) {
+	reader, err := opener(path, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	var (
+		files    []ListedFile
+		contents = make(map[string][]byte)
+	)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files, contents, nil
+		}
+		if err != nil {
+			return files, contents, err
+		}
+		file := ListedFile{
+			Name:            header.Name,
+			IsDir:           header.IsDir,
+			Symlink:         header.Mode()&fs.ModeSymlink != 0,
+			Size:            header.UnPackedSize,
+			Encrypted:       header.Encrypted,
+			HeaderEncrypted: header.HeaderEncrypted,
+		}
+		files = append(files, file)
+		if want == nil || file.IsDir || file.Symlink || header.UnKnownSize || file.Size > limit || !want(file) {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+		if err != nil {
+			return files, contents, err
+		}
+		if int64(len(data)) > limit {
+			continue
+		}
+		contents[header.Name] = data
+		limit -= int64(len(data))
+	}
+}
+
+// MemoryFS serves archive volumes held in memory, keyed by name, so
+// archives read with ReadEntries can be listed in turn without writing
+// them to disk.
+type MemoryFS map[string][]byte
+
+// Open implements fs.FS.
+func (m MemoryFS) Open(name string) (fs.File, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memoryFile{Reader: bytes.NewReader(data), name: name, size: int64(len(data))}, nil
+}
+
+type memoryFile struct {
+	*bytes.Reader
+	name string
+	size int64
+}
+
+func (f *memoryFile) Stat() (fs.FileInfo, error) { return memoryFileInfo{f}, nil }
+func (f *memoryFile) Close() error               { return nil }
+
+type memoryFileInfo struct{ f *memoryFile }
+
+func (i memoryFileInfo) Name() string       { return i.f.name }
+func (i memoryFileInfo) Size() int64        { return i.f.size }
+func (i memoryFileInfo) Mode() fs.FileMode  { return 0o444 }
+func (i memoryFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memoryFileInfo) IsDir() bool        { return false }
+func (i memoryFileInfo) Sys() any           { return nil }
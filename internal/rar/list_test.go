@@ -0,0 +1,60 @@
+package rar
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+func TestReadEntriesKeepsSelectedContentsWithinLimit(t *testing.T) {
+	t.Parallel()
+
+	reader := &fakeArchiveReader{entries: []fakeArchiveEntry{
+		{header: rardecode.FileHeader{Name: "movie.mkv", UnPackedSize: 8}, data: []byte("moviemov")},
+		{header: rardecode.FileHeader{Name: "subs", IsDir: true}},
+		{header: rardecode.FileHeader{Name: "subs/subs.rar", UnPackedSize: 4}, data: []byte("Rar!")},
+		{header: rardecode.FileHeader{Name: "extras.rar", UnPackedSize: 6}, data: []byte("Rar!xx")},
+	}}
+	opener := func(string, ...rardecode.Option) (archiveReadCloser, error) {
+		return reader, nil
+	}
+
+	files, contents, err := readEntriesWithOpener(opener, "set.rar", func(file ListedFile) bool {
+		return strings.HasSuffix(file.Name, ".rar")
+	}, 8)
+	if err != nil {
+		t.Fatalf("readEntriesWithOpener returned error: %v", err)
+	}
+	if len(files) != 4 || files[0].Size != 8 || !files[1].IsDir {
+		t.Fatalf("files=%+v, want all 4 entries with sizes", files)
+	}
+	// The second archive no longer fits in the remaining budget.
+	if len(contents) != 1 || string(contents["subs/subs.rar"]) != "Rar!" {
+		t.Fatalf("contents=%q, want only subs/subs.rar", contents)
+	}
+}
+
+func TestMemoryFSRejectsVolumesWithoutSignature(t *testing.T) {
+	t.Parallel()
+
+	fsys := signatureCheckedFS{fsys: MemoryFS{
+		"set.rar": append([]byte("Rar!\x1a\x07\x01\x00"), "data"...),
+		"set.r00": make([]byte, 64),
+	}}
+	file, err := fsys.Open("set.rar")
+	if err != nil {
+		t.Fatalf("Open(set.rar) returned error: %v", err)
+	}
+	if info, err := file.Stat(); err != nil || info.Size() != 12 {
+		t.Fatalf("Stat()=%v, %v, want size 12", info, err)
+	}
+	if _, err := fsys.Open("set.r00"); !errors.Is(err, errNoSignature) {
+		t.Fatalf("Open(set.r00) error=%v, want errNoSignature", err)
+	}
+	if _, err := fsys.Open("set.r01"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open(set.r01) error=%v, want fs.ErrNotExist", err)
+	}
+}
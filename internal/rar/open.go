@@ -1,9 +1,12 @@
 package rar
 
 import (
+	"context"
 	"errors"
 	"io"
+	"io/fs"
 
+	"github.com/arodd/go-unrarall/internal/checksum"
 	"github.com/nwaples/rardecode/v2"
 )
 
@@ -24,20 +27,100 @@ type OpenSettings struct {
 	MaxDictionaryBytes int64
 	Password           string
 	AllowSymlinks      bool
+	// FileSystem, when set, is used to open archive volumes instead of the
+	// OS filesystem.
+	FileSystem fs.FS
+	// FileSums, when set, records a sum of every regular file written by
+	// extraction. It does not affect decoding.
+	FileSums *checksum.FileSums
+	// Context, when set, stops extraction once done. The entry being
+	// written is removed and extraction fails with the context's error.
+	Context context.Context
 }
 
 // DecodeOptions converts settings into rardecode options.
 func (s OpenSettings) DecodeOptions() []rardecode.Option {
-	opts := make([]rardecode.Option, 0, 2)
+	opts := make([]rardecode.Option, 0, 3)
 	if s.MaxDictionaryBytes > 0 {
 		opts = append(opts, rardecode.MaxDictionarySize(s.MaxDictionaryBytes))
 	}
 	if s.Password != "" {
 		opts = append(opts, rardecode.Password(s.Password))
 	}
+	if s.FileSystem != nil {
+		opts = append(opts, rardecode.FileSystem(s.FileSystem))
+	}
 	return opts
 }
 
+// ErrUnsafeEntry is matched by errors for archive entries refused for
+// safety: paths escaping the extraction root, symlinks while they are
+// disabled, and symlink targets outside the tree.
+var ErrUnsafeEntry = errors.New("unsafe archive entry")
+
+// unsafeEntryError keeps the message of err while also matching
+// ErrUnsafeEntry.
+type unsafeEntryError struct{ err error }
+
+func unsafeEntry(err error) error { return unsafeEntryError{err: err} }
+
+func (e unsafeEntryError) Error() string   { return e.err.Error() }
+func (e unsafeEntryError) Unwrap() []error { return []error{e.err, ErrUnsafeEntry} }
+
+// IsCorruptionError reports whether err indicates damaged archive data or
+// headers, as opposed to I/O failures or missing credentials.
+func IsCorruptionError(err error) bool {
+	for _, target := range []error{
+		rardecode.ErrCorruptBlockHeader,
+		rardecode.ErrCorruptFileHeader,
+		rardecode.ErrBadHeaderCRC,
+		rardecode.ErrDecoderOutOfData,
+		rardecode.ErrCorruptEncryptData,
+		rardecode.ErrBadVolumeNumber,
+		rardecode.ErrNoArchiveBlock,
+		rardecode.ErrNoSig,
+		rardecode.ErrCorruptDecodeHeader,
+		rardecode.ErrInvalidFilter,
+		rardecode.ErrTooManyFilters,
+		rardecode.ErrUnknownFilter,
+		rardecode.ErrHuffDecodeFailed,
+		rardecode.ErrInvalidLengthTable,
+		rardecode.ErrCorruptPPM,
+		rardecode.ErrShortFile,
+		rardecode.ErrInvalidFileBlock,
+		rardecode.ErrUnexpectedArcEnd,
+		rardecode.ErrBadFileChecksum,
+		rardecode.ErrInvalidVMInstruction,
+		rardecode.ErrVerMismatch,
+		rardecode.ErrInvalidHeaderOff,
+		errNoSignature,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnsupportedError reports whether err indicates an archive feature the
+// decoder cannot handle, including a dictionary above --max-dict.
+func IsUnsupportedError(err error) bool {
+	for _, target := range []error{
+		rardecode.ErrUnknownDecoder,
+		rardecode.ErrUnsupportedDecoder,
+		rardecode.ErrUnknownEncryptMethod,
+		rardecode.ErrPlatformIntSize,
+		rardecode.ErrDictionaryTooLarge,
+		rardecode.ErrMultipleDecoders,
+		rardecode.ErrUnknownVersion,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsPasswordError reports whether err indicates that archive decryption
 // credentials are required or incorrect.
 func IsPasswordError(err error) bool {
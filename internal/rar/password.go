@@ -0,0 +1,97 @@
+package rar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// passwordProbeBytes bounds how much of the first encrypted entry is decoded
+// when checking a password. Entries at or below this size are decoded in full
+// so their stored checksum is verified as well.
+const passwordProbeBytes = 256 * 1024
+
+// CheckPassword cheaply verifies settings.Password against archivePath without
+// writing any files. RAR5 archives that carry password check values fail in
+// the header read; otherwise the start of the first encrypted entry is
+// decoded. A rejected password is reported as an error satisfying
+// IsPasswordError. A nil error means the password is very likely correct, but
+// only a full extraction proves it.
+func CheckPassword(archivePath string, settings OpenSettings) error {
+	return checkPasswordWithOpener(openArchiveReader, archivePath, settings.DecodeOptions()...)
+}
+
+func checkPasswordWithOpener(opener openReaderFunc, archivePath string, opts ...rardecode.Option) error {
+	reader, err := opener(archivePath, opts...)
+	if err != nil {
+		return classifyProbeError(err, "")
+	}
+	defer reader.Close()
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return classifyProbeError(err, "")
+		}
+		if header.IsDir || !header.Encrypted {
+			continue
+		}
+
+		_, err = io.CopyN(io.Discard, reader, passwordProbeBytes)
+		if err != nil && err != io.EOF {
+			return classifyProbeError(err, header.Name)
+		}
+		return nil
+	}
+}
+
+// decryptErrors are the failures a wrong key produces: data decrypted with
+// it no longer matches its checksum, and encrypted data decoded with it is
+// garbage the decoder rejects. They are only attributed to the password
+// while decoding an encrypted entry.
+var decryptErrors = []error{
+	rardecode.ErrBadFileChecksum,
+	rardecode.ErrHuffDecodeFailed,
+	rardecode.ErrInvalidLengthTable,
+	rardecode.ErrCorruptPPM,
+	rardecode.ErrCorruptDecodeHeader,
+	rardecode.ErrInvalidFilter,
+	rardecode.ErrUnknownFilter,
+	rardecode.ErrTooManyFilters,
+	rardecode.ErrInvalidVMInstruction,
+}
+
+// classifyProbeError maps failures seen while probing an encrypted archive
+// to password errors when a wrong key explains them: a rejected password
+// check value, encrypted headers failing their CRC, or one of decryptErrors
+// while decoding entry. Anything else, such as a missing volume, an I/O
+// error, or an unsupported archive, is returned unchanged so no further
+// passwords are tried.
+func classifyProbeError(err error, entry string) error {
+	if IsPasswordError(err) {
+		return err
+	}
+	if entry == "" {
+		if errors.Is(err, rardecode.ErrBadHeaderCRC) {
+			return fmt.Errorf("%w: %v", rardecode.ErrBadPassword, err)
+		}
+		return err
+	}
+	for _, target := range decryptErrors {
+		if errors.Is(err, target) {
+			return fmt.Errorf("%w: decoding %q failed: %v", rardecode.ErrBadPassword, entry, err)
+		}
+	}
+	return err
+}
+
+// IsChecksumError reports whether err indicates decoded data did not match
+// the checksum stored in the archive.
+func IsChecksumError(err error) bool {
+	return errors.Is(err, rardecode.ErrBadFileChecksum)
+}
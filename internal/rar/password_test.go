@@ -0,0 +1,140 @@
+package rar
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+type failingArchiveReader struct {
+	fakeArchiveReader
+	readErr error
+}
+
+func (r *failingArchiveReader) Read(p []byte) (int, error) {
+	return 0, r.readErr
+}
+
+func TestCheckPasswordReadsFirstEncryptedEntry(t *testing.T) {
+	t.Parallel()
+
+	reader := &fakeArchiveReader{
+		entries: []fakeArchiveEntry{
+			{header: rardecode.FileHeader{Name: "dir", IsDir: true, Encrypted: true}},
+			{header: rardecode.FileHeader{Name: "readme.txt"}, data: []byte("plain")},
+			{header: rardecode.FileHeader{Name: "payload.bin", Encrypted: true}, data: bytes.Repeat([]byte{1}, passwordProbeBytes*2)},
+		},
+	}
+	opener := func(string, ...rardecode.Option) (archiveReadCloser, error) {
+		return reader, nil
+	}
+
+	if err := checkPasswordWithOpener(opener, "release.rar"); err != nil {
+		t.Fatalf("checkPasswordWithOpener returned error: %v", err)
+	}
+	if remaining := reader.current.Len(); remaining != passwordProbeBytes {
+		t.Fatalf("probe left %d bytes unread, want %d (probe must stop early)", remaining, passwordProbeBytes)
+	}
+}
+
+func TestCheckPasswordClassifiesDecodeFailuresAsPasswordErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		readErr      error
+		wantPassword bool
+	}{
+		{name: "checksum mismatch", readErr: rardecode.ErrBadFileChecksum, wantPassword: true},
+		{name: "decoder garbage", readErr: rardecode.ErrInvalidFilter, wantPassword: true},
+		{name: "password check value", readErr: rardecode.ErrBadPassword, wantPassword: true},
+		{name: "missing volume", readErr: &fs.PathError{Op: "open", Path: "release.r00", Err: fs.ErrNotExist}, wantPassword: false},
+		{name: "truncated set", readErr: rardecode.ErrUnexpectedArcEnd, wantPassword: false},
+		{name: "dictionary too large", readErr: rardecode.ErrDictionaryTooLarge, wantPassword: false},
+		{name: "device error", readErr: errors.New("read release.rar: input/output error"), wantPassword: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader := &failingArchiveReader{
+				fakeArchiveReader: fakeArchiveReader{
+					entries: []fakeArchiveEntry{
+						{header: rardecode.FileHeader{Name: "payload.bin", Encrypted: true}},
+					},
+				},
+				readErr: tc.readErr,
+			}
+			opener := func(string, ...rardecode.Option) (archiveReadCloser, error) {
+				return reader, nil
+			}
+
+			err := checkPasswordWithOpener(opener, "release.rar")
+			if err == nil {
+				t.Fatal("expected probe error")
+			}
+			if got := IsPasswordError(err); got != tc.wantPassword {
+				t.Fatalf("IsPasswordError(%v)=%v, want %v", err, got, tc.wantPassword)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordReportsHeaderPasswordFailure(t *testing.T) {
+	t.Parallel()
+
+	opener := func(string, ...rardecode.Option) (archiveReadCloser, error) {
+		return nil, rardecode.ErrBadPassword
+	}
+	if err := checkPasswordWithOpener(opener, "release.rar"); !IsPasswordError(err) {
+		t.Fatalf("error=%v, want password error", err)
+	}
+}
+
+func TestCheckPasswordClassifiesHeaderFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		openErr      error
+		wantPassword bool
+	}{
+		{name: "encrypted header crc", openErr: rardecode.ErrBadHeaderCRC, wantPassword: true},
+		{name: "not an archive", openErr: rardecode.ErrNoSig, wantPassword: false},
+		{name: "missing volume", openErr: &fs.PathError{Op: "open", Path: "release.rar", Err: fs.ErrNotExist}, wantPassword: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opener := func(string, ...rardecode.Option) (archiveReadCloser, error) {
+				return nil, tc.openErr
+			}
+			err := checkPasswordWithOpener(opener, "release.rar")
+			if got := IsPasswordError(err); got != tc.wantPassword || (!got && err != tc.openErr) {
+				t.Fatalf("error=%v password=%v, want password %v or %v unchanged", err, got, tc.wantPassword, tc.openErr)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordAcceptsArchiveWithoutEncryptedEntries(t *testing.T) {
+	t.Parallel()
+
+	reader := &fakeArchiveReader{
+		entries: []fakeArchiveEntry{
+			{header: rardecode.FileHeader{Name: "plain.txt"}, data: []byte("plain")},
+		},
+	}
+	opener := func(string, ...rardecode.Option) (archiveReadCloser, error) {
+		return reader, nil
+	}
+	if err := checkPasswordWithOpener(opener, "release.rar"); err != nil {
+		t.Fatalf("checkPasswordWithOpener returned error: %v", err)
+	}
+}
@@ -21,10 +21,13 @@ func HasRarSignature(path string) (bool, error) {
 		return false, err
 	}
 	defer file.Close()
+	return hasRarSignature(file)
+}
 
+func hasRarSignature(r io.Reader) (bool, error) {
 	window := maxSFXBytes + len(rar5Signature)
 	buf := make([]byte, window)
-	readN, readErr := io.ReadFull(file, buf)
+	readN, readErr := io.ReadFull(r, buf)
 	if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
 		return false, readErr
 	}
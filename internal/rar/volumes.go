@@ -0,0 +1,126 @@
+package rar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// EntrySpan records the range of volumes an archive entry may occupy, as
+// indexes into VolumeMap.Volumes. The range is conservative: an entry is
+// assumed to run into the volume where the next entry starts.
+type EntrySpan struct {
+	Name        string
+	FirstVolume int
+	LastVolume  int
+}
+
+// VolumeMap relates the entries of a multi-volume set to its volumes.
+type VolumeMap struct {
+	// Volumes are base names in set order.
+	Volumes []string
+	Entries []EntrySpan
+	// Err is set when listing stopped early, for example at a missing or
+	// unreadable volume. Entries after that point are unknown.
+	Err error
+}
+
+// errNoSignature reports a volume without a RAR signature.
+var errNoSignature = errors.New("no RAR signature")
+
+// MapVolumes lists the entries of the set starting at path, noting the
+// volume each one starts in. Only headers are read; entry data is skipped.
+// Each volume's signature is checked before the decoder opens it, since
+// the decoder can spin forever on data without one, such as a zero-filled
+// volume; listing stops at such a volume instead.
+func MapVolumes(path string, opts ...rardecode.Option) (VolumeMap, error) {
+	opts = append(opts, rardecode.FileSystem(signatureCheckedFS{}))
+	return mapVolumesWithOpener(openArchiveReader, path, opts...)
+}
+
+// signatureCheckedFS opens volumes that carry a RAR signature from fsys,
+// or from the OS when fsys is nil.
+type signatureCheckedFS struct{ fsys fs.FS }
+
+func (s signatureCheckedFS) Open(name string) (fs.File, error) {
+	open := func() (fs.File, error) {
+		if s.fsys == nil {
+			return os.Open(name)
+		}
+		return s.fsys.Open(name)
+	}
+	file, err := open()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := hasRarSignature(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(name), errNoSignature)
+	}
+	return open()
+}
+
+func mapVolumesWithOpener(opener openReaderFunc, path string, opts ...rardecode.Option) (VolumeMap, error) {
+	reader, err := opener(path, opts...)
+	if err != nil {
+		return VolumeMap{}, err
+	}
+	defer reader.Close()
+
+	var m VolumeMap
+	// finish closes the previous entry's span at the current last volume.
+	finish := func(last int) {
+		if n := len(m.Entries); n > 0 && m.Entries[n-1].LastVolume < 0 {
+			m.Entries[n-1].LastVolume = max(last, m.Entries[n-1].FirstVolume)
+		}
+	}
+	for {
+		header, err := reader.Next()
+		m.Volumes = reader.Volumes()
+		current := len(m.Volumes) - 1
+		if err != nil {
+			finish(current)
+			if err != io.EOF {
+				m.Err = err
+			}
+			return m, nil
+		}
+		finish(current)
+		if header.IsDir {
+			continue
+		}
+		m.Entries = append(m.Entries, EntrySpan{Name: header.Name, FirstVolume: current, LastVolume: -1})
+	}
+}
+
+// VolumeIndex returns the index of the volume named name, compared
+// case-insensitively, or -1.
+func (m VolumeMap) VolumeIndex(name string) int {
+	for i, volume := range m.Volumes {
+		if strings.EqualFold(volume, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// EntriesIn returns the names of entries that may have data in volume i.
+func (m VolumeMap) EntriesIn(i int) []string {
+	var names []string
+	for _, entry := range m.Entries {
+		if entry.FirstVolume <= i && i <= entry.LastVolume {
+			names = append(names, entry.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,136 @@
+package rar
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// volumeStepReader reports the volumes opened so far, growing as entries
+// in later volumes are reached.
+type volumeStepReader struct {
+	fakeArchiveReader
+	// startVolume[i] is the volume index entry i starts in.
+	startVolume []int
+	all         []string
+	err         error
+	opened      int
+}
+
+func (r *volumeStepReader) Next() (*rardecode.FileHeader, error) {
+	if r.index < len(r.startVolume) {
+		r.opened = r.startVolume[r.index] + 1
+	} else if r.err == nil {
+		r.opened = len(r.all)
+	}
+	header, err := r.fakeArchiveReader.Next()
+	if err == io.EOF && r.err != nil {
+		return nil, r.err
+	}
+	return header, err
+}
+
+func (r *volumeStepReader) Volumes() []string {
+	return r.all[:r.opened]
+}
+
+func TestMapVolumes(t *testing.T) {
+	t.Parallel()
+
+	missing := errors.New("open set.part4.rar: no such file")
+	tests := []struct {
+		name    string
+		reader  *volumeStepReader
+		want    []EntrySpan
+		wantErr error
+	}{
+		{
+			name: "complete set",
+			reader: &volumeStepReader{
+				fakeArchiveReader: fakeArchiveReader{entries: []fakeArchiveEntry{
+					{header: rardecode.FileHeader{Name: "movie.mkv"}},
+					{header: rardecode.FileHeader{Name: "extras", IsDir: true}},
+					{header: rardecode.FileHeader{Name: "extras/movie.nfo"}},
+				}},
+				startVolume: []int{0, 2, 2},
+				all:         []string{"set.part1.rar", "set.part2.rar", "set.part3.rar"},
+			},
+			want: []EntrySpan{
+				{Name: "movie.mkv", FirstVolume: 0, LastVolume: 2},
+				{Name: "extras/movie.nfo", FirstVolume: 2, LastVolume: 2},
+			},
+		},
+		{
+			name: "listing stops at a missing volume",
+			reader: &volumeStepReader{
+				fakeArchiveReader: fakeArchiveReader{entries: []fakeArchiveEntry{
+					{header: rardecode.FileHeader{Name: "movie.mkv"}},
+				}},
+				startVolume: []int{0},
+				all:         []string{"set.part1.rar", "set.part2.rar", "set.part3.rar"},
+				err:         missing,
+			},
+			want:    []EntrySpan{{Name: "movie.mkv", FirstVolume: 0, LastVolume: 0}},
+			wantErr: missing,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opener := func(string, ...rardecode.Option) (archiveReadCloser, error) {
+				return tc.reader, nil
+			}
+			got, err := mapVolumesWithOpener(opener, "set.part1.rar")
+			if err != nil {
+				t.Fatalf("mapVolumesWithOpener returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got.Entries, tc.want) {
+				t.Fatalf("entries=%+v, want %+v", got.Entries, tc.want)
+			}
+			if !errors.Is(got.Err, tc.wantErr) {
+				t.Fatalf("Err=%v, want %v", got.Err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestVolumeMapEntriesIn(t *testing.T) {
+	t.Parallel()
+
+	m := VolumeMap{
+		Volumes: []string{"set.rar", "set.r00", "set.r01"},
+		Entries: []EntrySpan{
+			{Name: "movie.mkv", FirstVolume: 0, LastVolume: 1},
+			{Name: "movie.nfo", FirstVolume: 1, LastVolume: 2},
+		},
+	}
+	if got := m.EntriesIn(m.VolumeIndex("SET.R00")); !reflect.DeepEqual(got, []string{"movie.mkv", "movie.nfo"}) {
+		t.Fatalf("EntriesIn(set.r00)=%v", got)
+	}
+	if got := m.EntriesIn(m.VolumeIndex("set.r01")); !reflect.DeepEqual(got, []string{"movie.nfo"}) {
+		t.Fatalf("EntriesIn(set.r01)=%v", got)
+	}
+	if got := m.VolumeIndex("other.rar"); got != -1 {
+		t.Fatalf("VolumeIndex(other.rar)=%d, want -1", got)
+	}
+}
+
+func TestMapVolumesRejectsDataWithoutSignature(t *testing.T) {
+	t.Parallel()
+
+	// Zero-filled data has no signature byte for the decoder to stop at.
+	path := filepath.Join(t.TempDir(), "set.rar")
+	if err := os.WriteFile(path, make([]byte, 4096), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	if _, err := MapVolumes(path); !errors.Is(err, errNoSignature) {
+		t.Fatalf("MapVolumes error=%v, want errNoSignature", err)
+	}
+}
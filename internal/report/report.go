@@ -0,0 +1,239 @@
+// Package report builds the machine-readable run report written by
+// --report: one record per candidate archive set plus run totals.
+package report
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+	"github.com/arodd/go-unrarall/internal/hooks"
+)
+
+// Version is the report format version. Fields may be added within a
+// version; existing fields keep their meaning.
+const Version = 1
+
+// Outcome is the result of one candidate.
+type Outcome string
+
+const (
+	Extracted Outcome = "extracted"
+	Skipped   Outcome = "skipped"
+	Failed    Outcome = "failed"
+	DryRun    Outcome = "dry_run"
+)
+
+// Checksum results.
+const (
+	ChecksumDisabled = "disabled"
+	ChecksumNone     = "none"
+	ChecksumPassed   = "passed"
+	ChecksumFailed   = "failed"
+)
+
+// ErrorClass groups failures for retry tooling. Only ClassIO and ClassHook
+// failures are worth retrying without changing the input.
+type ErrorClass string
+
+const (
+	ClassNotRAR        ErrorClass = "not_rar"
+	ClassChecksum      ErrorClass = "checksum"
+	ClassMissingVolume ErrorClass = "missing_volume"
+	ClassCorrupt       ErrorClass = "corrupt"
+	ClassUnsupported   ErrorClass = "unsupported"
+	ClassPassword      ErrorClass = "password"
+	ClassUnsafeEntry   ErrorClass = "unsafe_entry"
+	ClassCopyIntegrity ErrorClass = "copy_integrity"
+	ClassIO            ErrorClass = "io"
+	ClassHook          ErrorClass = "hook"
+	ClassCommand       ErrorClass = "command"
+	ClassNested        ErrorClass = "nested"
+	ClassCanceled      ErrorClass = "canceled"
+	ClassTimeout       ErrorClass = "timeout"
+	ClassUnknown       ErrorClass = "unknown"
+)
+
+// Report is the document written to the --report file.
+type Report struct {
+	Version    int          `json:"version"`
+	Dir        string       `json:"dir"`
+	Started    time.Time    `json:"started"`
+	Finished   time.Time    `json:"finished"`
+	Summary    Summary      `json:"summary"`
+	Candidates []*Candidate `json:"candidates"`
+}
+
+// Summary holds the run totals, nested archives included.
+type Summary struct {
+	Found     int `json:"found"`
+	Extracted int `json:"extracted"`
+	Skipped   int `json:"skipped"`
+	Failures  int `json:"failures"`
+	// TimedOut counts the failures rolled back by --archive-timeout or
+	// --max-runtime.
+	TimedOut int `json:"timed_out"`
+}
+
+// Candidate records what happened to one archive set. Only the goroutine
+// processing the set writes to it.
+type Candidate struct {
+	Path string `json:"path"`
+	// Parent is the archive a nested set was extracted from.
+	Parent  string  `json:"parent,omitempty"`
+	Outcome Outcome `json:"outcome"`
+	// SkipReason is "journal" or "exists" for skipped sets, or "busy" for
+	// sets another run held locked.
+	SkipReason string `json:"skip_reason,omitempty"`
+	// Volumes are the base names of the volumes the decoder opened.
+	Volumes  []string `json:"volumes,omitempty"`
+	Checksum Checksum `json:"checksum"`
+	Password Password `json:"password"`
+	// Files are the final paths written to the destination.
+	Files        []File         `json:"files,omitempty"`
+	Hooks        []hooks.Action `json:"hooks,omitempty"`
+	Durations    Durations      `json:"durations_ms"`
+	BytesRead    int64          `json:"bytes_read"`
+	BytesWritten int64          `json:"bytes_written"`
+	Error        *Error         `json:"error,omitempty"`
+	// Retries counts the times the set was started over after a transient
+	// filesystem error.
+	Retries int `json:"retries,omitempty"`
+
+	started time.Time
+}
+
+// Checksum describes manifest verification of the set's volumes.
+type Checksum struct {
+	Result    string `json:"result"`
+	Manifest  string `json:"manifest,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+}
+
+// Password records whether a password was needed and where the working one
+// came from. The password itself is never recorded.
+type Password struct {
+	Required bool   `json:"required"`
+	Source   string `json:"source,omitempty"`
+	// Attempts counts the passwords tried, the working one included.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// File is one file placed in the destination.
+type File struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Symlink bool   `json:"symlink,omitempty"`
+}
+
+// Durations are phase times in milliseconds. Extract includes password
+// retries and nested archives.
+type Durations struct {
+	Total    int64 `json:"total"`
+	Checksum int64 `json:"checksum,omitempty"`
+	Extract  int64 `json:"extract,omitempty"`
+	Move     int64 `json:"move,omitempty"`
+	Hooks    int64 `json:"hooks,omitempty"`
+}
+
+// Error is a classified failure.
+type Error struct {
+	Class   ErrorClass `json:"class"`
+	Message string     `json:"message"`
+}
+
+// Millis converts d to whole milliseconds.
+func Millis(d time.Duration) int64 {
+	return d.Milliseconds()
+}
+
+// Restart clears what an abandoned attempt recorded on c and counts the
+// retry. The path, parent, and start time are kept, so Total still covers
+// every attempt.
+func (c *Candidate) Restart() {
+	*c = Candidate{Path: c.Path, Parent: c.Parent, Retries: c.Retries + 1, started: c.started}
+}
+
+// Finish sets the outcome and total duration of c.
+func (c *Candidate) Finish(outcome Outcome) {
+	c.Outcome = outcome
+	c.Durations.Total = Millis(time.Since(c.started))
+}
+
+// Recorder collects candidate records from concurrent jobs. A nil
+// *Recorder hands out records that are not kept.
+type Recorder struct {
+	mu         sync.Mutex
+	dir        string
+	started    time.Time
+	candidates []*Candidate
+}
+
+// NewRecorder starts a report for a run over dir.
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{dir: dir, started: time.Now().UTC()}
+}
+
+// Start returns a new record for the set at path.
+func (r *Recorder) Start(path, parent string) *Candidate {
+	c := &Candidate{Path: path, Parent: parent, started: time.Now()}
+	if r == nil {
+		return c
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.candidates = append(r.candidates, c)
+	return c
+}
+
+// Discard removes records, such as those of an attempt that was started
+// over, from the report.
+func (r *Recorder) Discard(records []*Candidate) {
+	if r == nil || len(records) == 0 {
+		return
+	}
+	drop := make(map[*Candidate]bool, len(records))
+	for _, c := range records {
+		drop[c] = true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.candidates[:0]
+	for _, c := range r.candidates {
+		if !drop[c] {
+			kept = append(kept, c)
+		}
+	}
+	clear(r.candidates[len(kept):])
+	r.candidates = kept
+}
+
+// Report returns the finished report. It must not be called while records
+// are still being written.
+func (r *Recorder) Report(summary Summary) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	candidates := r.candidates
+	if candidates == nil {
+		candidates = []*Candidate{}
+	}
+	return Report{
+		Version:    Version,
+		Dir:        r.dir,
+		Started:    r.started,
+		Finished:   time.Now().UTC(),
+		Summary:    summary,
+		Candidates: candidates,
+	}
+}
+
+// Write replaces the file at path with report, so readers never see a
+// partial report.
+func Write(path string, report Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, append(data, '\n'), 0o644)
+}
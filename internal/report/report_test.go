@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNilRecorderHandsOutRecords(t *testing.T) {
+	t.Parallel()
+
+	var r *Recorder
+	c := r.Start("/data/set.rar", "")
+	c.Finish(Extracted)
+	if c.Path != "/data/set.rar" || c.Outcome != Extracted {
+		t.Fatalf("record=%+v, want an extracted record for the set", c)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecorder("/data")
+	r.Start("/data/a.rar", "").Finish(Extracted)
+	nested := r.Start("/data/.unrarall-1/b.rar", "/data/a.rar")
+	nested.Error = &Error{Class: ClassCorrupt, Message: "bad header"}
+	nested.Finish(Failed)
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := Write(path, r.Report(Summary{Found: 2, Extracted: 1, Failures: 1})); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if got.Version != Version || got.Dir != "/data" || got.Summary.Found != 2 || len(got.Candidates) != 2 {
+		t.Fatalf("report=%+v, want version %d for /data with 2 candidates", got, Version)
+	}
+	if c := got.Candidates[1]; c.Parent != "/data/a.rar" || c.Outcome != Failed || c.Error.Class != ClassCorrupt {
+		t.Fatalf("nested record=%+v, want a corrupt failure under a.rar", c)
+	}
+}
+
+func TestEmptyReportListsNoCandidates(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(NewRecorder("/data").Report(Summary{}))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if string(raw["candidates"]) != "[]" {
+		t.Fatalf("candidates=%s, want []", raw["candidates"])
+	}
+}
@@ -0,0 +1,556 @@
+// Package server implements the HTTP control API of `unrarall serve`: a
+// queue of extraction jobs run one at a time, job listing and
+// cancellation, and per-job progress streamed as Server-Sent Events.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/metrics"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// Job states.
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+	Canceled  = "canceled"
+)
+
+// Event types.
+const (
+	EventState     = "state"
+	EventLog       = "log"
+	EventCandidate = "candidate"
+)
+
+// maxRequestBytes bounds a job submission body.
+const maxRequestBytes = 1 << 20
+
+// Sink receives the progress of one job's run. It has the method set of
+// app.Sink.
+type Sink interface {
+	Log(level log.Level, message string)
+	Candidate(record report.Candidate)
+}
+
+// RunFunc runs one job with opts, reporting to sink until ctx is done. It
+// returns the run totals and the exit code the command line would use.
+type RunFunc func(ctx context.Context, opts cli.Options, sink Sink) (report.Summary, int, error)
+
+// Config configures a Server.
+type Config struct {
+	Run RunFunc
+	// Log receives job lifecycle messages; job output is logged at the
+	// verbose level.
+	Log *log.Logger
+	// Token must be sent as a bearer token with every request except
+	// /healthz. New generates one when it is empty; see Server.Token.
+	Token string
+	// Addr is the address the API listens on. When its host is a loopback
+	// address, requests must name a loopback host and its port, so a DNS
+	// name rebound to 127.0.0.1 cannot reach the API from a browser.
+	Addr string
+	// History is how many finished jobs are kept.
+	History int
+	// Metrics, when set, counts the candidates and runs of every job.
+	Metrics *metrics.Metrics
+}
+
+// Job is the API view of a job.
+type Job struct {
+	ID         string             `json:"id"`
+	Path       string             `json:"path"`
+	Options    cli.JobOptions     `json:"options"`
+	State      string             `json:"state"`
+	Created    time.Time          `json:"created"`
+	Started    *time.Time         `json:"started,omitempty"`
+	Finished   *time.Time         `json:"finished,omitempty"`
+	Summary    *report.Summary    `json:"summary,omitempty"`
+	ExitCode   *int               `json:"exit_code,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Candidates []report.Candidate `json:"candidates"`
+}
+
+// Event is one entry of a job's progress stream. Seq starts at 1 and is
+// the SSE event id.
+type Event struct {
+	Seq       int               `json:"seq"`
+	Type      string            `json:"type"`
+	Time      time.Time         `json:"time"`
+	State     string            `json:"state,omitempty"`
+	Level     log.Level         `json:"level,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Candidate *report.Candidate `json:"candidate,omitempty"`
+	Summary   *report.Summary   `json:"summary,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// SubmitRequest is the body of POST /jobs.
+type SubmitRequest struct {
+	Path    string         `json:"path"`
+	Options cli.JobOptions `json:"options"`
+}
+
+type job struct {
+	Job
+	opts   cli.Options
+	cancel context.CancelFunc
+	events []Event
+	// changed is closed and replaced whenever an event is added.
+	changed chan struct{}
+}
+
+func (j *job) done() bool {
+	return j.State == Succeeded || j.State == Failed || j.State == Canceled
+}
+
+// Server queues jobs and serves the API. Jobs run one at a time, in
+// submission order, while Run is active.
+type Server struct {
+	cfg Config
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	order   []*job
+	pending []*job
+	wake    chan struct{}
+}
+
+// New returns a server with an empty queue. It fails only when no token
+// was given and none can be generated.
+func New(cfg Config) (*Server, error) {
+	if cfg.Log == nil {
+		cfg.Log = log.NewWithWriters(true, false, nil, nil)
+	}
+	if cfg.Token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate API token: %w", err)
+		}
+		cfg.Token = hex.EncodeToString(b)
+	}
+	return &Server{
+		cfg:  cfg,
+		jobs: make(map[string]*job),
+		wake: make(chan struct{}, 1),
+	}, nil
+}
+
+// Token returns the bearer token clients must send.
+func (s *Server) Token() string {
+	return s.cfg.Token
+}
+
+// Run executes queued jobs until ctx is done. The running job is canceled
+// with ctx and Run returns once it has stopped.
+func (s *Server) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		j, jobCtx := s.start(ctx)
+		if j == nil {
+			select {
+			case <-ctx.Done():
+			case <-s.wake:
+			}
+			continue
+		}
+		s.execute(jobCtx, j)
+	}
+}
+
+// start dequeues the oldest pending job and marks it running, or returns
+// nil when none is pending.
+func (s *Server) start(ctx context.Context) (*job, context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return nil, nil
+	}
+	j := s.pending[0]
+	s.pending = s.pending[1:]
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	now := time.Now().UTC()
+	j.cancel = cancel
+	j.Started = &now
+	j.State = Running
+	s.emit(j, Event{Type: EventState, State: Running})
+	return j, jobCtx
+}
+
+func (s *Server) execute(ctx context.Context, j *job) {
+	s.cfg.Log.Infof("Job %s started: %s", j.ID, j.Path)
+	summary, exitCode, err := s.cfg.Run(ctx, j.opts, &jobSink{s: s, j: j})
+	s.cfg.Metrics.RunFinished(time.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.cancel()
+	now := time.Now().UTC()
+	j.Finished = &now
+	j.cancel = nil
+	j.Summary = &summary
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.State = Canceled
+	case err != nil:
+		j.State = Failed
+		j.Error = err.Error()
+	default:
+		j.ExitCode = &exitCode
+		j.State = Succeeded
+		if exitCode != 0 {
+			j.State = Failed
+		}
+	}
+	s.emit(j, Event{Type: EventState, State: j.State, Summary: j.Summary, Error: j.Error})
+	s.cfg.Log.Infof("Job %s %s: %d found, %d extracted, %d skipped, %d failed", j.ID, j.State, summary.Found, summary.Extracted, summary.Skipped, summary.Failures)
+	s.prune()
+}
+
+// emit appends e to the job's stream. s.mu must be held.
+func (s *Server) emit(j *job, e Event) {
+	e.Seq = len(j.events) + 1
+	e.Time = time.Now().UTC()
+	j.events = append(j.events, e)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// prune forgets the oldest finished jobs beyond the history limit. s.mu
+// must be held.
+func (s *Server) prune() {
+	finished := 0
+	for _, j := range s.order {
+		if j.done() {
+			finished++
+		}
+	}
+	kept := s.order[:0]
+	for _, j := range s.order {
+		if j.done() && finished > s.cfg.History {
+			finished--
+			delete(s.jobs, j.ID)
+			continue
+		}
+		kept = append(kept, j)
+	}
+	clear(s.order[len(kept):])
+	s.order = kept
+}
+
+// jobSink feeds a run's output into its job.
+type jobSink struct {
+	s *Server
+	j *job
+}
+
+func (k *jobSink) Log(level log.Level, message string) {
+	k.s.cfg.Log.Verbosef("[job %s] %s", k.j.ID, message)
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+	k.s.emit(k.j, Event{Type: EventLog, Level: level, Message: message})
+}
+
+func (k *jobSink) Candidate(record report.Candidate) {
+	k.s.cfg.Metrics.Observe(&record)
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+	k.j.Candidates = append(k.j.Candidates, record)
+	k.s.emit(k.j, Event{Type: EventCandidate, Candidate: &record})
+}
+
+// Submit validates and queues a job.
+func (s *Server) Submit(req SubmitRequest) (Job, error) {
+	opts, err := cli.ParseJob(req.Path, req.Options)
+	if err != nil {
+		return Job{}, err
+	}
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	j := &job{
+		Job: Job{
+			ID:         id,
+			Path:       req.Path,
+			Options:    req.Options,
+			State:      Queued,
+			Created:    time.Now().UTC(),
+			Candidates: []report.Candidate{},
+		},
+		opts:    opts,
+		changed: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.jobs[id] = j
+	s.order = append(s.order, j)
+	s.pending = append(s.pending, j)
+	s.emit(j, Event{Type: EventState, State: Queued})
+	view := j.view()
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	s.cfg.Log.Infof("Job %s queued: %s", id, req.Path)
+	return view, nil
+}
+
+// errFinished reports a cancel request for a job that already ended.
+var errFinished = errors.New("job already finished")
+
+// Cancel cancels a queued or running job. A running job starts no further
+// sets, and the sets it has not finished moving are rolled back.
+func (s *Server) Cancel(id string) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false, nil
+	}
+	switch j.State {
+	case Queued:
+		for i, p := range s.pending {
+			if p == j {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				break
+			}
+		}
+		now := time.Now().UTC()
+		j.Finished = &now
+		j.State = Canceled
+		s.emit(j, Event{Type: EventState, State: Canceled})
+		s.prune()
+		s.cfg.Log.Infof("Job %s canceled before it started", id)
+	case Running:
+		j.cancel()
+		s.cfg.Log.Infof("Job %s cancel requested", id)
+	default:
+		return j.view(), true, errFinished
+	}
+	return j.view(), true, nil
+}
+
+// Jobs lists the known jobs, oldest first.
+func (s *Server) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.order))
+	for _, j := range s.order {
+		jobs = append(jobs, j.view())
+	}
+	return jobs
+}
+
+// view copies the job for a response. s.mu must be held.
+func (j *job) view() Job {
+	v := j.Job
+	v.Candidates = append([]report.Candidate{}, j.Candidates...)
+	return v
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Handler returns the API handler.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.Handle("POST /jobs", s.authorized(s.handleSubmit))
+	mux.Handle("GET /jobs", s.authorized(s.handleList))
+	mux.Handle("GET /jobs/{id}", s.authorized(s.handleGet))
+	mux.Handle("POST /jobs/{id}/cancel", s.authorized(s.handleCancel))
+	mux.Handle("GET /jobs/{id}/events", s.authorized(s.handleEvents))
+	return s.checkHost(mux)
+}
+
+// checkHost rejects requests whose Host header does not name the API
+// when it listens on a loopback address.
+func (s *Server) checkHost(next http.Handler) http.Handler {
+	host, port, err := net.SplitHostPort(s.cfg.Addr)
+	if err != nil || !isLoopback(host) {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqHost, reqPort, err := net.SplitHostPort(r.Host)
+		if err != nil || reqPort != port || !isLoopback(reqHost) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not this API's address", r.Host))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.Handler {
+	want := []byte("Bearer " + s.cfg.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	// Browsers send text/plain and form bodies cross-origin without a
+	// preflight; JSON they do not.
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("content type must be application/json"))
+		return
+	}
+	var req SubmitRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode job: %w", err))
+		return
+	}
+	job, err := s.Submit(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	jobs := []Job{}
+	for _, j := range s.Jobs() {
+		if state == "" || j.State == state {
+			jobs = append(jobs, j)
+		}
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	var view Job
+	if ok {
+		view = j.view()
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	job, ok, err := s.Cancel(r.PathValue("id"))
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, errors.New("no such job"))
+	case err != nil:
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// handleEvents streams a job's events, replaying those after Last-Event-ID
+// (all of them by default), until the job ends or the client goes away.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	next := 0
+	if last, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && last > 0 {
+		next = last
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		s.mu.Lock()
+		var events []Event
+		if next < len(j.events) {
+			events = append(events, j.events[next:]...)
+		}
+		changed, done := j.changed, j.done()
+		s.mu.Unlock()
+
+		for _, e := range events {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data); err != nil {
+				return
+			}
+		}
+		next += len(events)
+		flusher.Flush()
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/cli"
+	"github.com/arodd/go-unrarall/internal/log"
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// testToken is the bearer token of servers started by startServer.
+const testToken = "s3cret"
+
+// startServer runs a server whose jobs are handled by run.
+func startServer(t *testing.T, run RunFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	s, err := New(Config{Run: run, Token: testToken, Addr: srv.Listener.Addr().String(), History: 10})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	srv.Config.Handler = s.Handler()
+	srv.Start()
+	t.Cleanup(func() {
+		srv.Close()
+		cancel()
+		<-done
+	})
+	return srv
+}
+
+func request(t *testing.T, method, url, token string, body any, out any) int {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatalf("encode request: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func waitForState(t *testing.T, srv *httptest.Server, id, state string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job Job
+		request(t, http.MethodGet, srv.URL+"/jobs/"+id, testToken, nil, &job)
+		if job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, job.State, state)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSubmitRunsJobAndStreamsEvents(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	archive := filepath.Join(root, "movie.rar")
+	if err := os.WriteFile(archive, []byte("rar"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	var got cli.Options
+	srv := startServer(t, func(_ context.Context, opts cli.Options, sink Sink) (report.Summary, int, error) {
+		got = opts
+		sink.Log(log.LevelInfo, "Extracting movie.rar")
+		sink.Candidate(report.Candidate{Path: archive, Outcome: report.Extracted})
+		return report.Summary{Found: 1, Extracted: 1}, 0, nil
+	})
+
+	var job Job
+	status := request(t, http.MethodPost, srv.URL+"/jobs", testToken, SubmitRequest{Path: archive, Options: cli.JobOptions{Force: true, Clean: []string{"rar"}}}, &job)
+	if status != http.StatusCreated || job.ID == "" {
+		t.Fatalf("submit returned %d %+v, want 201 with an id", status, job)
+	}
+	finished := waitForState(t, srv, job.ID, Succeeded)
+	if got.Dir != root || got.Archive != archive || !got.Force || !got.NoPasswordPrompt || got.CleanHooks[0] != "rar" {
+		t.Fatalf("run options=%+v, want the archive's directory, the archive, --force, and --clean=rar", got)
+	}
+	if len(finished.Candidates) != 1 || finished.Summary.Extracted != 1 || *finished.ExitCode != 0 {
+		t.Fatalf("job=%+v, want one extracted candidate and exit code 0", finished)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/jobs/"+job.ID+"/events", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type=%q, want text/event-stream", ct)
+	}
+	var types []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			types = append(types, name)
+		}
+	}
+	want := []string{EventState, EventState, EventLog, EventCandidate, EventState}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("event types=%v, want %v", types, want)
+	}
+}
+
+func TestCancelStopsRunningAndQueuedJobs(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	started := make(chan struct{}, 2)
+	srv := startServer(t, func(ctx context.Context, _ cli.Options, _ Sink) (report.Summary, int, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return report.Summary{}, 0, ctx.Err()
+	})
+
+	var first, second Job
+	request(t, http.MethodPost, srv.URL+"/jobs", testToken, SubmitRequest{Path: root}, &first)
+	request(t, http.MethodPost, srv.URL+"/jobs", testToken, SubmitRequest{Path: root}, &second)
+	<-started
+
+	var list []Job
+	request(t, http.MethodGet, srv.URL+"/jobs?state=queued", testToken, nil, &list)
+	if len(list) != 1 || list[0].ID != second.ID {
+		t.Fatalf("queued jobs=%+v, want only the second job", list)
+	}
+
+	if status := request(t, http.MethodPost, srv.URL+"/jobs/"+second.ID+"/cancel", testToken, nil, nil); status != http.StatusAccepted {
+		t.Fatalf("cancel queued job returned %d, want 202", status)
+	}
+	waitForState(t, srv, second.ID, Canceled)
+	if status := request(t, http.MethodPost, srv.URL+"/jobs/"+first.ID+"/cancel", testToken, nil, nil); status != http.StatusAccepted {
+		t.Fatalf("cancel running job returned %d, want 202", status)
+	}
+	waitForState(t, srv, first.ID, Canceled)
+	if status := request(t, http.MethodPost, srv.URL+"/jobs/"+first.ID+"/cancel", testToken, nil, nil); status != http.StatusConflict {
+		t.Fatalf("cancel finished job returned %d, want 409", status)
+	}
+	if len(started) != 0 {
+		t.Fatal("the canceled queued job was started")
+	}
+}
+
+func TestSubmitValidatesJobs(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	srv := startServer(t, func(context.Context, cli.Options, Sink) (report.Summary, int, error) {
+		return report.Summary{}, 0, nil
+	})
+	for _, body := range []any{
+		SubmitRequest{Path: "relative/dir"},
+		SubmitRequest{Path: filepath.Join(root, "missing")},
+		SubmitRequest{Path: root, Options: cli.JobOptions{Clean: []string{"bogus"}}},
+		map[string]any{"path": root, "options": map[string]any{"on_extracted": "rm -rf /"}},
+	} {
+		var resp map[string]string
+		if status := request(t, http.MethodPost, srv.URL+"/jobs", testToken, body, &resp); status != http.StatusBadRequest || resp["error"] == "" {
+			t.Fatalf("submit %+v returned %d %v, want 400 with an error", body, status, resp)
+		}
+	}
+	if status := request(t, http.MethodGet, srv.URL+"/jobs/nope", testToken, nil, nil); status != http.StatusNotFound {
+		t.Fatalf("unknown job returned %d, want 404", status)
+	}
+}
+
+func TestTokenRequiredExceptHealthz(t *testing.T) {
+	t.Parallel()
+
+	srv := startServer(t, func(context.Context, cli.Options, Sink) (report.Summary, int, error) {
+		return report.Summary{}, 0, nil
+	})
+	var health map[string]string
+	if status := request(t, http.MethodGet, srv.URL+"/healthz", "", nil, &health); status != http.StatusOK || health["status"] != "ok" {
+		t.Fatalf("healthz returned %d %v, want 200 ok", status, health)
+	}
+	if status := request(t, http.MethodGet, srv.URL+"/jobs", "wrong", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("wrong token returned %d, want 401", status)
+	}
+	if status := request(t, http.MethodGet, srv.URL+"/jobs", testToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("right token returned %d, want 200", status)
+	}
+}
+
+func TestNewGeneratesToken(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	other, _ := New(Config{})
+	if len(s.Token()) != 64 || s.Token() == other.Token() {
+		t.Fatalf("tokens %q and %q, want distinct random 32-byte tokens", s.Token(), other.Token())
+	}
+}
+
+func TestHandlerRejectsBrowserRequests(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	srv := startServer(t, func(context.Context, cli.Options, Sink) (report.Summary, int, error) {
+		return report.Summary{}, 0, nil
+	})
+
+	body, _ := json.Marshal(SubmitRequest{Path: root})
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/jobs", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("text/plain submit returned %d, want 415", resp.StatusCode)
+	}
+
+	_, port, _ := strings.Cut(srv.Listener.Addr().String(), ":")
+	for host, want := range map[string]int{
+		"rebound.example:" + port: http.StatusForbidden,
+		"127.0.0.1:1":             http.StatusForbidden,
+		"localhost:" + port:       http.StatusOK,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/healthz", nil)
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("healthz: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("Host %q returned %d, want %d", host, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestPruneKeepsHistory(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(Config{History: 1})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	for _, state := range []string{Succeeded, Running, Failed, Canceled} {
+		j := &job{Job: Job{ID: state, State: state}}
+		s.jobs[j.ID] = j
+		s.order = append(s.order, j)
+	}
+	s.prune()
+	var ids []string
+	for _, j := range s.Jobs() {
+		ids = append(ids, j.ID)
+	}
+	if strings.Join(ids, ",") != "running,canceled" || len(s.jobs) != 2 {
+		t.Fatalf("jobs=%v, want the running job and the newest finished one", ids)
+	}
+}
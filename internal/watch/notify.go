@@ -0,0 +1,28 @@
+// Package watch notices new and changed archive sets under a directory
+// tree: change notifications where the platform has them, and a tracker
+// that decides when a set has stopped changing.
+package watch
+
+import "errors"
+
+// ErrUnsupported is returned by NewNotifier where change notifications are
+// not available; callers fall back to periodic rescans.
+var ErrUnsupported = errors.New("change notifications are not supported on this platform")
+
+// Notifier signals changes anywhere under a directory tree. Signals are
+// coalesced: C holds at most one pending signal, so a burst of changes
+// wakes the receiver once. Directories created later are watched too.
+// Extraction temp directories are ignored.
+type Notifier struct {
+	C <-chan struct{}
+
+	close func() error
+}
+
+// Close stops watching. C is not closed.
+func (n *Notifier) Close() error {
+	if n == nil || n.close == nil {
+		return nil
+	}
+	return n.close()
+}
@@ -0,0 +1,141 @@
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/arodd/go-unrarall/internal/fsutil"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO |
+	syscall.IN_MOVED_FROM | syscall.IN_DELETE | syscall.IN_ATTRIB | syscall.IN_MODIFY
+
+// NewNotifier watches root and every directory below it with inotify.
+func NewNotifier(root string) (*Notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	// A non-blocking descriptor is read through the runtime poller, so
+	// closing the file wakes the reader.
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	changes := make(chan struct{}, 1)
+	w := &inotifyWatcher{fd: fd, file: file, dirs: make(map[int32]string), changes: changes}
+	if err := w.addTree(root); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.read()
+	}()
+	var once sync.Once
+	var closeErr error
+	return &Notifier{
+		C: changes,
+		close: func() error {
+			once.Do(func() {
+				closeErr = file.Close()
+				<-done
+			})
+			return closeErr
+		},
+	}, nil
+}
+
+type inotifyWatcher struct {
+	fd      int
+	file    *os.File
+	dirs    map[int32]string
+	changes chan struct{}
+}
+
+// addTree adds a watch for dir and each directory below it, skipping
+// extraction temp directories.
+func (w *inotifyWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dir && fsutil.IsTempDir(entry.Name()) {
+			return filepath.SkipDir
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+		if err != nil {
+			if path != dir && errors.Is(err, syscall.ENOENT) {
+				return filepath.SkipDir
+			}
+			return fmt.Errorf("inotify watch %q: %w", path, err)
+		}
+		w.dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		changed := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				changed = true
+				continue
+			}
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, event.Wd)
+				continue
+			}
+			dir, ok := w.dirs[event.Wd]
+			if !ok {
+				continue
+			}
+			name := cString(nameBytes)
+			if fsutil.IsTempDir(name) {
+				continue
+			}
+			if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				// Errors leave the subtree to periodic rescans.
+				_ = w.addTree(filepath.Join(dir, name))
+			}
+			changed = true
+		}
+		if changed {
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,62 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotifierSeesFilesInNewDirectories(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	notifier, err := NewNotifier(root)
+	if err != nil {
+		t.Fatalf("NewNotifier returned error: %v", err)
+	}
+	defer notifier.Close()
+
+	expectChange := func(what string) {
+		t.Helper()
+		select {
+		case <-notifier.C:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change reported after %s", what)
+		}
+	}
+	drain := func() {
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-notifier.C:
+		default:
+		}
+	}
+
+	sub := filepath.Join(root, "release")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	expectChange("creating a directory")
+	drain()
+
+	if err := os.WriteFile(filepath.Join(sub, "set.part1.rar"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write volume: %v", err)
+	}
+	expectChange("writing a file in the new directory")
+	drain()
+
+	// Changes inside extraction temp directories are not reported.
+	if err := os.Mkdir(filepath.Join(sub, ".unrarall-1"), 0o755); err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	select {
+	case <-notifier.C:
+		t.Fatal("change reported for an extraction temp directory")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := notifier.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watch
+
+// NewNotifier returns ErrUnsupported outside Linux.
+func NewNotifier(root string) (*Notifier, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,68 @@
+package watch
+
+import "time"
+
+// Tracker decides when archive sets are ready to process. A set is ready
+// once its signature (for example the names, sizes, and mtimes of its
+// volumes) has been observed unchanged for Settle, and stays done until the
+// signature changes.
+type Tracker struct {
+	Settle time.Duration
+
+	sets map[string]*setState
+}
+
+type setState struct {
+	signature string
+	since     time.Time
+	done      bool
+}
+
+// Observe records the current signature of every set found by a scan,
+// keyed by set, and returns the keys that are ready. Sets missing from
+// signatures are forgotten. wait is how long until the next set still
+// settling becomes ready, or 0 when none is settling.
+func (t *Tracker) Observe(now time.Time, signatures map[string]string) (ready []string, wait time.Duration) {
+	if t.sets == nil {
+		t.sets = make(map[string]*setState)
+	}
+	for key := range t.sets {
+		if _, ok := signatures[key]; !ok {
+			delete(t.sets, key)
+		}
+	}
+	for key, signature := range signatures {
+		state, ok := t.sets[key]
+		if !ok || state.signature != signature {
+			state = &setState{signature: signature, since: now}
+			t.sets[key] = state
+		}
+		if state.done {
+			continue
+		}
+		if remaining := state.since.Add(t.Settle).Sub(now); remaining > 0 {
+			if wait == 0 || remaining < wait {
+				wait = remaining
+			}
+			continue
+		}
+		ready = append(ready, key)
+	}
+	return ready, wait
+}
+
+// Done marks key as handled at its current signature. It is not returned
+// by Observe again until its signature changes.
+func (t *Tracker) Done(key string) {
+	if state, ok := t.sets[key]; ok {
+		state.done = true
+	}
+}
+
+// Retry undoes Done for key, so Observe returns it again even though its
+// signature is unchanged.
+func (t *Tracker) Retry(key string) {
+	if state, ok := t.sets[key]; ok {
+		state.done = false
+	}
+}
@@ -0,0 +1,62 @@
+package watch
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTrackerWaitsForSetsToSettle(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tracker := Tracker{Settle: 30 * time.Second}
+
+	ready, wait := tracker.Observe(start, map[string]string{"a": "1"})
+	if len(ready) != 0 || wait != 30*time.Second {
+		t.Fatalf("first scan ready=%v wait=%v, want none ready and 30s wait", ready, wait)
+	}
+
+	// The set grew: it must settle again from now.
+	ready, wait = tracker.Observe(start.Add(20*time.Second), map[string]string{"a": "2", "b": "1"})
+	if len(ready) != 0 || wait != 30*time.Second {
+		t.Fatalf("changed scan ready=%v wait=%v, want none ready and 30s wait", ready, wait)
+	}
+
+	ready, wait = tracker.Observe(start.Add(50*time.Second), map[string]string{"a": "2", "b": "1"})
+	slices.Sort(ready)
+	if !slices.Equal(ready, []string{"a", "b"}) || wait != 0 {
+		t.Fatalf("settled scan ready=%v wait=%v, want a and b ready", ready, wait)
+	}
+	tracker.Done("a")
+	tracker.Done("b")
+
+	ready, _ = tracker.Observe(start.Add(time.Minute), map[string]string{"a": "2", "b": "1"})
+	if len(ready) != 0 {
+		t.Fatalf("done sets returned again: %v", ready)
+	}
+
+	// A changed volume makes a done set pending again; a vanished set is
+	// forgotten and settles anew if it reappears.
+	tracker.Observe(start.Add(2*time.Minute), map[string]string{"a": "3"})
+	ready, _ = tracker.Observe(start.Add(3*time.Minute), map[string]string{"a": "3", "b": "1"})
+	if !slices.Equal(ready, []string{"a"}) {
+		t.Fatalf("ready=%v, want only the changed set a", ready)
+	}
+}
+
+func TestTrackerRetryReturnsDoneSet(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tracker := Tracker{}
+	tracker.Observe(start, map[string]string{"a": "1"})
+	tracker.Done("a")
+	tracker.Retry("a")
+	tracker.Retry("missing")
+
+	ready, _ := tracker.Observe(start.Add(time.Minute), map[string]string{"a": "1"})
+	if !slices.Equal(ready, []string{"a"}) {
+		t.Fatalf("ready=%v, want the retried set a", ready)
+	}
+}
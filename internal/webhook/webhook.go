@@ -0,0 +1,246 @@
+// Package webhook delivers run events for --webhook as JSON POST requests,
+// built from the same records as the run report.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+// Event types.
+const (
+	CandidateExtracted = "candidate.extracted"
+	CandidateFailed    = "candidate.failed"
+	RunFinished        = "run.finished"
+)
+
+// Request headers. SignatureHeader is only sent when a secret is set and
+// holds "sha256=" followed by the hex HMAC-SHA256 of the body.
+const (
+	EventHeader     = "X-Unrarall-Event"
+	SignatureHeader = "X-Unrarall-Signature"
+)
+
+// maxBackoff caps the wait between delivery attempts.
+const maxBackoff = 30 * time.Second
+
+// queueSize is the number of events that may wait for delivery; Send drops
+// events beyond it.
+const queueSize = 64
+
+// defaultDrainTimeout is how long Close waits for queued events when
+// Config.DrainTimeout is zero.
+const defaultDrainTimeout = 30 * time.Second
+
+// Event is the JSON body of one request. Candidate is set for candidate
+// events and Summary for run.finished.
+type Event struct {
+	Version   int               `json:"version"`
+	Type      string            `json:"type"`
+	Time      time.Time         `json:"time"`
+	Dir       string            `json:"dir"`
+	Candidate *report.Candidate `json:"candidate,omitempty"`
+	Summary   *report.Summary   `json:"summary,omitempty"`
+}
+
+// Config describes the endpoint and delivery policy.
+type Config struct {
+	URL string
+	// Secret signs each body when non-empty.
+	Secret []byte
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// Retries is the number of attempts after the first.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles after each
+	// one. Zero means one second.
+	Backoff time.Duration
+	// DrainTimeout bounds how long Close waits for queued events. Zero
+	// means 30 seconds.
+	DrainTimeout time.Duration
+}
+
+// Sender delivers events in order from a background goroutine, so slow or
+// unreachable endpoints never hold up extraction. A nil *Sender drops
+// events.
+type Sender struct {
+	cfg    Config
+	client *http.Client
+	errorf func(format string, args ...any)
+	// ctx is the run's context; once it is done, failed deliveries are no
+	// longer retried.
+	ctx   context.Context
+	queue chan delivery
+	done  chan struct{}
+	// stopped ends delivery when Close's drain deadline passes: the request
+	// in flight is abandoned and the events still queued are dropped.
+	stopped context.Context
+	stop    context.CancelFunc
+}
+
+type delivery struct {
+	kind string
+	body []byte
+}
+
+// New starts a sender for cfg within the run whose context is ctx. Failed
+// and dropped deliveries are reported to errorf.
+func New(ctx context.Context, cfg Config, errorf func(format string, args ...any)) *Sender {
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = defaultDrainTimeout
+	}
+	s := &Sender{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		errorf: errorf,
+		ctx:    ctx,
+		queue:  make(chan delivery, queueSize),
+		done:   make(chan struct{}),
+	}
+	s.stopped, s.stop = context.WithCancel(context.Background())
+	go s.loop()
+	return s
+}
+
+// Send queues event for delivery without waiting: when the queue is full,
+// the event is dropped and logged. The event is encoded before Send
+// returns, so the records it points to may change afterwards.
+func (s *Sender) Send(event Event) {
+	if s == nil {
+		return
+	}
+	event.Version = report.Version
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		s.errorf("Webhook %s not sent: %v", event.Type, err)
+		return
+	}
+	select {
+	case s.queue <- delivery{kind: event.Type, body: body}:
+	default:
+		s.errorf("Webhook %s dropped: %d events are already waiting for %s", event.Type, queueSize, s.cfg.URL)
+	}
+}
+
+// Close waits for queued events to be delivered or given up on, but no
+// longer than the drain timeout; events left then are dropped and logged.
+// Send must not be called afterwards.
+func (s *Sender) Close() {
+	if s == nil {
+		return
+	}
+	close(s.queue)
+	timer := time.NewTimer(s.cfg.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.done:
+	case <-timer.C:
+		s.stop()
+		<-s.done
+	}
+	s.stop()
+}
+
+func (s *Sender) loop() {
+	defer close(s.done)
+	dropped := 0
+	for d := range s.queue {
+		if s.stopped.Err() != nil {
+			dropped++
+			continue
+		}
+		if err := s.deliver(d); err != nil {
+			s.errorf("Webhook %s not delivered to %s: %v", d.kind, s.cfg.URL, err)
+		}
+	}
+	if dropped > 0 {
+		s.errorf("Webhook: %d queued event(s) dropped after waiting %s for %s", dropped, s.cfg.DrainTimeout, s.cfg.URL)
+	}
+}
+
+// deliver posts d, retrying network errors, 429, and 5xx responses until
+// the run is canceled or Close stops delivery.
+func (s *Sender) deliver(d delivery) error {
+	wait := s.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(d)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.cfg.Retries || s.ctx.Err() != nil {
+			if attempt > 0 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+		if waitErr := s.wait(wait); waitErr != nil {
+			return fmt.Errorf("%w (retry abandoned: %w)", err, waitErr)
+		}
+		wait = min(wait*2, maxBackoff)
+	}
+}
+
+// wait sleeps d before a retry, returning early once the run is canceled or
+// Close stops delivery.
+func (s *Sender) wait(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-s.stopped.Done():
+		return s.stopped.Err()
+	}
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (s *Sender) post(d delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(s.stopped, http.MethodPost, s.cfg.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.kind)
+	if len(s.cfg.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.cfg.Secret, d.body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("server returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("server returned %s", resp.Status)
+	}
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,269 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arodd/go-unrarall/internal/report"
+)
+
+type received struct {
+	kind      string
+	signature string
+	event     Event
+}
+
+// recordingServer answers each request with the next status in statuses,
+// then 204.
+func recordingServer(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		got  []received
+		next int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("decode body %q: %v", body, err)
+		}
+		mu.Lock()
+		got = append(got, received{kind: r.Header.Get(EventHeader), signature: r.Header.Get(SignatureHeader), event: event})
+		status := http.StatusNoContent
+		if next < len(statuses) {
+			status = statuses[next]
+		}
+		next++
+		mu.Unlock()
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type=%q, want application/json", r.Header.Get("Content-Type"))
+		}
+		if sig := r.Header.Get(SignatureHeader); sig != "" && sig != Sign([]byte("s3cret"), body) {
+			t.Errorf("signature %q does not match body", sig)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), got...)
+	}
+}
+
+func TestSenderDeliversSignedEventsInOrder(t *testing.T) {
+	t.Parallel()
+
+	srv, got := recordingServer(t)
+	s := New(context.Background(), Config{URL: srv.URL, Secret: []byte("s3cret"), Timeout: time.Second}, func(format string, args ...any) {
+		t.Errorf("unexpected error: "+format, args...)
+	})
+	record := &report.Candidate{Path: "/data/a.rar", Outcome: report.Extracted}
+	s.Send(Event{Type: CandidateExtracted, Dir: "/data", Candidate: record})
+	// The event is encoded on Send; later changes are not delivered.
+	record.Outcome = report.Failed
+	s.Send(Event{Type: RunFinished, Dir: "/data", Summary: &report.Summary{Found: 1, Extracted: 1}})
+	s.Close()
+
+	events := got()
+	if len(events) != 2 {
+		t.Fatalf("received %d events, want 2", len(events))
+	}
+	first, last := events[0], events[1]
+	if first.kind != CandidateExtracted || first.event.Candidate == nil || first.event.Candidate.Outcome != report.Extracted {
+		t.Fatalf("first=%+v, want the extracted candidate", first)
+	}
+	if first.event.Version != report.Version || first.event.Time.IsZero() || first.event.Dir != "/data" {
+		t.Fatalf("first event=%+v, want version, time, and dir set", first.event)
+	}
+	if !strings.HasPrefix(first.signature, "sha256=") {
+		t.Fatalf("signature=%q, want sha256=<hex>", first.signature)
+	}
+	if last.kind != RunFinished || last.event.Summary == nil || last.event.Summary.Extracted != 1 {
+		t.Fatalf("last=%+v, want the run summary", last)
+	}
+}
+
+func TestSenderRetriesTransientFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		retries  int
+		statuses []int
+		attempts int
+		failed   bool
+	}{
+		{name: "recovers", retries: 3, statuses: []int{503, 429}, attempts: 3},
+		{name: "gives up", retries: 1, statuses: []int{500, 502, 503}, attempts: 2, failed: true},
+		{name: "client error is final", retries: 3, statuses: []int{400}, attempts: 1, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv, got := recordingServer(t, tt.statuses...)
+			var errs []string
+			s := New(context.Background(), Config{URL: srv.URL, Timeout: time.Second, Retries: tt.retries, Backoff: time.Millisecond}, func(format string, args ...any) {
+				errs = append(errs, fmt.Sprintf(format, args...))
+			})
+			s.Send(Event{Type: CandidateFailed})
+			s.Close()
+
+			if n := len(got()); n != tt.attempts {
+				t.Fatalf("attempts=%d, want %d", n, tt.attempts)
+			}
+			if got()[0].signature != "" {
+				t.Fatalf("signature sent without a secret")
+			}
+			if (len(errs) > 0) != tt.failed {
+				t.Fatalf("errors=%q, want failure %v", errs, tt.failed)
+			}
+		})
+	}
+}
+
+func TestSenderReportsUnreachableEndpoint(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	var errs []string
+	s := New(context.Background(), Config{URL: url, Timeout: time.Second, Retries: 1, Backoff: time.Millisecond}, func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	})
+	s.Send(Event{Type: RunFinished})
+	s.Close()
+	if len(errs) != 1 || !strings.Contains(errs[0], "after 2 attempts") {
+		t.Fatalf("errors=%q, want one failure after 2 attempts", errs)
+	}
+}
+
+func TestNilSenderDropsEvents(t *testing.T) {
+	t.Parallel()
+
+	var s *Sender
+	s.Send(Event{Type: RunFinished})
+	s.Close()
+}
+
+// blockingServer holds every request until release is closed or the client
+// gives up, and reports each arrival on started.
+func blockingServer(t *testing.T) (srv *httptest.Server, started <-chan struct{}, release chan struct{}) {
+	t.Helper()
+	arrived := make(chan struct{}, 1)
+	release = make(chan struct{})
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	return srv, arrived, release
+}
+
+func TestSenderDropsEventsInsteadOfBlocking(t *testing.T) {
+	t.Parallel()
+
+	srv, started, _ := blockingServer(t)
+	var (
+		mu   sync.Mutex
+		errs []string
+	)
+	s := New(context.Background(), Config{URL: srv.URL, Timeout: time.Minute, DrainTimeout: 50 * time.Millisecond}, func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, fmt.Sprintf(format, args...))
+	})
+	s.Send(Event{Type: CandidateExtracted})
+	<-started
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for range queueSize + 1 {
+			s.Send(Event{Type: CandidateExtracted})
+		}
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked on a full queue")
+	}
+
+	closed := time.Now()
+	s.Close()
+	if waited := time.Since(closed); waited > 5*time.Second {
+		t.Fatalf("Close waited %s, want it bounded by the drain timeout", waited)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"dropped: 64 events are already waiting", "not delivered", "64 queued event(s) dropped"}
+	if len(errs) != len(want) {
+		t.Fatalf("errors=%q, want %d", errs, len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(errs[i], w) {
+			t.Fatalf("errors[%d]=%q, want it to mention %q", i, errs[i], w)
+		}
+	}
+}
+
+func TestSenderStopsRetryingOnceRunIsCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	// The run is canceled while the first attempt is in flight, so its 503
+	// arrives after cancellation on every schedule.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	var errs []string
+	s := New(ctx, Config{URL: srv.URL, Timeout: time.Second, Retries: 3, Backoff: time.Hour}, func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	})
+	s.Send(Event{Type: CandidateFailed})
+	s.Send(Event{Type: RunFinished})
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Fatalf("attempts=%d, want one per event and no retries", attempts)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("errors=%q, want one per event", errs)
+	}
+	for _, e := range errs {
+		if !strings.HasSuffix(e, "server returned 503 Service Unavailable") {
+			t.Fatalf("errors=%q, want each event failed once without a retry", errs)
+		}
+	}
+}